@@ -71,6 +71,12 @@ func (a *Admin) Notify(command string) (string, error) {
 			return fmt.Sprintf("Getconfig Error!: %s", cmd[1]), err
 		}
 		return fmt.Sprintf("Getconfig Success!: %s", cmd[1]), nil
+	case "tars.reflection":
+		return reflectionCMD(cmd[1:])
+	case "tars.viewstat":
+		return statPercentileCMD(cmd[1:])
+	case "tars.viewremotelog":
+		return remoteLogStatCMD(cmd[1:])
 	case "tars.connection":
 		return fmt.Sprintf("%s not support now!", command), nil
 	case "tars.gracerestart":
@@ -118,8 +124,15 @@ func RegisterAdmin(name string, fn adminFn) {
 	adminMethods[name] = fn
 }
 
+// setLogLevelCMD sets the level of the framework logger by "tars.setloglevel <level>", or the level of a
+// module by "tars.setloglevel <module> <level>".
 func setLogLevelCMD(params []string) (string, error) {
-	if len(params) >= 1 {
+	if len(params) >= 2 {
+		if err := zaplog.SetModuleLevel(params[0], strings.ToLower(params[1])); err != nil {
+			return "SetModuleLevel failed", err
+		}
+		return "set level of " + params[0] + " to " + params[1], nil
+	} else if len(params) >= 1 {
 		if err := zaplog.SetLogLevel(strings.ToLower(params[0])); err != nil {
 			return "SetLogLevel failed", err
 		}
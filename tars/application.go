@@ -15,6 +15,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -31,6 +32,7 @@ import (
 
 var tarsConfig map[string]*transport.TarsServerConf
 var goSvrs map[string]*transport.TarsServer
+var servantMuxes map[string]*servantMux
 var httpSvrs map[string]*http.Server
 var listenFds []*os.File
 var shutdown chan bool
@@ -55,6 +57,7 @@ type destroyableImp interface {
 func init() {
 	tarsConfig = make(map[string]*transport.TarsServerConf)
 	goSvrs = make(map[string]*transport.TarsServer)
+	servantMuxes = make(map[string]*servantMux)
 	httpSvrs = make(map[string]*http.Server)
 	shutdown = make(chan bool, 1)
 	adminMethods = make(map[string]adminFn)
@@ -103,11 +106,16 @@ func initConfig() {
 	svrCfg.LogSize = tools.ParseLogSizeMb(sMap["logsize"])
 	svrCfg.LogNum = tools.ParseLogNum(sMap["lognum"])
 	svrCfg.LogLevel = sMap["logLevel"]
+	svrCfg.LogEncoding = c.GetStringWithDef("/tars/application/server<logencoding>", LogEncoding)
+	svrCfg.LogRetention = c.GetIntWithDef("/tars/application/server<logretention>", LogRetention)
+	svrCfg.LogHTTPServer = c.GetBoolWithDef("/tars/application/server<loghttpserver>", LogHTTPServer)
 	svrCfg.Config = sMap["config"]
 	svrCfg.Notify = sMap["notify"]
 	svrCfg.BasePath = sMap["basepath"]
 	svrCfg.DataPath = sMap["datapath"]
 	svrCfg.Log = sMap["log"]
+	svrCfg.Metrics = sMap["metrics"]
+	svrCfg.MetricsDetail = c.GetBoolWithDef("/tars/application/server<metricsdetail>", false)
 
 	//add version info
 	svrCfg.Version = TarsVersion
@@ -126,7 +134,21 @@ func initConfig() {
 	}
 	if svrCfg != nil {
 		logPath := svrCfg.LogPath + "/" + svrCfg.App + "/" + svrCfg.Server + "/" + svrCfg.Server + ".log"
-		zaplog.InitZapLogger(zaplog.ProcessName(path.Base(os.Args[0])+"_rd"), zaplog.LogPath(logPath))
+		opts := []zaplog.Option{
+			zaplog.ProcessName(path.Base(os.Args[0]) + "_rd"),
+			zaplog.LogPath(logPath),
+			zaplog.Level(svrCfg.LogLevel),
+			zaplog.Encoding(svrCfg.LogEncoding),
+			zaplog.MaxAge(time.Duration(svrCfg.LogRetention) * 24 * time.Hour),
+			zaplog.WithHttpServer(svrCfg.LogHTTPServer),
+		}
+		// the framework log is rolled by size only if the logsize is set, or by day
+		if sMap["logsize"] != "" {
+			opts = append(opts, zaplog.LogSize(int(svrCfg.LogSize)), zaplog.LogNum(int(svrCfg.LogNum)))
+		}
+		if err := zaplog.InitZapLogger(opts...); err != nil {
+			zaplog.Error("init zap logger error", zap.Error(err))
+		}
 	}
 
 	//cache
@@ -153,6 +175,7 @@ func initConfig() {
 	svrCfg.StatReportInterval = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<statreportinterval>", StatReportInterval))
 	svrCfg.MainLoopTicker = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/server<mainloopticker>", MainLoopTicker))
 	svrCfg.StatReportChannelBufLen = c.GetInt32WithDef("/tars/application/server<statreportchannelbuflen>", StatReportChannelBufLen)
+	svrCfg.StatBuckets = parseStatBuckets(c.GetStringWithDef("/tars/application/server<statbuckets>", StatBuckets))
 	// maxPackageLength
 	svrCfg.MaxPackageLength = c.GetIntWithDef("/tars/application/server<maxPackageLength>", MaxPackageLength)
 	protocol.SetMaxPackageLength(svrCfg.MaxPackageLength)
@@ -179,6 +202,13 @@ func initConfig() {
 	cltCfg.ObjQueueMax = c.GetInt32WithDef("/tars/application/client<objqueuemax>", ObjQueueMax)
 	cltCfg.AdapterProxyTicker = tools.ParseTimeOut(c.GetIntWithDef("/tars/application/client<adapterproxyticker>", AdapterProxyTicker))
 	cltCfg.AdapterProxyResetCount = c.GetIntWithDef("/tars/application/client<adapterproxyresetcount>", AdapterProxyResetCount)
+	if rate := cMap["sample-rate"]; rate != "" {
+		if cltCfg.SampleRate, err = strconv.Atoi(rate); err != nil {
+			zaplog.Error("parse sample-rate error, the calls are not sampled", zap.String("SampleRate", rate), zap.Error(err))
+			cltCfg.SampleRate = 0
+		}
+	}
+	cltCfg.PropertyLabelsLimit = c.GetIntWithDef("/tars/application/client<property-labels-limit>", PropertyLabelsLimit)
 
 	for _, adapter := range serList {
 		endString := c.GetString("/tars/application/server/" + adapter + "<endpoint>")
@@ -232,6 +262,9 @@ func initConfig() {
 		//RegisterAdmin(rogger.Admin, rogger.HandleDyeingAdmin)
 	}
 
+	if svrCfg.Metrics != "" {
+		initMetrics(svrCfg)
+	}
 	go initReport()
 }
 
@@ -255,6 +288,7 @@ func Run() {
 	}
 
 	lisDone := &sync.WaitGroup{}
+	started := make(map[*transport.TarsServer]bool)
 	for _, obj := range objRunList {
 		if s, ok := httpSvrs[obj]; ok {
 			lisDone.Add(1)
@@ -291,6 +325,11 @@ func Run() {
 			teerDown(fmt.Errorf("Obj not found %s", obj))
 			break
 		}
+		if started[s] {
+			// shares the server of the servant on the same address
+			continue
+		}
+		started[s] = true
 		zaplog.Debug("Run", zap.String("Obj", obj), zap.Any("Config", s.GetConfig()))
 		lisDone.Add(1)
 		go func(obj string) {
@@ -398,6 +437,17 @@ func graceShutdown() {
 		}(&wg, obj)
 	}
 
+	if metrics != nil {
+		wg.Add(1)
+		go func(ctx context.Context, wg *sync.WaitGroup) {
+			defer wg.Done()
+			if err := metrics.shutdown(ctx); err != nil {
+				zaplog.Info("grace shutdown metrics failed", zap.Error(err))
+			}
+		}(ctx, &wg)
+	}
+
+	stopped := make(map[*transport.TarsServer]bool)
 	for _, obj := range objRunList {
 		if s, ok := httpSvrs[obj]; ok {
 			wg.Add(1)
@@ -413,7 +463,8 @@ func graceShutdown() {
 			}(s, ctx, &wg, obj)
 		}
 
-		if s, ok := goSvrs[obj]; ok {
+		if s, ok := goSvrs[obj]; ok && !stopped[s] {
+			stopped[s] = true
 			wg.Add(1)
 			go func(s *transport.TarsServer, ctx context.Context, wg *sync.WaitGroup, objstr string) {
 				defer wg.Done()
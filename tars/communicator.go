@@ -76,12 +76,14 @@ func (c *Communicator) SetLocator(obj string) {
 	c.SetProperty("locator", obj)
 }
 
-// StringToProxy sets the servant of ProxyPrx p with a string servant
-func (c *Communicator) StringToProxy(servant string, p ProxyPrx) {
+// StringToProxy sets the servant of ProxyPrx p with a string servant, the interceptors run for
+// all the requests of the proxy after the global ones.
+func (c *Communicator) StringToProxy(servant string, p ProxyPrx, interceptors ...ClientInterceptor) {
 	if servant == "" {
 		panic("empty servant")
 	}
 	sp := newServantProxy(c, servant)
+	sp.interceptors = interceptors
 	p.SetServant(sp)
 }
 
@@ -29,22 +29,25 @@ type adapterConfig struct {
 }
 
 type serverConfig struct {
-	Node     string
-	App      string
-	Server   string
-	LogPath  string
-	LogSize  uint64
-	LogNum   uint64
-	LogLevel string
-	Version  string
-	LocalIP  string
-	Local    string
-	BasePath string
-	DataPath string
-	Config   string
-	Notify   string
-	Log      string
-	Adapters map[string]adapterConfig
+	Node          string
+	App           string
+	Server        string
+	LogPath       string
+	LogSize       uint64
+	LogNum        uint64
+	LogLevel      string
+	LogEncoding   string
+	LogRetention  int
+	LogHTTPServer bool
+	Version       string
+	LocalIP       string
+	Local         string
+	BasePath      string
+	DataPath      string
+	Config        string
+	Notify        string
+	Log           string
+	Adapters      map[string]adapterConfig
 
 	Container   string
 	Isdocker    bool
@@ -71,6 +74,12 @@ type serverConfig struct {
 	StatReportChannelBufLen int32
 	MaxPackageLength        int
 	GracedownTimeout        time.Duration
+	// the upper bounds in ms of the buckets of the response time in the stat
+	StatBuckets []int32
+	// the address of the /metrics endpoint of Prometheus
+	Metrics string
+	// add the labels of the ips and the ret code to the stats on /metrics
+	MetricsDetail bool
 }
 
 type clientConfig struct {
@@ -92,4 +101,8 @@ type clientConfig struct {
 	ObjQueueMax            int32
 	AdapterProxyTicker     time.Duration
 	AdapterProxyResetCount int
+	// one in SampleRate requests is sampled for the call chains
+	SampleRate int
+	// the max number of the label sets of a labelled property
+	PropertyLabelsLimit int
 }
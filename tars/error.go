@@ -0,0 +1,124 @@
+package tars
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/basef"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+)
+
+// StatusErrorDetailPrefix is the prefix of the status keys of the response which carry the details of the Error.
+const StatusErrorDetailPrefix = "TARS_ERR_"
+
+// InvokeQueueFullCode is the code of the Error of the request which is not sent because the invoke queue of the
+// proxy is full (ObjQueueMax). It is raised on the client side only and never carried by a response.
+const InvokeQueueFullCode int32 = -100
+
+// Error is the error with the ret code across the rpc. The servant returns it to set the IRet, the SResultDesc
+// and the status of the response, and the client gets it back for any response with a non zero IRet:
+//
+//	var e *tars.Error
+//	if errors.As(err, &e) && e.Code == basef.TARSSERVERNOFUNCERR {
+//		...
+//	}
+type Error struct {
+	Code    int32
+	Message string
+	Details map[string]string
+}
+
+// NewError returns the Error with the code and the message.
+func NewError(code int32, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+// Errorf returns the Error with the code and the formatted message.
+func Errorf(code int32, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// WithDetail sets the detail of the error and returns it.
+func (e *Error) WithDetail(key, value string) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]string)
+	}
+	e.Details[key] = value
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("basef error code %d", e.Code)
+	}
+	return e.Message
+}
+
+// funcMismatch is the error of the Dispatch generated without the tars package for an unknown function.
+const funcMismatch = "func mismatch"
+
+// NoFuncError returns the error of the generated Dispatch for the function which is not in the servant.
+func NoFuncError(name string) *Error {
+	return Errorf(basef.TARSSERVERNOFUNCERR, "%s: %s", funcMismatch, name)
+}
+
+// Abort returns the error to abort the request with the ret code, the interceptors return it without calling next.
+// The code is the IRet of the response on the server side.
+func Abort(code int32, msg string) error {
+	return NewError(code, msg)
+}
+
+// setErrorResponse sets the ret code, the result and the status of the response for the error of the server.
+func setErrorResponse(resp *requestf.ResponsePacket, err error) {
+	resp.IRet = 1
+	resp.SResultDesc = err.Error()
+	var e *Error
+	if !errors.As(err, &e) {
+		if resp.SResultDesc == funcMismatch {
+			resp.IRet = basef.TARSSERVERNOFUNCERR
+		}
+		return
+	}
+	if e.Code != basef.TARSSERVERSUCCESS {
+		resp.IRet = e.Code
+	}
+	if len(e.Details) == 0 {
+		return
+	}
+	if resp.Status == nil {
+		resp.Status = make(map[string]string, len(e.Details))
+	}
+	for k, v := range e.Details {
+		resp.Status[StatusErrorDetailPrefix+k] = v
+	}
+}
+
+// ServantError returns the error the client gets for the error returned by the servant: the Error with the
+// ret code of the response, which is 1 for the errors without a code, like the Error of a real server.
+// It is used by the fakes generated by tars2go, which dispatch the requests in-process.
+func ServantError(err error) error {
+	if err == nil {
+		return nil
+	}
+	resp := &requestf.ResponsePacket{}
+	setErrorResponse(resp, err)
+	return responseError(resp)
+}
+
+// responseError returns the Error of the response with a non zero ret code, or nil if the ret code is zero.
+func responseError(resp *requestf.ResponsePacket) error {
+	if resp.IRet == basef.TARSSERVERSUCCESS {
+		return nil
+	}
+	e := &Error{Code: resp.IRet, Message: resp.SResultDesc}
+	for k, v := range resp.Status {
+		if strings.HasPrefix(k, StatusErrorDetailPrefix) {
+			if e.Details == nil {
+				e.Details = make(map[string]string)
+			}
+			e.Details[strings.TrimPrefix(k, StatusErrorDetailPrefix)] = v
+		}
+	}
+	return e
+}
@@ -0,0 +1,88 @@
+package tars
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/basef"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+)
+
+// TestErrorResponse tests the code, the message and the details of the error of the servant are got back
+// by the client from the response.
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		ret  int32
+		want *Error
+	}{
+		{
+			name: "code and details",
+			err:  NewError(1001, "invalid").WithDetail("field", "a").WithDetail("reason", "negative"),
+			ret:  1001,
+			want: &Error{Code: 1001, Message: "invalid", Details: map[string]string{"field": "a", "reason": "negative"}},
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("add: %w", Errorf(basef.TARSSERVEROVERLOAD, "busy")),
+			ret:  basef.TARSSERVEROVERLOAD,
+			want: &Error{Code: basef.TARSSERVEROVERLOAD, Message: "add: busy"},
+		},
+		{
+			name: "plain error",
+			err:  errors.New("failed"),
+			ret:  1,
+			want: &Error{Code: 1, Message: "failed"},
+		},
+		{
+			name: "no func",
+			err:  NoFuncError("nofunc"),
+			ret:  basef.TARSSERVERNOFUNCERR,
+			want: &Error{Code: basef.TARSSERVERNOFUNCERR, Message: "func mismatch: nofunc"},
+		},
+		{
+			name: "zero code with details",
+			err:  NewError(basef.TARSSERVERSUCCESS, "failed").WithDetail("field", "a"),
+			ret:  1,
+			want: &Error{Code: 1, Message: "failed", Details: map[string]string{"field": "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &requestf.ResponsePacket{Status: map[string]string{"other": "kept"}}
+			setErrorResponse(resp, tt.err)
+			if resp.IRet != tt.ret {
+				t.Fatalf("ret: got %d, want %d", resp.IRet, tt.ret)
+			}
+			if resp.Status["other"] != "kept" {
+				t.Fatalf("status is overwritten: %v", resp.Status)
+			}
+			err := responseError(resp)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("not an Error: %v", err)
+			}
+			if !reflect.DeepEqual(e, tt.want) {
+				t.Fatalf("error: got %+v, want %+v", e, tt.want)
+			}
+			if got := ServantError(tt.err); !reflect.DeepEqual(got, err) {
+				t.Fatalf("ServantError: got %+v, want %+v", got, err)
+			}
+		})
+	}
+}
+
+// TestResponseErrorSuccess tests no error is returned for the response with the zero ret code, even if its
+// status has the keys of the details, and for no error of the servant.
+func TestResponseErrorSuccess(t *testing.T) {
+	resp := &requestf.ResponsePacket{Status: map[string]string{StatusErrorDetailPrefix + "field": "a"}}
+	if err := responseError(resp); err != nil {
+		t.Fatalf("error of the response succeeded: %v", err)
+	}
+	if err := ServantError(nil); err != nil {
+		t.Fatalf("ServantError of nil: %v", err)
+	}
+}
@@ -83,7 +83,7 @@ func (mux *TarsHttpMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (mux *TarsHttpMux) reportHttpStat(st *httpStatInfo) {
-	if mux.cfg == nil || StatReport == nil {
+	if mux.cfg == nil || statCollector == nil {
 		return
 	}
 	cfg := mux.cfg
@@ -124,7 +124,7 @@ func (mux *TarsHttpMux) reportHttpStat(st *httpStatInfo) {
 	info := StatInfo{}
 	info.Head = _statInfo
 	info.Body = _statBody
-	StatReport.pushBackMsg(info, true)
+	statCollector.pushBackMsg(info, true)
 }
 
 // SetConfig sets the cfg tho the TarsHttpMux.
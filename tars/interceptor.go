@@ -0,0 +1,84 @@
+package tars
+
+import (
+	"context"
+	"time"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+)
+
+// ServerInvoker handles the request on the server side, it is the next step of a ServerInterceptor.
+type ServerInvoker func(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket) (err error)
+
+// ServerInterceptor wraps the handling of the request on the server side. It calls next to go on with
+// the chain, and may change the request and the response around it, or returns without calling next
+// to abort the request, e.g. with the error of Abort.
+type ServerInterceptor func(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket, next ServerInvoker) (err error)
+
+// ClientInterceptor wraps the request on the client side. It calls next to go on with the chain and
+// send the request, or returns without calling next to abort the request.
+type ClientInterceptor func(ctx context.Context, msg *Message, next Invoke, timeout time.Duration) (err error)
+
+type interceptors struct {
+	server []ServerInterceptor
+	client []ClientInterceptor
+}
+
+var allInterceptors = interceptors{nil, nil}
+
+// RegisterServerInterceptor registers the server interceptors for all the servants, they are executed
+// in the registered order before the ones of the servant.
+func RegisterServerInterceptor(is ...ServerInterceptor) {
+	allInterceptors.server = append(allInterceptors.server, is...)
+}
+
+// RegisterClientInterceptor registers the client interceptors for all the proxies, they are executed
+// in the registered order before the ones of the proxy.
+func RegisterClientInterceptor(is ...ClientInterceptor) {
+	allInterceptors.client = append(allInterceptors.client, is...)
+}
+
+// chainServerInterceptor runs the interceptors in order, and then the handler.
+func chainServerInterceptor(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket,
+	is []ServerInterceptor, handler ServerInvoker) error {
+	if len(is) == 0 {
+		return handler(ctx, req, resp)
+	}
+	return is[0](ctx, req, resp, func(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket) error {
+		return chainServerInterceptor(ctx, req, resp, is[1:], handler)
+	})
+}
+
+// chainClientInterceptor runs the interceptors in order, and then invoke.
+func chainClientInterceptor(ctx context.Context, msg *Message, timeout time.Duration, is []ClientInterceptor, invoke Invoke) error {
+	if len(is) == 0 {
+		return invoke(ctx, msg, timeout)
+	}
+	return is[0](ctx, msg, func(ctx context.Context, msg *Message, timeout time.Duration) error {
+		return chainClientInterceptor(ctx, msg, timeout, is[1:], invoke)
+	}, timeout)
+}
+
+// joinServerInterceptors returns the global interceptors followed by the ones of the object.
+func joinServerInterceptors(obj []ServerInterceptor) []ServerInterceptor {
+	if len(obj) == 0 {
+		return allInterceptors.server
+	}
+	if len(allInterceptors.server) == 0 {
+		return obj
+	}
+	is := make([]ServerInterceptor, 0, len(allInterceptors.server)+len(obj))
+	return append(append(is, allInterceptors.server...), obj...)
+}
+
+// joinClientInterceptors returns the global interceptors followed by the ones of the proxy.
+func joinClientInterceptors(obj []ClientInterceptor) []ClientInterceptor {
+	if len(obj) == 0 {
+		return allInterceptors.client
+	}
+	if len(allInterceptors.client) == 0 {
+		return obj
+	}
+	is := make([]ClientInterceptor, 0, len(allInterceptors.client)+len(obj))
+	return append(append(is, allInterceptors.client...), obj...)
+}
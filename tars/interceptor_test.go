@@ -0,0 +1,175 @@
+package tars
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/basef"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+)
+
+// recorder records the steps of a request in order.
+type recorder struct {
+	steps []string
+}
+
+func (r *recorder) serverInterceptor(name string, abort bool) ServerInterceptor {
+	return func(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket, next ServerInvoker) error {
+		r.steps = append(r.steps, name+">")
+		if abort {
+			return Abort(basef.TARSSERVEROVERLOAD, name+" aborted")
+		}
+		err := next(ctx, req, resp)
+		r.steps = append(r.steps, "<"+name)
+		return err
+	}
+}
+
+func (r *recorder) serverFilter(name string) ServerFilter {
+	return func(ctx context.Context, d Dispatch, f interface{}, req *requestf.RequestPacket,
+		resp *requestf.ResponsePacket, withContext bool) error {
+		r.steps = append(r.steps, name)
+		return nil
+	}
+}
+
+func (r *recorder) Dispatch(ctx context.Context, imp interface{}, req *requestf.RequestPacket,
+	resp *requestf.ResponsePacket, withContext bool) error {
+	r.steps = append(r.steps, "dispatch")
+	resp.IVersion = basef.TARSVERSION
+	resp.IRequestId = req.IRequestId
+	return nil
+}
+
+func (r *recorder) clientInterceptor(name string, abort bool) ClientInterceptor {
+	return func(ctx context.Context, msg *Message, next Invoke, timeout time.Duration) error {
+		r.steps = append(r.steps, name+">")
+		if abort {
+			return Abort(basef.TARSINVOKEBYINVALIDESET, name+" aborted")
+		}
+		err := next(ctx, msg, timeout)
+		r.steps = append(r.steps, "<"+name)
+		return err
+	}
+}
+
+// resetInterceptors clears the global interceptors and filters, and returns the function to restore them.
+func resetInterceptors() func() {
+	is, fs := allInterceptors, allFilters
+	allInterceptors, allFilters = interceptors{}, filters{}
+	return func() {
+		allInterceptors, allFilters = is, fs
+	}
+}
+
+// TestServerInterceptor tests the global interceptors run before the ones of the servant, and then the
+// filters and the dispatcher, and an interceptor aborts the request without calling next.
+func TestServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name  string
+		abort string
+		ret   int32
+		want  []string
+	}{
+		{
+			name: "chain",
+			ret:  basef.TARSSERVERSUCCESS,
+			want: []string{"g1>", "g2>", "s1>", "s2>", "pre", "dispatch", "post", "<s2", "<s1", "<g2", "<g1"},
+		},
+		{
+			name:  "abort by global",
+			abort: "g2",
+			ret:   basef.TARSSERVEROVERLOAD,
+			want:  []string{"g1>", "g2>", "<g1"},
+		},
+		{
+			name:  "abort by servant",
+			abort: "s1",
+			ret:   basef.TARSSERVEROVERLOAD,
+			want:  []string{"g1>", "g2>", "s1>", "<g2", "<g1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetInterceptors()()
+			r := &recorder{}
+			RegisterServerInterceptor(r.serverInterceptor("g1", false))
+			RegisterServerInterceptor(r.serverInterceptor("g2", tt.abort == "g2"))
+			RegisterPreServerFilter(r.serverFilter("pre"))
+			RegisterPostServerFilter(r.serverFilter("post"))
+			s := NewTarsProtocol(r, nil, false)
+			s.interceptors = []ServerInterceptor{r.serverInterceptor("s1", tt.abort == "s1"), r.serverInterceptor("s2", false)}
+
+			resp := invokeRequest(t, s, &requestf.RequestPacket{
+				IVersion:     basef.TARSVERSION,
+				IRequestId:   1,
+				SServantName: "App.Server.HelloObj",
+				SFuncName:    "echo",
+			})
+			if resp.IRet != tt.ret {
+				t.Fatalf("ret: got %d, want %d (%s)", resp.IRet, tt.ret, resp.SResultDesc)
+			}
+			if tt.abort != "" && resp.SResultDesc != tt.abort+" aborted" {
+				t.Fatalf("result: got %q", resp.SResultDesc)
+			}
+			if !reflect.DeepEqual(r.steps, tt.want) {
+				t.Fatalf("steps: got %v, want %v", r.steps, tt.want)
+			}
+		})
+	}
+}
+
+// TestClientInterceptor tests the global interceptors run before the ones of the proxy, and then the
+// filter, and an interceptor aborts the request without calling next.
+func TestClientInterceptor(t *testing.T) {
+	tests := []struct {
+		name  string
+		abort string
+		want  []string
+	}{
+		{
+			name: "chain",
+			want: []string{"g1>", "p1>", "p2>", "filter", "<p2", "<p1", "<g1"},
+		},
+		{
+			name:  "abort by global",
+			abort: "g1",
+			want:  []string{"g1>"},
+		},
+		{
+			name:  "abort by proxy",
+			abort: "p2",
+			want:  []string{"g1>", "p1>", "p2>", "<p1", "<g1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetInterceptors()()
+			r := &recorder{}
+			RegisterClientInterceptor(r.clientInterceptor("g1", tt.abort == "g1"))
+			proxy := []ClientInterceptor{r.clientInterceptor("p1", false), r.clientInterceptor("p2", tt.abort == "p2")}
+			// the filter returns without calling the rpc, which needs the endpoints
+			RegisterClientFilter(func(ctx context.Context, msg *Message, invoke Invoke, timeout time.Duration) error {
+				r.steps = append(r.steps, "filter")
+				return nil
+			})
+			s := &ServantProxy{}
+
+			err := chainClientInterceptor(context.Background(), &Message{}, time.Second, joinClientInterceptors(proxy), s.invoke)
+			if tt.abort == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.abort != "" {
+				e, ok := err.(*Error)
+				if !ok || e.Code != basef.TARSINVOKEBYINVALIDESET || e.Message != tt.abort+" aborted" {
+					t.Fatalf("abort error: got %v", err)
+				}
+			}
+			if !reflect.DeepEqual(r.steps, tt.want) {
+				t.Fatalf("steps: got %v, want %v", r.steps, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,20 @@
 package tars
 
 import (
+	"context"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
 	"github.com/MacgradyHuang/TarsGo/tars/util/rogger"
+	"github.com/MacgradyHuang/TarsGo/tars/util/zaplog"
 )
 
 // GetLogger Get a logger
@@ -63,3 +74,179 @@ func GetRemoteLogger(name string) *rogger.Logger {
 	return lg
 
 }
+
+// the name of the log of the dyed requests
+const dyeingLogName = "tars_dyeing"
+
+var zapLoggers sync.Map // kind and name -> *zap.Logger
+
+// GetZapLogger returns a zap logger rolled by the logsize and the lognum of the server config, the level
+// is the one of the module of the name, and the logs of the dyed requests are written to the dyeing log.
+func GetZapLogger(name string) *zap.Logger {
+	return getZapLogger("size", name, func(logPath, fullName string, cfg *serverConfig) (io.Writer, error) {
+		return zaplog.NewSizeWriter(logPath, fullName, int(cfg.LogNum), int(cfg.LogSize))
+	})
+}
+
+// GetDayZapLogger returns a zap logger rolled by day like GetZapLogger, the logs of numDay days are kept.
+func GetDayZapLogger(name string, numDay int) *zap.Logger {
+	return getZapLogger("day", name, func(logPath, fullName string, cfg *serverConfig) (io.Writer, error) {
+		return zaplog.NewDayWriter(logPath, fullName, numDay)
+	})
+}
+
+// GetHourZapLogger returns a zap logger rolled by hour like GetZapLogger, the logs of numHour hours are kept.
+func GetHourZapLogger(name string, numHour int) *zap.Logger {
+	return getZapLogger("hour", name, func(logPath, fullName string, cfg *serverConfig) (io.Writer, error) {
+		return zaplog.NewHourWriter(logPath, fullName, numHour)
+	})
+}
+
+// GetRemoteZapLogger returns a zap logger writing to the file of the name in the remote log server like
+// GetZapLogger.
+func GetRemoteZapLogger(name string) *zap.Logger {
+	key := "remote/" + name
+	if l, ok := zapLoggers.Load(key); ok {
+		return l.(*zap.Logger)
+	}
+	if cfg := GetServerConfig(); cfg == nil || cfg.Log == "" {
+		return GetZapLogger(name)
+	}
+	l := newZapLogger(NewRemoteZapCore(name, zapLevel(name)))
+	actual, _ := zapLoggers.LoadOrStore(key, l)
+	return actual.(*zap.Logger)
+}
+
+// zapLevel returns the level of the logger of the name, which is set by "tars.setloglevel <name> <level>"
+// or follows the level of the framework logger.
+func zapLevel(name string) zapcore.LevelEnabler {
+	if name == "" {
+		return zaplog.AtomicLevel()
+	}
+	return zaplog.ModuleLevel(name)
+}
+
+func getZapLogger(kind, name string, writer func(logPath, fullName string, cfg *serverConfig) (io.Writer, error)) *zap.Logger {
+	key := kind + "/" + name
+	if l, ok := zapLoggers.Load(key); ok {
+		return l.(*zap.Logger)
+	}
+	var core zapcore.Core
+	level := zapLevel(name)
+	cfg := GetServerConfig()
+	if cfg == nil {
+		core = zaplog.NewWriterCore(os.Stdout, level)
+	} else {
+		logPath := filepath.Join(cfg.LogPath, cfg.App, cfg.Server)
+		fullName := cfg.App + "." + cfg.Server
+		if name != "" {
+			fullName += "_" + name
+		}
+		w, err := writer(logPath, fullName, cfg)
+		if err != nil {
+			zaplog.Error("create zap logger error", zap.String("Name", fullName), zap.Error(err))
+			w = os.Stdout
+		}
+		core = zaplog.NewWriterCore(w, level)
+	}
+	actual, _ := zapLoggers.LoadOrStore(key, newZapLogger(core))
+	return actual.(*zap.Logger)
+}
+
+// newZapLogger returns the logger of the core and the dyeing core.
+func newZapLogger(core zapcore.Core) *zap.Logger {
+	if dyeing := dyeingZapCore(); dyeing != nil {
+		core = zapcore.NewTee(core, dyeing)
+	}
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.DPanicLevel))
+}
+
+var (
+	dyeingCore     zapcore.Core
+	dyeingCoreOnce sync.Once
+)
+
+// dyeingZapCore returns the core of the dyeing log, which is in the remote log server if it is
+// configured, or a local file rolled by day.
+func dyeingZapCore() zapcore.Core {
+	dyeingCoreOnce.Do(func() {
+		cfg := GetServerConfig()
+		if cfg == nil {
+			return
+		}
+		var w io.Writer
+		if cfg.Log != "" {
+			rw := NewRemoteTimeWriter()
+			var set string
+			if cfg.Enableset {
+				set = cfg.Setdivision
+			}
+			rw.InitServerInfo(cfg.App, cfg.Server, dyeingLogName, set)
+			w = remoteZapWriter{rw}
+		} else {
+			var err error
+			logPath := filepath.Join(cfg.LogPath, cfg.App, cfg.Server)
+			if w, err = zaplog.NewDayWriter(logPath, dyeingLogName, int(cfg.LogNum)); err != nil {
+				zaplog.Error("create dyeing log error", zap.Error(err))
+				return
+			}
+		}
+		dyeingCore = zaplog.NewDyeingCore(zapcore.AddSync(w))
+	})
+	return dyeingCore
+}
+
+// Logger returns the framework logger with the fields of the request in ctx, i.e. the servant, the function,
+// the request id, the client ip and port, the dyeing key and the trace id. The logs of the dyed requests
+// are written to the dyeing log as well.
+func Logger(ctx context.Context) *zap.Logger {
+	dyeing := dyeingZapCore()
+	if dyeing == nil {
+		return zaplog.FromContext(ctx)
+	}
+	// the dyeing core is added before the fields, so that it knows the request is dyed
+	l := zaplog.FromContext(context.Background()).WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, dyeing)
+	}))
+	return zaplog.WithContext(ctx, l)
+}
+
+// the status key of the W3C trace context, whose second part is the trace id
+const traceParentKey = "traceparent"
+
+// requestLogContext returns the context carrying the log fields of the request.
+func requestLogContext(ctx context.Context, req *requestf.RequestPacket) context.Context {
+	fields := []zap.Field{
+		zap.String(zaplog.ServantField, req.SServantName),
+		zap.String(zaplog.FuncField, req.SFuncName),
+		zap.Int32(zaplog.RequestIDField, req.IRequestId),
+	}
+	if ip, ok := current.GetClientIPFromContext(ctx); ok && ip != "" {
+		fields = append(fields, zap.String(zaplog.ClientIPField, ip))
+	}
+	if port, ok := current.GetClientPortFromContext(ctx); ok && port != "" {
+		fields = append(fields, zap.String(zaplog.ClientPortField, port))
+	}
+	if key, ok := current.GetDyeingKey(ctx); ok {
+		fields = append(fields, zap.String(zaplog.DyeingKeyField, key))
+	}
+	if id := traceID(req.Status); id != "" {
+		fields = append(fields, zap.String(zaplog.TraceIDField, id))
+	}
+	return zaplog.NewContext(ctx, fields...)
+}
+
+// traceID returns the trace id of the W3C trace context in the status, or the unid of the sampled call chain.
+func traceID(status map[string]string) string {
+	if tp, ok := status[traceParentKey]; ok {
+		if parts := strings.Split(tp, "-"); len(parts) == 4 {
+			return parts[1]
+		}
+	}
+	if s, ok := status[current.STATUS_SAMPLE_KEY]; ok {
+		if k, ok := parseSampleKey(s); ok {
+			return k.unid
+		}
+	}
+	return ""
+}
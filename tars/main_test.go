@@ -0,0 +1,25 @@
+package tars
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/util/zaplog"
+)
+
+// TestMain logs to a temp dir and skips loading the server config, which is not given in the tests.
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "tars")
+	if err != nil {
+		panic(err)
+	}
+	if err := zaplog.InitZapLogger(zaplog.LogPath(filepath.Join(dir, "tars.log")), zaplog.WithHttpServer(false)); err != nil {
+		panic(err)
+	}
+	initOnce.Do(func() {})
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,391 @@
+package tars
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/zaplog"
+	"go.uber.org/zap"
+)
+
+// metricsExporter keeps the stats and the properties in the process, and exposes them in the text format
+// of Prometheus. The stats are counted since the start of the process like the Prometheus counters,
+// the max and the min of the properties are the ones of the current property report interval.
+type metricsExporter struct {
+	mu         sync.Mutex
+	stats      map[string]*metricsStat // by the labels of the stat
+	props      map[string]metricsProp
+	propLabels map[string]string // the labels of the property key
+	// detail adds the labels of the ips and the ret code to the stats, which are aggregated over them if not set,
+	// as the ips of the clients and the ret codes of the business are unbounded.
+	detail bool
+	// buckets are the upper bounds in ms of the rpc duration histograms, the statbuckets of the server config.
+	buckets []int32
+
+	svr  *http.Server
+	done chan struct{}
+}
+
+type metricsStat struct {
+	count   int64
+	timeout int64
+	exec    int64
+	buckets []int64 // the observations of every bucket, the last one is +Inf
+	sum     int64   // the total rsp time in ms
+}
+
+// metricsProp keeps the values of the property by the report policy.
+type metricsProp map[ReportPolicy]*metricsValue
+
+type metricsValue struct {
+	sum      int64
+	count    int64
+	max      int64
+	min      int64
+	hasValue bool
+	ranges   []int
+	distr    []int64 // the observations of every range, the last one is +Inf
+}
+
+// metrics is nil if the /metrics endpoint is not configured.
+var metrics *metricsExporter
+
+func newMetricsExporter() *metricsExporter {
+	return &metricsExporter{
+		stats:      make(map[string]*metricsStat),
+		props:      make(map[string]metricsProp),
+		propLabels: make(map[string]string),
+		buckets:    parseStatBuckets(StatBuckets),
+	}
+}
+
+// initMetrics starts the /metrics endpoint on the address, it is stopped by shutdown.
+func initMetrics(cfg *serverConfig) {
+	m := newMetricsExporter()
+	m.detail = cfg.MetricsDetail
+	if len(cfg.StatBuckets) > 0 {
+		m.buckets = cfg.StatBuckets
+	}
+	interval := cfg.PropertyReportInterval
+	if interval <= 0 {
+		interval = time.Duration(PropertyReportInterval) * time.Millisecond
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	m.svr = &http.Server{Addr: cfg.Metrics, Handler: mux}
+	m.done = make(chan struct{})
+	go m.resetLoop(interval)
+	go func() {
+		zaplog.Info("start serve metrics", zap.String("Addr", cfg.Metrics))
+		if err := m.svr.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			zaplog.Error("serve metrics error", zap.String("Addr", cfg.Metrics), zap.Error(err))
+		}
+	}()
+	metrics = m
+}
+
+// resetLoop resets the property report interval until the exporter is shut down.
+func (m *metricsExporter) resetLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.resetInterval()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// shutdown stops the /metrics endpoint and the reset of the interval.
+func (m *metricsExporter) shutdown(ctx context.Context) error {
+	close(m.done)
+	return m.svr.Shutdown(ctx)
+}
+
+func (m *metricsExporter) observeStat(info *StatInfo, fromServer bool) {
+	key := statLabels(&info.Head, fromServer, m.detail)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.stats[key]
+	if !ok {
+		st = &metricsStat{buckets: make([]int64, len(m.buckets)+1)}
+		m.stats[key] = st
+	}
+	st.count += int64(info.Body.Count)
+	st.timeout += int64(info.Body.TimeoutCount)
+	st.exec += int64(info.Body.ExecCount)
+	st.sum += info.Body.TotalRspTime
+	i := sort.Search(len(m.buckets), func(i int) bool { return info.Body.TotalRspTime <= int64(m.buckets[i]) })
+	st.buckets[i]++
+}
+
+// addProperty adds the property with its report methods, it is exposed even if no value is reported.
+func (m *metricsExporter) addProperty(pr *PropertyReport, methods ...ReportMethod) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range methods {
+		if v != nil {
+			m.value(pr, v)
+		}
+	}
+}
+
+func (m *metricsExporter) value(pr *PropertyReport, method ReportMethod) *metricsValue {
+	p, ok := m.props[pr.key]
+	if !ok {
+		p = make(metricsProp)
+		m.props[pr.key] = p
+		m.propLabels[pr.key] = propertyLabels(pr)
+	}
+	v, ok := p[method.Enum()]
+	if !ok {
+		v = &metricsValue{}
+		if d, ok := method.(*Distr); ok {
+			v.ranges = d.dataRange
+			v.distr = make([]int64, len(d.dataRange)+1)
+		}
+		p[method.Enum()] = v
+	}
+	return v
+}
+
+func (m *metricsExporter) observeProperty(pr *PropertyReport, method ReportMethod, in int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.value(pr, method)
+	n := int64(in)
+	v.sum += n
+	v.count++
+	if !v.hasValue || n > v.max {
+		v.max = n
+	}
+	if !v.hasValue || n < v.min {
+		v.min = n
+	}
+	v.hasValue = true
+	if v.ranges != nil {
+		v.distr[sort.SearchInts(v.ranges, in)]++
+	}
+}
+
+// resetInterval starts the new property report interval for the max and the min.
+func (m *metricsExporter) resetInterval() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.props {
+		for _, v := range p {
+			v.hasValue = false
+			v.max, v.min = 0, 0
+		}
+	}
+}
+
+// ServeHTTP writes the metrics in the text format of Prometheus.
+func (m *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	m.mu.Lock()
+	m.writeStats(bw)
+	m.writeProps(bw)
+	m.mu.Unlock()
+	bw.Flush()
+}
+
+func (m *metricsExporter) writeStats(w *bufio.Writer) {
+	keys := make([]string, 0, len(m.stats))
+	for k := range m.stats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, "# HELP tars_rpc_calls_total The tars rpc calls by the result.")
+	fmt.Fprintln(w, "# TYPE tars_rpc_calls_total counter")
+	for _, k := range keys {
+		st := m.stats[k]
+		fmt.Fprintf(w, "tars_rpc_calls_total{%s,result=\"success\"} %d\n", k, st.count)
+		fmt.Fprintf(w, "tars_rpc_calls_total{%s,result=\"timeout\"} %d\n", k, st.timeout)
+		fmt.Fprintf(w, "tars_rpc_calls_total{%s,result=\"exception\"} %d\n", k, st.exec)
+	}
+	fmt.Fprintln(w, "# HELP tars_rpc_duration_seconds The response time of the tars rpc calls.")
+	fmt.Fprintln(w, "# TYPE tars_rpc_duration_seconds histogram")
+	for _, k := range keys {
+		st := m.stats[k]
+		var n int64
+		for i, b := range m.buckets {
+			n += st.buckets[i]
+			fmt.Fprintf(w, "tars_rpc_duration_seconds_bucket{%s,le=\"%s\"} %d\n", k, msToSeconds(int64(b)), n)
+		}
+		n += st.buckets[len(m.buckets)]
+		fmt.Fprintf(w, "tars_rpc_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k, n)
+		fmt.Fprintf(w, "tars_rpc_duration_seconds_sum{%s} %s\n", k, msToSeconds(st.sum))
+		fmt.Fprintf(w, "tars_rpc_duration_seconds_count{%s} %d\n", k, n)
+	}
+}
+
+func (m *metricsExporter) writeProps(w *bufio.Writer) {
+	names := make([]string, 0, len(m.props))
+	for k := range m.props {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	has := func(policy ReportPolicy) bool {
+		for _, name := range names {
+			if _, ok := m.props[name][policy]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	each := func(policy ReportPolicy, fn func(label string, v *metricsValue)) {
+		for _, name := range names {
+			if v, ok := m.props[name][policy]; ok {
+				fn(m.propLabels[name], v)
+			}
+		}
+	}
+
+	if has(ReportPolicySum) {
+		fmt.Fprintln(w, "# HELP tars_property_sum The sum of the property values, which may go down with the negative values.")
+		fmt.Fprintln(w, "# TYPE tars_property_sum gauge")
+		each(ReportPolicySum, func(label string, v *metricsValue) {
+			fmt.Fprintf(w, "tars_property_sum{%s} %d\n", label, v.sum)
+		})
+	}
+	if has(ReportPolicyCount) {
+		fmt.Fprintln(w, "# HELP tars_property_count_total The count of the property values.")
+		fmt.Fprintln(w, "# TYPE tars_property_count_total counter")
+		each(ReportPolicyCount, func(label string, v *metricsValue) {
+			fmt.Fprintf(w, "tars_property_count_total{%s} %d\n", label, v.count)
+		})
+	}
+	if has(ReportPolicyAvg) {
+		fmt.Fprintln(w, "# HELP tars_property_avg The property values to average.")
+		fmt.Fprintln(w, "# TYPE tars_property_avg summary")
+		each(ReportPolicyAvg, func(label string, v *metricsValue) {
+			fmt.Fprintf(w, "tars_property_avg_sum{%s} %d\n", label, v.sum)
+			fmt.Fprintf(w, "tars_property_avg_count{%s} %d\n", label, v.count)
+		})
+	}
+	if has(ReportPolicyMax) {
+		fmt.Fprintln(w, "# HELP tars_property_max The max of the property values in the report interval.")
+		fmt.Fprintln(w, "# TYPE tars_property_max gauge")
+		each(ReportPolicyMax, func(label string, v *metricsValue) {
+			fmt.Fprintf(w, "tars_property_max{%s} %d\n", label, v.max)
+		})
+	}
+	if has(ReportPolicyMin) {
+		fmt.Fprintln(w, "# HELP tars_property_min The min of the property values in the report interval.")
+		fmt.Fprintln(w, "# TYPE tars_property_min gauge")
+		each(ReportPolicyMin, func(label string, v *metricsValue) {
+			fmt.Fprintf(w, "tars_property_min{%s} %d\n", label, v.min)
+		})
+	}
+	if has(ReportPolicyDistr) {
+		fmt.Fprintln(w, "# HELP tars_property_distr The distribution of the property values.")
+		fmt.Fprintln(w, "# TYPE tars_property_distr histogram")
+		each(ReportPolicyDistr, func(label string, v *metricsValue) {
+			var n int64
+			for i, r := range v.ranges {
+				n += v.distr[i]
+				fmt.Fprintf(w, "tars_property_distr_bucket{%s,le=\"%d\"} %d\n", label, r, n)
+			}
+			n += v.distr[len(v.ranges)]
+			fmt.Fprintf(w, "tars_property_distr_bucket{%s,le=\"+Inf\"} %d\n", label, n)
+			fmt.Fprintf(w, "tars_property_distr_sum{%s} %d\n", label, v.sum)
+			fmt.Fprintf(w, "tars_property_distr_count{%s} %d\n", label, n)
+		})
+	}
+}
+
+// statLabels returns the labels of the dimensions of the stat, the ones of the ips and the ret code are
+// added only with detail.
+func statLabels(h *statf.StatMicMsgHead, fromServer bool, detail bool) string {
+	side := "client"
+	if fromServer {
+		side = "server"
+	}
+	set := ""
+	if h.SlaveSetName != "" {
+		set = h.SlaveSetName + "." + h.SlaveSetArea + "." + h.SlaveSetID
+	}
+	var b strings.Builder
+	b.WriteString(`side="` + side + `"`)
+	labels := [][2]string{
+		{"master", h.MasterName},
+		{"slave", h.SlaveName},
+		{"slave_set", set},
+		{"interface", h.InterfaceName},
+	}
+	if detail {
+		labels = append(labels,
+			[2]string{"master_ip", h.MasterIp},
+			[2]string{"slave_ip", h.SlaveIp},
+			[2]string{"slave_port", strconv.Itoa(int(h.SlavePort))},
+			[2]string{"ret", strconv.Itoa(int(h.ReturnValue))},
+		)
+	}
+	for _, l := range labels {
+		b.WriteString("," + l[0] + `="` + escapeLabel(l[1]) + `"`)
+	}
+	return b.String()
+}
+
+// propertyLabels returns the label of the property name followed by the labels of the report, the
+// invalid characters of the label names are replaced by "_".
+func propertyLabels(pr *PropertyReport) string {
+	keys := make([]string, 0, len(pr.labels))
+	for k := range pr.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(`property="` + escapeLabel(pr.name) + `"`)
+	for _, k := range keys {
+		b.WriteString("," + propertyLabelName(k) + `="` + escapeLabel(pr.labels[k]) + `"`)
+	}
+	return b.String()
+}
+
+// propertyLabelName returns the label name of the label of the property, which is not the label property
+// of the property name.
+func propertyLabelName(k string) string {
+	name := labelName(k)
+	if name == "property" {
+		name = "label_property"
+	}
+	return name
+}
+
+// labelName returns the valid label name of Prometheus.
+func labelName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func msToSeconds(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1000, 'f', -1, 64)
+}
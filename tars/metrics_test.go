@@ -0,0 +1,183 @@
+package tars
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+)
+
+// scrape returns the lines of the exposition of the exporter.
+func scrape(t *testing.T, m *metricsExporter) []string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type: %s", ct)
+	}
+	return strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+}
+
+// TestMetricsStats tests the stats are exposed as counters and histograms, aggregated over the ips and the ret
+// codes unless detail is set.
+func TestMetricsStats(t *testing.T) {
+	stats := []StatInfo{
+		{
+			Head: statf.StatMicMsgHead{MasterName: "App.Client", MasterIp: "10.0.0.1", SlaveName: "App.Server.HelloObj",
+				SlaveIp: "10.0.0.9", SlavePort: 10015, InterfaceName: "echo", ReturnValue: 0},
+			Body: statf.StatMicMsgBody{Count: 1, TotalRspTime: 3},
+		},
+		{
+			Head: statf.StatMicMsgHead{MasterName: "App.Client", MasterIp: "10.0.0.2", SlaveName: "App.Server.HelloObj",
+				SlaveIp: "10.0.0.9", SlavePort: 10015, InterfaceName: "echo", ReturnValue: -7},
+			Body: statf.StatMicMsgBody{TimeoutCount: 1, TotalRspTime: 5000},
+		},
+	}
+	tests := []struct {
+		name    string
+		detail  bool
+		buckets []int32
+		want    []string
+	}{
+		{
+			name: "aggregated",
+			want: []string{
+				"# TYPE tars_rpc_calls_total counter",
+				`tars_rpc_calls_total{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",result="success"} 1`,
+				`tars_rpc_calls_total{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",result="timeout"} 1`,
+				`tars_rpc_calls_total{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",result="exception"} 0`,
+				"# TYPE tars_rpc_duration_seconds histogram",
+				`tars_rpc_duration_seconds_bucket{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",le="0.005"} 1`,
+				`tars_rpc_duration_seconds_bucket{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",le="3"} 1`,
+				`tars_rpc_duration_seconds_bucket{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",le="+Inf"} 2`,
+				`tars_rpc_duration_seconds_sum{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo"} 5.003`,
+				`tars_rpc_duration_seconds_count{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo"} 2`,
+			},
+		},
+		{
+			name:   "detail",
+			detail: true,
+			want: []string{
+				`tars_rpc_calls_total{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",master_ip="10.0.0.1",slave_ip="10.0.0.9",slave_port="10015",ret="0",result="success"} 1`,
+				`tars_rpc_calls_total{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",master_ip="10.0.0.2",slave_ip="10.0.0.9",slave_port="10015",ret="-7",result="timeout"} 1`,
+				`tars_rpc_duration_seconds_count{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",master_ip="10.0.0.2",slave_ip="10.0.0.9",slave_port="10015",ret="-7"} 1`,
+			},
+		},
+		{
+			name:    "statbuckets",
+			buckets: []int32{1, 6000},
+			want: []string{
+				`tars_rpc_duration_seconds_bucket{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",le="0.001"} 0`,
+				`tars_rpc_duration_seconds_bucket{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",le="6"} 2`,
+				`tars_rpc_duration_seconds_bucket{side="server",master="App.Client",slave="App.Server.HelloObj",slave_set="",interface="echo",le="+Inf"} 2`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMetricsExporter()
+			m.detail = tt.detail
+			if tt.buckets != nil {
+				m.buckets = tt.buckets
+			}
+			for i := range stats {
+				m.observeStat(&stats[i], true)
+			}
+			lines := scrape(t, m)
+			for _, want := range tt.want {
+				if !containsLine(lines, want) {
+					t.Errorf("%s not found in\n%s", want, strings.Join(lines, "\n"))
+				}
+			}
+			if !tt.detail {
+				for _, l := range lines {
+					if strings.Contains(l, "_ip=") || strings.Contains(l, "ret=") {
+						t.Fatalf("label of the detail is exposed: %s", l)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestMetricsProperties tests the properties are exposed by their policies, and the max and the min are the
+// ones of the current interval.
+func TestMetricsProperties(t *testing.T) {
+	m := newMetricsExporter()
+	pr := &PropertyReport{key: "request{api=login}", name: "request", labels: map[string]string{"api": "login"}}
+	distr := NewDistr([]int{10, 100})
+	methods := []ReportMethod{NewSum(), NewCount(), NewAvg(), NewMax(), NewMin(), distr}
+	m.addProperty(pr, methods...)
+	empty := &PropertyReport{key: "idle", name: "idle"}
+	m.addProperty(empty, NewSum())
+
+	for _, v := range []int{5, 50, 500} {
+		for _, method := range methods {
+			m.observeProperty(pr, method, v)
+		}
+	}
+	labels := `property="request",api="login"`
+	want := []string{
+		"# TYPE tars_property_sum gauge",
+		"tars_property_sum{" + labels + "} 555",
+		`tars_property_sum{property="idle"} 0`,
+		"tars_property_count_total{" + labels + "} 3",
+		"# TYPE tars_property_avg summary",
+		"tars_property_avg_sum{" + labels + "} 555",
+		"tars_property_avg_count{" + labels + "} 3",
+		"# TYPE tars_property_max gauge",
+		"tars_property_max{" + labels + "} 500",
+		"tars_property_min{" + labels + "} 5",
+		"# TYPE tars_property_distr histogram",
+		"tars_property_distr_bucket{" + labels + `,le="10"} 1`,
+		"tars_property_distr_bucket{" + labels + `,le="100"} 2`,
+		"tars_property_distr_bucket{" + labels + `,le="+Inf"} 3`,
+		"tars_property_distr_count{" + labels + "} 3",
+	}
+	lines := scrape(t, m)
+	for _, w := range want {
+		if !containsLine(lines, w) {
+			t.Errorf("%s not found in\n%s", w, strings.Join(lines, "\n"))
+		}
+	}
+
+	m.resetInterval()
+	m.observeProperty(pr, methods[3], 20)
+	m.observeProperty(pr, methods[4], 20)
+	lines = scrape(t, m)
+	for _, w := range []string{"tars_property_max{" + labels + "} 20", "tars_property_min{" + labels + "} 20",
+		"tars_property_sum{" + labels + "} 555"} {
+		if !containsLine(lines, w) {
+			t.Errorf("%s not found after the reset in\n%s", w, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+// TestMetricsShutdown tests the endpoint and the reset of the interval are stopped by shutdown.
+func TestMetricsShutdown(t *testing.T) {
+	defer func(m *metricsExporter) { metrics = m }(metrics)
+	initMetrics(&serverConfig{Metrics: "127.0.0.1:0", PropertyReportInterval: time.Millisecond})
+	m := metrics
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-m.done:
+	default:
+		t.Fatal("reset loop is not stopped")
+	}
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, l := range lines {
+		if l == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/MacgradyHuang/TarsGo/tars"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
+)
+
+const instrumentationName = "github.com/MacgradyHuang/TarsGo/tars/plugin/otel"
+
+// the attributes of the spans and the metrics besides the ones of the semantic conventions
+const (
+	attrRetCode = attribute.Key("tars.ret_code")
+	attrVersion = attribute.Key("tars.version")
+)
+
+// statusCarrier carries the trace context in the status of the request.
+type statusCarrier map[string]string
+
+func (c statusCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c statusCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c statusCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ClientInterceptor returns the tars client interceptor, which starts the client span of the request
+// and records the duration in rpc.client.duration.
+func ClientInterceptor() tars.ClientInterceptor {
+	tracer := otel.Tracer(instrumentationName)
+	duration, _ := otel.Meter(instrumentationName).Float64Histogram("rpc.client.duration",
+		metric.WithDescription("The duration of the tars requests of the clients."), metric.WithUnit("ms"))
+	return func(ctx context.Context, msg *tars.Message, next tars.Invoke, timeout time.Duration) error {
+		req := msg.Req
+		ctx, span := tracer.Start(ctx, req.SServantName+"/"+req.SFuncName,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(rpcAttributes(req)...),
+		)
+		defer span.End()
+		if req.Status == nil {
+			req.Status = make(map[string]string)
+		}
+		otel.GetTextMapPropagator().Inject(ctx, statusCarrier(req.Status))
+
+		start := time.Now()
+		err := next(ctx, msg, timeout)
+		cost := float64(time.Since(start)) / float64(time.Millisecond)
+
+		var ret int32
+		if msg.Resp != nil {
+			ret = msg.Resp.IRet
+		}
+		if err != nil {
+			ret = retCode(err, ret)
+		}
+		attrs := append(rpcAttributes(req), attrRetCode.Int64(int64(ret)))
+		if msg.Adp != nil {
+			if ep := msg.Adp.GetPoint(); ep != nil {
+				span.SetAttributes(semconv.ServerAddress(ep.Host), semconv.ServerPort(int(ep.Port)))
+			}
+		}
+		span.SetAttributes(attrRetCode.Int64(int64(ret)))
+		endSpan(span, err)
+		duration.Record(ctx, cost, metric.WithAttributes(attrs...))
+		return err
+	}
+}
+
+// ServerInterceptor returns the tars server interceptor, which starts the server span of the request
+// as the child of the client span in the traceparent of the status, or as a new root span without it,
+// and records the duration in rpc.server.duration. The span is in the context passed to the servant,
+// so the client spans of the calls made by the servant are its children.
+func ServerInterceptor() tars.ServerInterceptor {
+	tracer := otel.Tracer(instrumentationName)
+	duration, _ := otel.Meter(instrumentationName).Float64Histogram("rpc.server.duration",
+		metric.WithDescription("The duration of the tars requests of the servers."), metric.WithUnit("ms"))
+	return func(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket, next tars.ServerInvoker) error {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, statusCarrier(req.Status))
+		ctx, span := tracer.Start(ctx, req.SServantName+"/"+req.SFuncName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(rpcAttributes(req)...),
+		)
+		defer span.End()
+		if ip, ok := current.GetClientIPFromContext(ctx); ok {
+			span.SetAttributes(semconv.ClientAddress(ip))
+		}
+		if port, ok := current.GetClientPortFromContext(ctx); ok {
+			if p, err := strconv.Atoi(port); err == nil {
+				span.SetAttributes(semconv.ClientPort(p))
+			}
+		}
+		if cfg := tars.GetServerConfig(); cfg != nil && cfg.Enableset {
+			span.SetAttributes(attribute.String("tars.set_division", cfg.Setdivision))
+		}
+
+		start := time.Now()
+		err := next(ctx, req, resp)
+		cost := float64(time.Since(start)) / float64(time.Millisecond)
+
+		ret := resp.IRet
+		if err != nil {
+			ret = retCode(err, 1)
+		}
+		span.SetAttributes(attrRetCode.Int64(int64(ret)))
+		endSpan(span, err)
+		duration.Record(ctx, cost, metric.WithAttributes(append(rpcAttributes(req), attrRetCode.Int64(int64(ret)))...))
+		return err
+	}
+}
+
+func rpcAttributes(req *requestf.RequestPacket) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.RPCSystemKey.String("tars"),
+		semconv.RPCService(req.SServantName),
+		semconv.RPCMethod(req.SFuncName),
+		attrVersion.Int(int(req.IVersion)),
+	}
+}
+
+// retCode returns the code of the tars.Error, or def for the other errors.
+func retCode(err error, def int32) int32 {
+	var e *tars.Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return def
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,201 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/MacgradyHuang/TarsGo/tars"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+)
+
+// setTestProviders sets the global providers to record the spans and the metrics in memory, and returns the
+// function to restore them.
+func setTestProviders() (*tracetest.InMemoryExporter, *sdkmetric.ManualReader, func()) {
+	tp, mp, prop := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	exporter := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return exporter, reader, func() {
+		otel.SetTracerProvider(tp)
+		otel.SetMeterProvider(mp)
+		otel.SetTextMapPropagator(prop)
+	}
+}
+
+// call sends the request by the client interceptor to the server interceptor, and the servant returns err.
+func call(ctx context.Context, ret int32, err error) error {
+	client, server := ClientInterceptor(), ServerInterceptor()
+	msg := &tars.Message{Req: &requestf.RequestPacket{IVersion: 1, SServantName: "App.Server.HelloObj", SFuncName: "echo"}}
+	return client(ctx, msg, func(ctx context.Context, msg *tars.Message, timeout time.Duration) error {
+		// the status goes over the wire without the context of the client
+		req := *msg.Req
+		msg.Resp = &requestf.ResponsePacket{}
+		serr := server(context.Background(), &req, msg.Resp, func(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket) error {
+			resp.IRet = ret
+			return err
+		})
+		if serr != nil {
+			msg.Resp.IRet = retCode(serr, 1)
+		}
+		return serr
+	}, time.Second)
+}
+
+func spanAttr(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// TestInterceptors tests the server span is the child of the client span by the traceparent in the status, and
+// both have the attributes of the request and the ret code.
+func TestInterceptors(t *testing.T) {
+	exporter, reader, restore := setTestProviders()
+	defer restore()
+
+	if err := call(context.Background(), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	// the server span ends first
+	server, client := spans[0], spans[1]
+	if client.SpanKind != trace.SpanKindClient || server.SpanKind != trace.SpanKindServer {
+		t.Fatalf("kinds: client %v, server %v", client.SpanKind, server.SpanKind)
+	}
+	if client.Parent.IsValid() {
+		t.Fatalf("client span has the parent %v", client.Parent)
+	}
+	if !server.Parent.IsRemote() || server.Parent.SpanID() != client.SpanContext.SpanID() ||
+		server.SpanContext.TraceID() != client.SpanContext.TraceID() {
+		t.Fatalf("server span is not the child of the client span: parent %v, client %v", server.Parent, client.SpanContext)
+	}
+	for _, s := range spans {
+		if s.Name != "App.Server.HelloObj/echo" {
+			t.Errorf("name: %s", s.Name)
+		}
+		if got := spanAttr(s, "rpc.system").AsString(); got != "tars" {
+			t.Errorf("%v rpc.system: %s", s.SpanKind, got)
+		}
+		if got := spanAttr(s, "rpc.service").AsString(); got != "App.Server.HelloObj" {
+			t.Errorf("%v rpc.service: %s", s.SpanKind, got)
+		}
+		if got := spanAttr(s, "rpc.method").AsString(); got != "echo" {
+			t.Errorf("%v rpc.method: %s", s.SpanKind, got)
+		}
+		if got := spanAttr(s, attrVersion).AsInt64(); got != 1 {
+			t.Errorf("%v tars.version: %d", s.SpanKind, got)
+		}
+		if v := spanAttr(s, attrRetCode); v.Type() != attribute.INT64 || v.AsInt64() != 0 {
+			t.Errorf("%v tars.ret_code: %v", s.SpanKind, v.Emit())
+		}
+		if s.Status.Code != codes.Unset {
+			t.Errorf("%v status: %v", s.SpanKind, s.Status)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]uint64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok {
+				for _, dp := range h.DataPoints {
+					counts[m.Name] += dp.Count
+				}
+			}
+		}
+	}
+	if counts["rpc.client.duration"] != 1 || counts["rpc.server.duration"] != 1 {
+		t.Fatalf("durations: %v", counts)
+	}
+}
+
+// TestInterceptorsError tests the error of the servant is recorded in both spans with its code.
+func TestInterceptorsError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int64
+	}{
+		{name: "tars error", err: &tars.Error{Code: -3, Message: "bad request"}, want: -3},
+		{name: "other error", err: errors.New("failed"), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, _, restore := setTestProviders()
+			defer restore()
+
+			if err := call(context.Background(), 0, tt.err); err != tt.err {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("got %d spans, want 2", len(spans))
+			}
+			for _, s := range spans {
+				if s.Status.Code != codes.Error || s.Status.Description != tt.err.Error() {
+					t.Errorf("%v status: %v", s.SpanKind, s.Status)
+				}
+				if got := spanAttr(s, attrRetCode).AsInt64(); got != tt.want {
+					t.Errorf("%v tars.ret_code: got %d, want %d", s.SpanKind, got, tt.want)
+				}
+				if len(s.Events) != 1 || s.Events[0].Name != "exception" {
+					t.Errorf("%v error is not recorded: %v", s.SpanKind, s.Events)
+				}
+			}
+		})
+	}
+}
+
+// TestServerInterceptorRoot tests the server span is a new root without the traceparent in the status, and the
+// client span of the call made by the servant is its child.
+func TestServerInterceptorRoot(t *testing.T) {
+	exporter, _, restore := setTestProviders()
+	defer restore()
+
+	server, client := ServerInterceptor(), ClientInterceptor()
+	req := &requestf.RequestPacket{SServantName: "App.Server.HelloObj", SFuncName: "echo"}
+	err := server(context.Background(), req, &requestf.ResponsePacket{}, func(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket) error {
+		msg := &tars.Message{Req: &requestf.RequestPacket{SServantName: "Peer.Server.HelloObj", SFuncName: "hello"}}
+		return client(ctx, msg, func(ctx context.Context, msg *tars.Message, timeout time.Duration) error {
+			msg.Resp = &requestf.ResponsePacket{}
+			return nil
+		}, time.Second)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	call, span := spans[0], spans[1]
+	if span.SpanKind != trace.SpanKindServer || span.Parent.IsValid() {
+		t.Fatalf("server span is not a root: %v %v", span.SpanKind, span.Parent)
+	}
+	if call.SpanKind != trace.SpanKindClient || call.Parent.SpanID() != span.SpanContext.SpanID() {
+		t.Fatalf("client span is not the child of the server span: %v %v", call.SpanKind, call.Parent)
+	}
+}
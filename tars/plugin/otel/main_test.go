@@ -0,0 +1,25 @@
+package otel
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain runs in a temp dir, as tars logs to the working dir without the server config.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "otel")
+	if err != nil {
+		panic(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.Chdir(wd)
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
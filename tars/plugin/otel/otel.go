@@ -0,0 +1,155 @@
+// Package otel is the OpenTelemetry plugin of tars, it traces the requests and records the durations
+// by the interceptors of the clients and the servers. The trace context is carried by the W3C
+// traceparent and tracestate in the status of the request.
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"github.com/MacgradyHuang/TarsGo/tars"
+)
+
+// the exporters of Config
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// Config is the config of the tracer provider and the meter provider.
+type Config struct {
+	// ServiceName is the service.name of the resource, App.Server of the server config if empty.
+	ServiceName string
+	// Exporter is otlp, stdout or file, the default is otlp.
+	Exporter string
+	// Endpoint is the host:port of the otlp collector over http, OTEL_EXPORTER_OTLP_ENDPOINT or
+	// localhost:4318 is used if empty.
+	Endpoint string
+	// Insecure sends to the otlp collector without tls.
+	Insecure bool
+	// File is the path of the file exporter, the spans and the metrics are appended as json.
+	File string
+	// SampleRatio is the ratio of the new traces to sample, all of them are sampled if 0.
+	// The requests with a sampled parent are always sampled.
+	SampleRatio float64
+	// MetricInterval is the interval to export the metrics, the default is 1 minute.
+	MetricInterval time.Duration
+}
+
+// Init sets the global tracer provider, meter provider and propagator of OpenTelemetry by the config.
+// The returned function flushes and stops the exporters, it should be called before the process exits.
+// The interceptors are registered by tars.RegisterServerInterceptor(ServerInterceptor()) and
+// tars.RegisterClientInterceptor(ClientInterceptor()).
+func Init(cfg Config) (shutdown func(context.Context) error, err error) {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = defaultServiceName()
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		spanExporter   sdktrace.SpanExporter
+		metricExporter sdkmetric.Exporter
+		file           *os.File
+	)
+	ctx := context.Background()
+	switch cfg.Exporter {
+	case "", ExporterOTLP:
+		traceOpts := []otlptracehttp.Option{}
+		metricOpts := []otlpmetrichttp.Option{}
+		if cfg.Endpoint != "" {
+			traceOpts = append(traceOpts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+			metricOpts = append(metricOpts, otlpmetrichttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			traceOpts = append(traceOpts, otlptracehttp.WithInsecure())
+			metricOpts = append(metricOpts, otlpmetrichttp.WithInsecure())
+		}
+		if spanExporter, err = otlptracehttp.New(ctx, traceOpts...); err != nil {
+			return nil, err
+		}
+		if metricExporter, err = otlpmetrichttp.New(ctx, metricOpts...); err != nil {
+			return nil, err
+		}
+	case ExporterStdout, ExporterFile:
+		var w io.Writer = os.Stdout
+		if cfg.Exporter == ExporterFile {
+			if cfg.File == "" {
+				return nil, errors.New("otel: file of the file exporter is empty")
+			}
+			if file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+				return nil, err
+			}
+			w = file
+		}
+		if spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(w)); err != nil {
+			return nil, err
+		}
+		if metricExporter, err = stdoutmetric.New(stdoutmetric.WithWriter(w)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("otel: unknown exporter %s", cfg.Exporter)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	interval := cfg.MetricInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(interval))),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if e := mp.Shutdown(ctx); err == nil {
+			err = e
+		}
+		if file != nil {
+			if e := file.Close(); err == nil {
+				err = e
+			}
+		}
+		return err
+	}, nil
+}
+
+func defaultServiceName() string {
+	if cfg := tars.GetServerConfig(); cfg != nil && cfg.App != "" {
+		return cfg.App + "." + cfg.Server
+	}
+	return "tars"
+}
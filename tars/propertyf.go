@@ -316,8 +316,11 @@ func (c *Count) clear() {
 type PropertyReportHelper struct {
 	reportPtrs *sync.Map //string -> *PropertyReport
 	comm       *Communicator
-	pf         *propertyf.PropertyF
+	sink       PropertySink
 	node       string
+
+	mu       sync.Mutex
+	labelled map[string]int // name -> the number of the label sets
 }
 
 // ProHelper is global PropertyReportHelper instance
@@ -329,34 +332,32 @@ func (p *PropertyReportHelper) ReportToServer() {
 	cfg := GetServerConfig()
 	statMsg := make(map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody)
 
-	var head propertyf.StatPropMsgHead
-	head.IPropertyVer = 2
+	var base propertyf.StatPropMsgHead
+	base.IPropertyVer = 2
 	if cfg != nil {
 		if cfg.Enableset {
 			setList := strings.Split(cfg.Setdivision, ".")
-			head.ModuleName = cfg.App + "." + cfg.Server + "." + setList[0] + setList[1] + setList[2]
-			head.SetName = setList[0]
-			head.SetArea = setList[1]
-			head.SetID = setList[2]
+			base.ModuleName = cfg.App + "." + cfg.Server + "." + setList[0] + setList[1] + setList[2]
+			base.SetName = setList[0]
+			base.SetArea = setList[1]
+			base.SetID = setList[2]
 		} else {
-			head.ModuleName = cfg.App + "." + cfg.Server
+			base.ModuleName = cfg.App + "." + cfg.Server
 		}
 	} else {
 		return
 	}
-	head.Ip = cfg.LocalIP
-	//head.SContainer = cfg.Container
+	base.Ip = cfg.LocalIP
+	//base.SContainer = cfg.Container
 
 	p.reportPtrs.Range(func(key, val interface{}) bool {
 		v := val.(*PropertyReport)
-		head.PropertyName = v.key
+		head := base
+		v.fillHead(&head)
 
 		var body propertyf.StatPropMsgBody
 		body.VInfo = make([]propertyf.StatPropInfo, 0)
-		for _, m := range v.reportMethods {
-			if nil == m {
-				continue
-			}
+		for _, m := range v.methods() {
 
 			var info propertyf.StatPropInfo
 			bflag := false
@@ -405,12 +406,16 @@ func (p *PropertyReportHelper) ReportToServer() {
 		return true
 	})
 
+	sink := p.getSink()
+	if sink == nil {
+		return
+	}
 	var cnt int
 	var tmpStatMsg = make(map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody)
 	for k, v := range statMsg {
 		cnt++
 		if cnt >= 20 {
-			_, err := p.pf.ReportPropMsg(tmpStatMsg)
+			err := sink.ReportPropMsg(tmpStatMsg)
 			if err != nil {
 				zaplog.Error("Send to property server Error", zap.Any("Type", reflect.TypeOf(err)), zap.Error(err))
 			}
@@ -419,28 +424,43 @@ func (p *PropertyReportHelper) ReportToServer() {
 		tmpStatMsg[k] = v
 	}
 	if len(tmpStatMsg) > 0 {
-		_, err := p.pf.ReportPropMsg(tmpStatMsg)
+		err := sink.ReportPropMsg(tmpStatMsg)
 		if err != nil {
 			zaplog.Error("Send to property server Error", zap.Any("Type", reflect.TypeOf(err)), zap.Error(err))
 		}
 	}
 }
 
-// Init inits the PropertyReportHelper
+// Init inits the PropertyReportHelper with the property of the client config, the properties are only
+// reported to the sink of SetPropertySink if node is empty.
 func (p *PropertyReportHelper) Init(comm *Communicator, node string) {
 	p.node = node
 	p.comm = comm
-	p.pf = new(propertyf.PropertyF)
 	p.reportPtrs = new(sync.Map)
-	p.comm.StringToProxy(p.node, p.pf)
+	if p.node != "" {
+		sink, err := newPropertySink(p.comm, p.node)
+		if err != nil {
+			zaplog.Error("create property sink error", zap.String("Property", p.node), zap.Error(err))
+		}
+		p.sink = sink
+	}
+}
+
+// getSink returns the sink of SetPropertySink or the one of the config.
+func (p *PropertyReportHelper) getSink() PropertySink {
+	if sink := getPropertySink(); sink != nil {
+		return sink
+	}
+	return p.sink
 }
 
 func initProReport() {
-	if GetClientConfig() == nil || GetClientConfig().Property == "" {
+	// keeps the property reports for the metrics even if the property is not reported
+	ProHelper = &PropertyReportHelper{reportPtrs: new(sync.Map)}
+	if GetClientConfig() == nil {
 		return
 	}
 	comm := NewCommunicator()
-	ProHelper = new(PropertyReportHelper)
 	ProHelper.Init(comm, GetClientConfig().Property)
 	go ProHelper.Run()
 }
@@ -459,31 +479,245 @@ func (p *PropertyReportHelper) Run() {
 	}
 }
 
+// labelledReport returns the report of the name and the labels, or the one with the label
+// PropertyLabelOverflow if the name has reached the limit of the label sets. The dropped labels are
+// logged when the report is created.
+func (p *PropertyReportHelper) labelledReport(name string, labels map[string]string, dropped []string) *PropertyReport {
+	key := propertyKey(name, labels)
+	if val, ok := p.reportPtrs.Load(key); ok {
+		return val.(*PropertyReport)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if val, ok := p.reportPtrs.Load(key); ok {
+		return val.(*PropertyReport)
+	}
+	if len(dropped) > 0 {
+		zaplog.Error("labels of the property collide on /metrics, they are dropped",
+			zap.String("Name", name), zap.Strings("Labels", dropped))
+	}
+	limit := PropertyLabelsLimit
+	if cfg := GetClientConfig(); cfg != nil && cfg.PropertyLabelsLimit > 0 {
+		limit = cfg.PropertyLabelsLimit
+	}
+	if p.labelled == nil {
+		p.labelled = make(map[string]int)
+	}
+	if p.labelled[name] >= limit {
+		labels = map[string]string{PropertyLabelOverflow: "true"}
+		key = propertyKey(name, labels)
+		if val, ok := p.reportPtrs.Load(key); ok {
+			return val.(*PropertyReport)
+		}
+		zaplog.Warn("too many label sets of the property, the new ones are reported as overflow",
+			zap.String("Name", name), zap.Int("Limit", limit))
+	} else {
+		p.labelled[name]++
+	}
+	ptr := newPropertyReport(key, name, labels)
+	p.AddToReport(ptr)
+	return ptr
+}
+
+// the labels of the labelled property reports for the fields of the StatPropMsgHead, the other labels
+// are reported in the property name as name{label1=value1,label2=value2}.
+const (
+	PropertyLabelIP        = "ip"
+	PropertyLabelSet       = "set" // the set division like name.area.id
+	PropertyLabelContainer = "container"
+	// PropertyLabelOverflow is the only label of the report of the new label sets over the limit.
+	PropertyLabelOverflow = "overflow"
+)
+
 // PropertyReport property report struct
 type PropertyReport struct {
 	key           string
+	name          string
+	labels        map[string]string
+	mu            sync.RWMutex // guards reportMethods
 	reportMethods []ReportMethod
 }
 
+func newPropertyReport(key, name string, labels map[string]string) *PropertyReport {
+	return &PropertyReport{
+		key:           key,
+		name:          name,
+		labels:        labels,
+		reportMethods: make([]ReportMethod, 7),
+	}
+}
+
+// propertyKey returns the name followed by the labels sorted by the label.
+func propertyKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k + "=" + labels[k])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// uniqueLabels returns the labels without the ones whose label names on /metrics collide with the former
+// ones in the sorted order, e.g. a_b is dropped for a-b, and the keys of the dropped labels.
+func uniqueLabels(labels map[string]string) (map[string]string, []string) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	names := make(map[string]bool, len(keys))
+	var dropped []string
+	for _, k := range keys {
+		name := propertyLabelName(k)
+		if names[name] {
+			dropped = append(dropped, k)
+			continue
+		}
+		names[name] = true
+	}
+	if len(dropped) == 0 {
+		return labels, nil
+	}
+	unique := make(map[string]string, len(labels)-len(dropped))
+	for k, v := range labels {
+		unique[k] = v
+	}
+	for _, k := range dropped {
+		delete(unique, k)
+	}
+	return unique, dropped
+}
+
+// fillHead sets the property name and the fields of the labels to the head.
+func (p *PropertyReport) fillHead(head *propertyf.StatPropMsgHead) {
+	if len(p.labels) == 0 {
+		head.PropertyName = p.key
+		return
+	}
+	others := make(map[string]string, len(p.labels))
+	for k, v := range p.labels {
+		switch k {
+		case PropertyLabelIP:
+			head.Ip = v
+		case PropertyLabelSet:
+			if setList := strings.Split(v, "."); len(setList) == 3 {
+				head.SetName = setList[0]
+				head.SetArea = setList[1]
+				head.SetID = setList[2]
+			} else {
+				others[k] = v
+			}
+		case PropertyLabelContainer:
+			head.SContainer = v
+		default:
+			others[k] = v
+		}
+	}
+	head.PropertyName = propertyKey(p.name, others)
+}
+
 // Report reports a value.
 func (p *PropertyReport) Report(in int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	for _, v := range p.reportMethods {
 		if v != nil {
-			v.Set(in)
+			p.set(v, in)
+		}
+	}
+}
+
+// methods returns the report methods set.
+func (p *PropertyReport) methods() []ReportMethod {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ms := make([]ReportMethod, 0, len(p.reportMethods))
+	for _, m := range p.reportMethods {
+		if m != nil {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// addMethods sets the methods, the existing ones are replaced if replace is true, and returns the methods set.
+func (p *PropertyReport) addMethods(replace bool, argvs ...ReportMethod) []ReportMethod {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var added []ReportMethod
+	for _, v := range argvs {
+		if replace || p.reportMethods[v.Enum()] == nil {
+			p.reportMethods[v.Enum()] = v
+			added = append(added, v)
 		}
 	}
+	return added
+}
+
+// method returns the method of the policy, it is created by newMethod if not set.
+func (p *PropertyReport) method(policy ReportPolicy, newMethod func() ReportMethod) ReportMethod {
+	p.mu.RLock()
+	m := p.reportMethods[policy]
+	p.mu.RUnlock()
+	if m != nil {
+		return m
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reportMethods[policy] == nil {
+		p.reportMethods[policy] = newMethod()
+	}
+	return p.reportMethods[policy]
+}
+
+func (p *PropertyReport) set(m ReportMethod, in int) {
+	m.Set(in)
+	if metrics != nil {
+		metrics.observeProperty(p, m, in)
+	}
 }
 
 // CreatePropertyReport creats the property report instance with the key.
 func CreatePropertyReport(key string, argvs ...ReportMethod) *PropertyReport {
 	ptr := GetPropertyReport(key)
-	for _, v := range argvs {
-		ptr.reportMethods[v.Enum()] = v
+	ptr.addMethods(true, argvs...)
+	if metrics != nil {
+		metrics.addProperty(ptr, argvs...)
 	}
 
 	return ptr
 }
 
+// CreatePropertyReportWithLabels creates the property report instance with the name and the labels, it
+// returns the same instance for the same name and labels, and the methods of the existing instance are
+// kept, so it may be called for every report. The labels ip, set and container are reported in the
+// fields of the StatPropMsgHead, and all the labels are the labels of /metrics, a label whose name on
+// /metrics collides with another one, like a_b with a-b, is dropped. The label sets of a name
+// are limited by property-labels-limit in the client config, the default is PropertyLabelsLimit.
+func CreatePropertyReportWithLabels(name string, labels map[string]string, argvs ...ReportMethod) *PropertyReport {
+	proOnce.Do(initProReport)
+	labels, dropped := uniqueLabels(labels)
+	ptr := ProHelper.labelledReport(name, labels, dropped)
+	added := ptr.addMethods(false, argvs...)
+	if metrics != nil && len(added) > 0 {
+		metrics.addProperty(ptr, added...)
+	}
+	return ptr
+}
+
 // GetPropertyReport gets the property report instance with the key.
 func GetPropertyReport(key string) *PropertyReport {
 	proOnce.Do(initProReport)
@@ -493,9 +727,7 @@ func GetPropertyReport(key string) *PropertyReport {
 		}
 	}
 
-	ptr := new(PropertyReport)
-	ptr.key = key
-	ptr.reportMethods = make([]ReportMethod, 7)
+	ptr := newPropertyReport(key, key, nil)
 	ProHelper.AddToReport(ptr)
 
 	return ptr
@@ -504,59 +736,35 @@ func GetPropertyReport(key string) *PropertyReport {
 // ReportSum sum report
 func ReportSum(key string, i int) {
 	ptr := GetPropertyReport(key)
-	policy := ReportPolicySum
-	if nil == ptr.reportMethods[policy] {
-		ptr.reportMethods[policy] = NewSum()
-	}
-	ptr.reportMethods[policy].Set(i)
+	ptr.set(ptr.method(ReportPolicySum, func() ReportMethod { return NewSum() }), i)
 }
 
 // ReportAvg avg report
 func ReportAvg(key string, i int) {
 	ptr := GetPropertyReport(key)
-	policy := ReportPolicyAvg
-	if nil == ptr.reportMethods[policy] {
-		ptr.reportMethods[policy] = NewAvg()
-	}
-	ptr.reportMethods[policy].Set(i)
+	ptr.set(ptr.method(ReportPolicyAvg, func() ReportMethod { return NewAvg() }), i)
 }
 
 // ReportMax max report
 func ReportMax(key string, i int) {
 	ptr := GetPropertyReport(key)
-	policy := ReportPolicyMax
-	if nil == ptr.reportMethods[policy] {
-		ptr.reportMethods[policy] = NewMax()
-	}
-	ptr.reportMethods[policy].Set(i)
+	ptr.set(ptr.method(ReportPolicyMax, func() ReportMethod { return NewMax() }), i)
 }
 
 // ReportMin min report
 func ReportMin(key string, i int) {
 	ptr := GetPropertyReport(key)
-	policy := ReportPolicyMin
-	if nil == ptr.reportMethods[policy] {
-		ptr.reportMethods[policy] = NewMin()
-	}
-	ptr.reportMethods[policy].Set(i)
+	ptr.set(ptr.method(ReportPolicyMin, func() ReportMethod { return NewMin() }), i)
 }
 
 // ReportDistr distr report
 func ReportDistr(key string, in []int, i int) {
 	ptr := GetPropertyReport(key)
-	policy := ReportPolicyDistr
-	if nil == ptr.reportMethods[policy] {
-		ptr.reportMethods[policy] = NewDistr(in)
-	}
-	ptr.reportMethods[policy].Set(i)
+	ptr.set(ptr.method(ReportPolicyDistr, func() ReportMethod { return NewDistr(in) }), i)
 }
 
 // ReportCount count report
 func ReportCount(key string, i int) {
 	ptr := GetPropertyReport(key)
-	policy := ReportPolicyCount
-	if nil == ptr.reportMethods[policy] {
-		ptr.reportMethods[policy] = NewCount()
-	}
-	ptr.reportMethods[policy].Set(i)
+	ptr.set(ptr.method(ReportPolicyCount, func() ReportMethod { return NewCount() }), i)
 }
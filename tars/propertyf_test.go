@@ -0,0 +1,129 @@
+package tars
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// resetPropertyHelper replaces the global property helper, and returns the function to restore it.
+func resetPropertyHelper() func() {
+	proOnce.Do(initProReport)
+	helper := ProHelper
+	ProHelper = &PropertyReportHelper{reportPtrs: new(sync.Map)}
+	return func() {
+		ProHelper = helper
+	}
+}
+
+// TestPropertyLabelsLimit tests the new label sets of a name over the limit share the overflow report, and
+// the existing ones and the other names are not affected.
+func TestPropertyLabelsLimit(t *testing.T) {
+	defer resetPropertyHelper()()
+
+	first := CreatePropertyReportWithLabels("request", map[string]string{"api": "0"}, NewCount())
+	for i := 1; i < PropertyLabelsLimit; i++ {
+		CreatePropertyReportWithLabels("request", map[string]string{"api": strconv.Itoa(i)}, NewCount())
+	}
+	over1 := CreatePropertyReportWithLabels("request", map[string]string{"api": "over1"}, NewCount())
+	over2 := CreatePropertyReportWithLabels("request", map[string]string{"api": "over2"}, NewSum())
+	if over1 != over2 {
+		t.Fatal("label sets over the limit are not reported together")
+	}
+	if want := map[string]string{PropertyLabelOverflow: "true"}; !reflect.DeepEqual(over1.labels, want) {
+		t.Fatalf("labels over the limit: got %v, want %v", over1.labels, want)
+	}
+	if over1.key != "request{overflow=true}" {
+		t.Fatalf("key over the limit: %s", over1.key)
+	}
+	if over1.reportMethods[ReportPolicyCount] == nil || over1.reportMethods[ReportPolicySum] == nil {
+		t.Fatal("methods of the label sets over the limit are not kept")
+	}
+	if got := CreatePropertyReportWithLabels("request", map[string]string{"api": "0"}); got != first {
+		t.Fatal("label set under the limit is not returned after the limit")
+	}
+	if got := CreatePropertyReportWithLabels("login", map[string]string{"api": "over1"}); got.key != "login{api=over1}" {
+		t.Fatalf("other name is limited: %s", got.key)
+	}
+}
+
+// TestPropertyLabelCollision tests the labels whose names collide on /metrics are dropped but the first in
+// the sorted order.
+func TestPropertyLabelCollision(t *testing.T) {
+	defer resetPropertyHelper()()
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   map[string]string
+		metric string
+	}{
+		{
+			name:   "no collision",
+			labels: map[string]string{"api": "login", "zone": "sz"},
+			want:   map[string]string{"api": "login", "zone": "sz"},
+			metric: `property="p1",api="login",zone="sz"`,
+		},
+		{
+			name:   "sanitized",
+			labels: map[string]string{"a_b": "2", "a-b": "1", "c": "3"},
+			want:   map[string]string{"a-b": "1", "c": "3"},
+			metric: `property="p2",a_b="1",c="3"`,
+		},
+		{
+			name:   "property",
+			labels: map[string]string{"property": "1", "label_property": "2"},
+			want:   map[string]string{"label_property": "2"},
+			metric: `property="p3",label_property="2"`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "p" + strconv.Itoa(i+1)
+			pr := CreatePropertyReportWithLabels(name, tt.labels, NewSum())
+			if !reflect.DeepEqual(pr.labels, tt.want) {
+				t.Fatalf("labels: got %v, want %v", pr.labels, tt.want)
+			}
+			if got := propertyLabels(pr); got != tt.metric {
+				t.Fatalf("labels on /metrics: got %s, want %s", got, tt.metric)
+			}
+			if again := CreatePropertyReportWithLabels(name, tt.labels); again != pr {
+				t.Fatal("same labels return another report")
+			}
+		})
+	}
+}
+
+// TestPropertyConcurrent tests the methods are added and reported concurrently on the same report, run with -race.
+func TestPropertyConcurrent(t *testing.T) {
+	defer resetPropertyHelper()()
+
+	labels := map[string]string{"api": "hello"}
+	methods := []func() ReportMethod{
+		func() ReportMethod { return NewSum() },
+		func() ReportMethod { return NewCount() },
+		func() ReportMethod { return NewMax() },
+		func() ReportMethod { return NewMin() },
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			CreatePropertyReportWithLabels("concurrent", labels, methods[i%len(methods)]())
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				CreatePropertyReportWithLabels("concurrent", labels).Report(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	pr := CreatePropertyReportWithLabels("concurrent", labels)
+	if got := len(pr.methods()); got != len(methods) {
+		t.Fatalf("got %d methods, want %d", got, len(methods))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -62,8 +63,42 @@ type Buffer struct {
 
 // Reader is wapper of bytes.Reader
 type Reader struct {
-	ref []byte
-	buf *bytes.Reader
+	ref    []byte
+	buf    *bytes.Reader
+	limits Limits
+	depth  int
+}
+
+// Limits restricts what a Reader accepts from the wire, zero means no limit.
+// Lengths are always checked against the remaining bytes, so a corrupt length can not cause a huge allocation.
+type Limits struct {
+	MaxStringLen int // max bytes of a string
+	MaxListLen   int // max elements of a list, or bytes of a simple list
+	MaxMapLen    int // max entries of a map
+	MaxDepth     int // max nesting of the skipped lists, maps and structs
+}
+
+// defaultLimits holds the Limits of the new readers, it may be set while decoding.
+var defaultLimits atomic.Value
+
+func init() {
+	defaultLimits.Store(Limits{MaxDepth: 100})
+}
+
+// SetDefaultLimits sets the limits for the readers created afterwards, the readers created before keep theirs.
+// It is safe to call while other goroutines are decoding.
+func SetDefaultLimits(l Limits) {
+	defaultLimits.Store(l)
+}
+
+// DefaultLimits returns the limits used by NewReader.
+func DefaultLimits() Limits {
+	return defaultLimits.Load().(Limits)
+}
+
+// SetLimits sets the limits of the reader.
+func (b *Reader) SetLimits(l Limits) {
+	b.limits = l
 }
 
 //go:nosplit
@@ -114,7 +149,7 @@ func bReadU16(r *bytes.Reader, data *uint16) error {
 	var b [2]byte
 	var bs []byte
 	bs = b[:]
-	_, err := r.Read(bs)
+	_, err := io.ReadFull(r, bs)
 	*data = binary.BigEndian.Uint16(bs)
 	return err
 }
@@ -124,7 +159,7 @@ func bReadU32(r *bytes.Reader, data *uint32) error {
 	var b [4]byte
 	var bs []byte
 	bs = b[:]
-	_, err := r.Read(bs)
+	_, err := io.ReadFull(r, bs)
 	*data = binary.BigEndian.Uint32(bs)
 	return err
 }
@@ -134,7 +169,7 @@ func bReadU64(r *bytes.Reader, data *uint64) error {
 	var b [8]byte
 	var bs []byte
 	bs = b[:]
-	_, err := r.Read(bs)
+	_, err := io.ReadFull(r, bs)
 	*data = binary.BigEndian.Uint64(bs)
 	return err
 }
@@ -365,25 +400,68 @@ func (b *Reader) Skip(n int) {
 	b.buf.Seek(int64(n), io.SeekCurrent)
 }
 
+// skip skips the next n bytes, and fails if there are not enough.
+func (b *Reader) skip(n int) error {
+	if n < 0 || n > b.buf.Len() {
+		return fmt.Errorf("skip %d bytes, but only %d left", n, b.buf.Len())
+	}
+	b.Skip(n)
+	return nil
+}
+
+// checkLength checks the length of a string, list, simple list or map read from the wire.
+func (b *Reader) checkLength(ty byte, n int64) error {
+	if n < 0 {
+		return fmt.Errorf("invalid %s length %d", getTypeStr(int(ty)), n)
+	}
+	// every element takes at least one byte, map entries take two
+	min, limit := n, 0
+	switch ty {
+	case STRING1, STRING4:
+		limit = b.limits.MaxStringLen
+	case LIST, SIMPLE_LIST:
+		limit = b.limits.MaxListLen
+	case MAP:
+		min, limit = n*2, b.limits.MaxMapLen
+	}
+	if limit > 0 && n > int64(limit) {
+		return fmt.Errorf("%s length %d exceeds the limit %d", getTypeStr(int(ty)), n, limit)
+	}
+	if min > int64(b.buf.Len()) {
+		return fmt.Errorf("%s length %d, but only %d bytes left", getTypeStr(int(ty)), n, b.buf.Len())
+	}
+	return nil
+}
+
+// ReadLength reads the length of a list, simple list or map, and checks it against the limits.
+func (b *Reader) ReadLength(data *int32, ty byte) error {
+	if err := b.Read_int32(data, 0, true); err != nil {
+		return err
+	}
+	return b.checkLength(ty, int64(*data))
+}
+
 func (b *Reader) skipFieldMap() error {
 	var len int32
-	err := b.Read_int32(&len, 0, true)
+	err := b.ReadLength(&len, MAP)
 	if err != nil {
 		return err
 	}
 
-	for i := int32(0); i < len*2; i++ {
+	for i := int64(0); i < int64(len)*2; i++ {
 		tyCur, _, err := b.readHead()
 		if err != nil {
 			return err
 		}
-		b.skipField(tyCur)
+		if err = b.skipField(tyCur); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 func (b *Reader) skipFieldList() error {
 	var len int32
-	err := b.Read_int32(&len, 0, true)
+	err := b.ReadLength(&len, LIST)
 	if err != nil {
 		return err
 	}
@@ -392,64 +470,71 @@ func (b *Reader) skipFieldList() error {
 		if err != nil {
 			return err
 		}
-		b.skipField(tyCur)
+		if err = b.skipField(tyCur); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 func (b *Reader) skipFieldSimpleList() error {
 	tyCur, _, err := b.readHead()
-	if tyCur != BYTE {
-		return fmt.Errorf("simple list need byte head. but get %d", tyCur)
-	}
 	if err != nil {
 		return err
 	}
+	if tyCur != BYTE {
+		return fmt.Errorf("simple list need byte head. but get %d", tyCur)
+	}
 	var len int32
-	err = b.Read_int32(&len, 0, true)
+	err = b.ReadLength(&len, SIMPLE_LIST)
 	if err != nil {
 		return err
 	}
 
-	b.Skip(int(len))
-	return nil
+	return b.skip(int(len))
 }
 
 func (b *Reader) skipField(ty byte) error {
+	switch ty {
+	case MAP, LIST, STRUCT_BEGIN:
+		if b.limits.MaxDepth > 0 && b.depth >= b.limits.MaxDepth {
+			return fmt.Errorf("nesting depth exceeds the limit %d", b.limits.MaxDepth)
+		}
+		b.depth++
+		defer func() { b.depth-- }()
+	}
+
 	switch ty {
 	case BYTE:
-		b.Skip(1)
-		break
+		return b.skip(1)
 	case SHORT:
-		b.Skip(2)
-		break
+		return b.skip(2)
 	case INT:
-		b.Skip(4)
-		break
+		return b.skip(4)
 	case LONG:
-		b.Skip(8)
-		break
+		return b.skip(8)
 	case FLOAT:
-		b.Skip(4)
-		break
+		return b.skip(4)
 	case DOUBLE:
-		b.Skip(8)
-		break
+		return b.skip(8)
 	case STRING1:
 		data, err := b.buf.ReadByte()
 		if err != nil {
 			return err
 		}
-		l := int(data)
-		b.Skip(l)
-		break
+		if err = b.checkLength(STRING1, int64(data)); err != nil {
+			return err
+		}
+		return b.skip(int(data))
 	case STRING4:
 		var l uint32
 		err := bReadU32(b.buf, &l)
 		if err != nil {
 			return err
 		}
-		b.Skip(int(l))
-		break
+		if err = b.checkLength(STRING4, int64(l)); err != nil {
+			return err
+		}
+		return b.skip(int(l))
 	case MAP:
 		err := b.skipFieldMap()
 		if err != nil {
@@ -551,9 +636,12 @@ func (b *Reader) Read_slice_int8(data *[]int8, len int32, require bool) error {
 	if len <= 0 {
 		return nil
 	}
+	if err := b.checkLength(SIMPLE_LIST, int64(len)); err != nil {
+		return err
+	}
 
 	*data = make([]int8, len)
-	_, err := b.buf.Read(*(*[]uint8)(unsafe.Pointer(data)))
+	_, err := io.ReadFull(b.buf, *(*[]uint8)(unsafe.Pointer(data)))
 	if err != nil {
 		err = fmt.Errorf("Read_slice_int8 error:%v", err)
 	}
@@ -565,9 +653,12 @@ func (b *Reader) Read_slice_uint8(data *[]uint8, len int32, require bool) error
 	if len <= 0 {
 		return nil
 	}
+	if err := b.checkLength(SIMPLE_LIST, int64(len)); err != nil {
+		return err
+	}
 
 	*data = make([]uint8, len)
-	_, err := b.buf.Read(*data)
+	_, err := io.ReadFull(b.buf, *data)
 	if err != nil {
 		err = fmt.Errorf("Read_slice_uint8 error:%v", err)
 	}
@@ -812,6 +903,9 @@ func (b *Reader) Read_string(data *string, tag byte, require bool) error {
 		if err != nil {
 			return fmt.Errorf("Read_string4 tag:%d error:%v", tag, err)
 		}
+		if err = b.checkLength(ty, int64(len)); err != nil {
+			return fmt.Errorf("Read_string4 tag:%d error:%v", tag, err)
+		}
 		buff := b.Next(int(len))
 		*data = string(buff)
 	} else if ty == STRING1 {
@@ -820,6 +914,9 @@ func (b *Reader) Read_string(data *string, tag byte, require bool) error {
 		if err != nil {
 			return fmt.Errorf("Read_string1 tag:%d error:%v", tag, err)
 		}
+		if err = b.checkLength(ty, int64(len)); err != nil {
+			return fmt.Errorf("Read_string1 tag:%d error:%v", tag, err)
+		}
 		buff := b.Next(int(len))
 		*data = string(buff)
 	} else {
@@ -840,7 +937,7 @@ func (b *Buffer) Grow(size int) {
 
 // NewReader returns *Reader
 func NewReader(data []byte) *Reader {
-	return &Reader{buf: bytes.NewReader(data), ref: data}
+	return &Reader{buf: bytes.NewReader(data), ref: data, limits: DefaultLimits()}
 }
 
 // NewBuffer returns *Buffer
@@ -322,3 +322,107 @@ func BenchmarkString(t *testing.B) {
 		}
 	}
 }
+
+// TestLimits tests the reader refuses lengths over the limits or the remaining bytes.
+func TestLimits(t *testing.T) {
+	b := NewBuffer()
+	b.Write_string("hahahahaha", 0)
+	rb := r(b)
+	rb.SetLimits(Limits{MaxStringLen: 5})
+	var data string
+	if err := rb.Read_string(&data, 0, true); err == nil {
+		t.Error("string over the limit should fail.")
+	}
+
+	// a list claiming more elements than the bytes left
+	b = NewBuffer()
+	b.WriteHead(LIST, 0)
+	b.Write_int32(math.MaxInt32, 0)
+	var length int32
+	rb = r(b)
+	rb.SkipTo(LIST, 0, true)
+	if err := rb.ReadLength(&length, LIST); err == nil {
+		t.Error("list length over the remaining bytes should fail.")
+	}
+
+	b = NewBuffer()
+	b.WriteHead(SIMPLE_LIST, 0)
+	b.WriteHead(BYTE, 0)
+	b.Write_int32(-1, 0)
+	if err := r(b).SkipToStructEnd(); err == nil {
+		t.Error("negative length should fail.")
+	}
+}
+
+// TestSkipLimits tests the skipped strings are checked against the limit, like the read ones.
+func TestSkipLimits(t *testing.T) {
+	for _, n := range []int{10, 300} { // STRING1 and STRING4
+		b := NewBuffer()
+		b.Write_string(string(make([]byte, n)), 0)
+		b.Write_int32(7, 1)
+
+		rb := r(b)
+		rb.SetLimits(Limits{MaxStringLen: n - 1})
+		var data int32
+		if err := rb.Read_int32(&data, 1, true); err == nil {
+			t.Errorf("skipping a string of %d bytes over the limit should fail.", n)
+		}
+		rb = r(b)
+		rb.SetLimits(Limits{MaxStringLen: n})
+		if err := rb.Read_int32(&data, 1, true); err != nil || data != 7 {
+			t.Errorf("skipping a string of %d bytes: got %d %v", n, data, err)
+		}
+	}
+}
+
+// TestDefaultLimits tests the default limits apply to the readers created afterwards, and may be set while
+// other goroutines are decoding.
+func TestDefaultLimits(t *testing.T) {
+	old := DefaultLimits()
+	defer SetDefaultLimits(old)
+
+	b := NewBuffer()
+	b.Write_string("hahahahaha", 0)
+	before := r(b)
+	SetDefaultLimits(Limits{MaxStringLen: 5})
+	var data string
+	if err := r(b).Read_string(&data, 0, true); err == nil {
+		t.Error("string over the default limit should fail.")
+	}
+	if err := before.Read_string(&data, 0, true); err != nil {
+		t.Errorf("reader created before keeps its limits: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			SetDefaultLimits(Limits{MaxStringLen: i})
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		r(b).Read_string(&data, 0, true)
+	}
+	<-done
+}
+
+// TestMaxDepth tests skipping deeply nested structs stops at the limit.
+func TestMaxDepth(t *testing.T) {
+	b := NewBuffer()
+	for i := 0; i < 10; i++ {
+		b.WriteHead(STRUCT_BEGIN, 0)
+	}
+	for i := 0; i < 10; i++ {
+		b.WriteHead(STRUCT_END, 0)
+	}
+	b.WriteHead(STRUCT_END, 0)
+
+	rb := r(b)
+	rb.SetLimits(Limits{MaxDepth: 5})
+	if err := rb.SkipToStructEnd(); err == nil {
+		t.Error("nesting over the limit should fail.")
+	}
+	if err := r(b).SkipToStructEnd(); err != nil {
+		t.Error(err)
+	}
+}
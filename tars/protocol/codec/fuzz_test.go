@@ -0,0 +1,97 @@
+//go:build go1.18
+// +build go1.18
+
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/codec"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/propertyf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+)
+
+type readable interface {
+	ReadFrom(_is *codec.Reader) error
+	WriteTo(_os *codec.Buffer) error
+}
+
+func encode(f *testing.F, st readable) []byte {
+	os := codec.NewBuffer()
+	if err := st.WriteTo(os); err != nil {
+		f.Fatal(err)
+	}
+	return os.ToBytes()
+}
+
+// fuzzReadFrom feeds the seed and random input to ReadFrom, which must return an error instead of panic.
+func fuzzReadFrom(f *testing.F, seed readable, newValue func() readable) {
+	f.Add(encode(f, seed))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		st := newValue()
+		if err := st.ReadFrom(codec.NewReader(data)); err != nil {
+			return
+		}
+		// whatever is decoded must be encoded and decoded again.
+		os := codec.NewBuffer()
+		if err := st.WriteTo(os); err != nil {
+			t.Fatal(err)
+		}
+		if err := newValue().ReadFrom(codec.NewReader(os.ToBytes())); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzRequestPacket(f *testing.F) {
+	seed := &requestf.RequestPacket{
+		IVersion:     1,
+		IRequestId:   10,
+		SServantName: "App.Server.Obj",
+		SFuncName:    "test",
+		SBuffer:      []int8{1, 2, 3},
+		ITimeout:     3000,
+		Context:      map[string]string{"a": "b"},
+		Status:       map[string]string{"c": "d"},
+	}
+	fuzzReadFrom(f, seed, func() readable { return &requestf.RequestPacket{} })
+}
+
+func FuzzResponsePacket(f *testing.F) {
+	seed := &requestf.ResponsePacket{
+		IVersion:    1,
+		IRequestId:  10,
+		IRet:        -1,
+		SBuffer:     []int8{1, 2, 3},
+		SResultDesc: "error",
+		Status:      map[string]string{"a": "b"},
+		Context:     map[string]string{"c": "d"},
+	}
+	fuzzReadFrom(f, seed, func() readable { return &requestf.ResponsePacket{} })
+}
+
+func FuzzStatMicMsgBody(f *testing.F) {
+	seed := &statf.StatMicMsgBody{
+		Count:         10,
+		TotalRspTime:  100,
+		IntervalCount: map[int32]int32{10: 1, 100: 9},
+	}
+	fuzzReadFrom(f, seed, func() readable { return &statf.StatMicMsgBody{} })
+}
+
+func FuzzStatPropMsgBody(f *testing.F) {
+	seed := &propertyf.StatPropMsgBody{
+		VInfo: []propertyf.StatPropInfo{{Policy: "Sum", Value: "10"}},
+	}
+	fuzzReadFrom(f, seed, func() readable { return &propertyf.StatPropMsgBody{} })
+}
+
+// FuzzSkipToStructEnd checks skipping unknown fields of any shape.
+func FuzzSkipToStructEnd(f *testing.F) {
+	f.Add(encode(f, &requestf.RequestPacket{SServantName: "a"}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		codec.NewReader(data).SkipToStructEnd()
+	})
+}
@@ -62,7 +62,7 @@ func (_obj *Config) ListConfig(App string, Server string, Vf *[]string, _opt ...
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -135,7 +135,7 @@ func (_obj *Config) ListConfigWithContext(ctx context.Context, App string, Serve
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -525,7 +525,7 @@ func (_obj *Config) ListConfigByInfo(ConfigInfo *ConfigInfo, Vf *[]string, _opt
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -593,7 +593,7 @@ func (_obj *Config) ListConfigByInfoWithContext(ctx context.Context, ConfigInfo
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -836,7 +836,7 @@ func (_obj *Config) ListAllConfigByInfo(ConfigInfo *GetConfigListInfo, Vf *[]str
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -904,7 +904,7 @@ func (_obj *Config) ListAllConfigByInfoWithContext(ctx context.Context, ConfigIn
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -87,7 +87,10 @@ func (st *GetConfigListInfo) ReadBlock(_is *codec.Reader, tag byte, require bool
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
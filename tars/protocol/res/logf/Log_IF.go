@@ -319,7 +319,7 @@ func logger(ctx context.Context, _val interface{}, _os *codec.Buffer, _is *codec
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return err
 		}
@@ -377,7 +377,7 @@ func loggerbyInfo(ctx context.Context, _val interface{}, _os *codec.Buffer, _is
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return err
 		}
@@ -71,7 +71,10 @@ func (st *ServerInfo) ReadBlock(_is *codec.Reader, tag byte, require bool) error
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
@@ -36,7 +36,7 @@ func (st *NotifyInfo) ReadFrom(_is *codec.Reader) error {
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return err
 		}
@@ -84,7 +84,10 @@ func (st *NotifyInfo) ReadBlock(_is *codec.Reader, tag byte, require bool) error
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
@@ -177,7 +177,7 @@ func reportPropMsg(ctx context.Context, _val interface{}, _os *codec.Buffer, _is
 		return err
 	}
 
-	err = _is.Read_int32(&length, 0, true)
+	err = _is.ReadLength(&length, codec.MAP)
 	if err != nil {
 		return err
 	}
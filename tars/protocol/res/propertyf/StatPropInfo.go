@@ -59,7 +59,10 @@ func (st *StatPropInfo) ReadBlock(_is *codec.Reader, tag byte, require bool) err
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
@@ -30,7 +30,7 @@ func (st *StatPropMsgBody) ReadFrom(_is *codec.Reader) error {
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return err
 		}
@@ -78,7 +78,10 @@ func (st *StatPropMsgBody) ReadBlock(_is *codec.Reader, tag byte, require bool)
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
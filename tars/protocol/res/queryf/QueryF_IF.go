@@ -52,7 +52,7 @@ func (_obj *QueryF) FindObjectById(Id string, _opt ...map[string]string) (ret []
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -115,7 +115,7 @@ func (_obj *QueryF) FindObjectByIdWithContext(ctx context.Context, Id string, _o
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -184,7 +184,7 @@ func (_obj *QueryF) FindObjectById4Any(Id string, ActiveEp *[]endpointf.Endpoint
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -214,7 +214,7 @@ func (_obj *QueryF) FindObjectById4Any(Id string, ActiveEp *[]endpointf.Endpoint
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -282,7 +282,7 @@ func (_obj *QueryF) FindObjectById4AnyWithContext(ctx context.Context, Id string
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -312,7 +312,7 @@ func (_obj *QueryF) FindObjectById4AnyWithContext(ctx context.Context, Id string
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -381,7 +381,7 @@ func (_obj *QueryF) FindObjectById4All(Id string, ActiveEp *[]endpointf.Endpoint
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -411,7 +411,7 @@ func (_obj *QueryF) FindObjectById4All(Id string, ActiveEp *[]endpointf.Endpoint
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -479,7 +479,7 @@ func (_obj *QueryF) FindObjectById4AllWithContext(ctx context.Context, Id string
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -509,7 +509,7 @@ func (_obj *QueryF) FindObjectById4AllWithContext(ctx context.Context, Id string
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -578,7 +578,7 @@ func (_obj *QueryF) FindObjectByIdInSameGroup(Id string, ActiveEp *[]endpointf.E
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -608,7 +608,7 @@ func (_obj *QueryF) FindObjectByIdInSameGroup(Id string, ActiveEp *[]endpointf.E
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -676,7 +676,7 @@ func (_obj *QueryF) FindObjectByIdInSameGroupWithContext(ctx context.Context, Id
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -706,7 +706,7 @@ func (_obj *QueryF) FindObjectByIdInSameGroupWithContext(ctx context.Context, Id
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -780,7 +780,7 @@ func (_obj *QueryF) FindObjectByIdInSameStation(Id string, SStation string, Acti
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -810,7 +810,7 @@ func (_obj *QueryF) FindObjectByIdInSameStation(Id string, SStation string, Acti
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -883,7 +883,7 @@ func (_obj *QueryF) FindObjectByIdInSameStationWithContext(ctx context.Context,
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -913,7 +913,7 @@ func (_obj *QueryF) FindObjectByIdInSameStationWithContext(ctx context.Context,
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -987,7 +987,7 @@ func (_obj *QueryF) FindObjectByIdInSameSet(Id string, SetId string, ActiveEp *[
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -1017,7 +1017,7 @@ func (_obj *QueryF) FindObjectByIdInSameSet(Id string, SetId string, ActiveEp *[
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -1090,7 +1090,7 @@ func (_obj *QueryF) FindObjectByIdInSameSetWithContext(ctx context.Context, Id s
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -1120,7 +1120,7 @@ func (_obj *QueryF) FindObjectByIdInSameSetWithContext(ctx context.Context, Id s
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return ret, err
 		}
@@ -75,7 +75,7 @@ func (st *RequestPacket) ReadFrom(_is *codec.Reader) error {
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return err
 		}
@@ -93,7 +93,7 @@ func (st *RequestPacket) ReadFrom(_is *codec.Reader) error {
 		if err != nil {
 			return err
 		}
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.SIMPLE_LIST)
 		if err != nil {
 			return err
 		}
@@ -119,7 +119,7 @@ func (st *RequestPacket) ReadFrom(_is *codec.Reader) error {
 		return err
 	}
 
-	err = _is.Read_int32(&length, 0, true)
+	err = _is.ReadLength(&length, codec.MAP)
 	if err != nil {
 		return err
 	}
@@ -146,7 +146,7 @@ func (st *RequestPacket) ReadFrom(_is *codec.Reader) error {
 		return err
 	}
 
-	err = _is.Read_int32(&length, 0, true)
+	err = _is.ReadLength(&length, codec.MAP)
 	if err != nil {
 		return err
 	}
@@ -193,7 +193,10 @@ func (st *RequestPacket) ReadBlock(_is *codec.Reader, tag byte, require bool) er
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
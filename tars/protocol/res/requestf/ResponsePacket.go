@@ -67,7 +67,7 @@ func (st *ResponsePacket) ReadFrom(_is *codec.Reader) error {
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return err
 		}
@@ -85,7 +85,7 @@ func (st *ResponsePacket) ReadFrom(_is *codec.Reader) error {
 		if err != nil {
 			return err
 		}
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.SIMPLE_LIST)
 		if err != nil {
 			return err
 		}
@@ -106,7 +106,7 @@ func (st *ResponsePacket) ReadFrom(_is *codec.Reader) error {
 		return err
 	}
 
-	err = _is.Read_int32(&length, 0, true)
+	err = _is.ReadLength(&length, codec.MAP)
 	if err != nil {
 		return err
 	}
@@ -138,7 +138,7 @@ func (st *ResponsePacket) ReadFrom(_is *codec.Reader) error {
 		return err
 	}
 	if have {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.MAP)
 		if err != nil {
 			return err
 		}
@@ -185,7 +185,10 @@ func (st *ResponsePacket) ReadBlock(_is *codec.Reader, tag byte, require bool) e
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
@@ -288,7 +288,7 @@ func reportMicMsg(ctx context.Context, _val interface{}, _os *codec.Buffer, _is
 		return err
 	}
 
-	err = _is.Read_int32(&length, 0, true)
+	err = _is.ReadLength(&length, codec.MAP)
 	if err != nil {
 		return err
 	}
@@ -354,7 +354,7 @@ func reportSampleMsg(ctx context.Context, _val interface{}, _os *codec.Buffer, _
 	}
 
 	if ty == codec.LIST {
-		err = _is.Read_int32(&length, 0, true)
+		err = _is.ReadLength(&length, codec.LIST)
 		if err != nil {
 			return err
 		}
@@ -50,7 +50,7 @@ func (st *StatMicMsgBody) ReadFrom(_is *codec.Reader) error {
 		return err
 	}
 
-	err = _is.Read_int32(&length, 0, true)
+	err = _is.ReadLength(&length, codec.MAP)
 	if err != nil {
 		return err
 	}
@@ -111,7 +111,10 @@ func (st *StatMicMsgBody) ReadBlock(_is *codec.Reader, tag byte, require bool) e
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
@@ -113,7 +113,10 @@ func (st *StatMicMsgHead) ReadBlock(_is *codec.Reader, tag byte, require bool) e
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
@@ -101,7 +101,10 @@ func (st *StatSampleMsg) ReadBlock(_is *codec.Reader, tag byte, require bool) er
 
 	}
 
-	st.ReadFrom(_is)
+	err = st.ReadFrom(_is)
+	if err != nil {
+		return err
+	}
 
 	err = _is.SkipToStructEnd()
 	if err != nil {
@@ -0,0 +1,83 @@
+package tars
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// reflectable is implemented by the dispatcher generated by tars2go with the description of the interface.
+type reflectable interface {
+	TarsReflection() (name string, methods []string, source string)
+}
+
+// ServantInfo describes the servant served by the server.
+type ServantInfo struct {
+	Obj       string   `json:"obj"`
+	Protocol  string   `json:"protocol"`
+	Interface string   `json:"interface,omitempty"`
+	Methods   []string `json:"methods,omitempty"`
+	Source    string   `json:"source,omitempty"`
+}
+
+// GetServantInfos returns the servants of the server in the registered order, with the interfaces and the
+// method signatures embedded by tars2go. The tars files are included if withSource and they are embedded.
+func GetServantInfos(withSource bool) []ServantInfo {
+	infos := make([]ServantInfo, 0, len(objRunList))
+	for _, obj := range objRunList {
+		info := ServantInfo{Obj: obj, Protocol: "custom"}
+		if _, ok := httpSvrs[obj]; ok {
+			info.Protocol = "http"
+		} else if s := findTarsServant(obj); s != nil {
+			info.Protocol = "tars"
+			if r, ok := s.dispatcher.(reflectable); ok {
+				var source string
+				info.Interface, info.Methods, source = r.TarsReflection()
+				if withSource {
+					info.Source = source
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func findTarsServant(obj string) *TarsProtocol {
+	for _, mux := range servantMuxes {
+		if s, ok := mux.servants[obj]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// reflectionCMD handles tars.reflection [-source] [obj ...], it returns the servants in json.
+func reflectionCMD(params []string) (string, error) {
+	withSource := false
+	objs := make(map[string]bool)
+	for _, v := range params {
+		if v == "-source" {
+			withSource = true
+		} else if v != "" {
+			objs[v] = true
+		}
+	}
+	infos := GetServantInfos(withSource)
+	if len(objs) > 0 {
+		selected := infos[:0]
+		for _, info := range infos {
+			if objs[info.Obj] {
+				selected = append(selected, info)
+			}
+		}
+		infos = selected
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(infos); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,76 @@
+package tars
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// reflectDispatch is the fakeDispatch with the description generated by tars2go.
+type reflectDispatch struct {
+	fakeDispatch
+}
+
+func (d *reflectDispatch) TarsReflection() (string, []string, string) {
+	return "App.Hello", []string{"int echo(string s, out string r);"}, "module App { interface Hello {}; };"
+}
+
+// TestReflectionCMD tests tars.reflection returns the servants in the registered order with their interfaces,
+// the sources with -source, and only the objects given.
+func TestReflectionCMD(t *testing.T) {
+	defer func(objs []string, muxes map[string]*servantMux, https map[string]*http.Server) {
+		objRunList, servantMuxes, httpSvrs = objs, muxes, https
+	}(objRunList, servantMuxes, httpSvrs)
+
+	mux := newServantMux()
+	mux.add("App.Server.HelloObj", NewTarsProtocol(&reflectDispatch{fakeDispatch{name: "App.Server.HelloObj"}}, nil, false))
+	mux.add("App.Server.StoreObj", NewTarsProtocol(&fakeDispatch{name: "App.Server.StoreObj"}, nil, false))
+	servantMuxes = map[string]*servantMux{"tcp://127.0.0.1:10015": mux}
+	httpSvrs = map[string]*http.Server{"App.Server.WebObj": {}}
+	objRunList = []string{"App.Server.HelloObj", "App.Server.WebObj", "App.Server.StoreObj", "App.Server.UdpObj"}
+
+	hello := ServantInfo{Obj: "App.Server.HelloObj", Protocol: "tars", Interface: "App.Hello",
+		Methods: []string{"int echo(string s, out string r);"}}
+	tests := []struct {
+		name   string
+		params []string
+		want   []ServantInfo
+	}{
+		{
+			name: "all",
+			want: []ServantInfo{
+				hello,
+				{Obj: "App.Server.WebObj", Protocol: "http"},
+				{Obj: "App.Server.StoreObj", Protocol: "tars"},
+				{Obj: "App.Server.UdpObj", Protocol: "custom"},
+			},
+		},
+		{
+			name:   "source",
+			params: []string{"-source", "App.Server.HelloObj", "App.Server.NoObj"},
+			want: []ServantInfo{{Obj: "App.Server.HelloObj", Protocol: "tars", Interface: "App.Hello",
+				Methods: hello.Methods, Source: "module App { interface Hello {}; };"}},
+		},
+		{
+			name:   "objects",
+			params: []string{"App.Server.StoreObj", "", "App.Server.HelloObj"},
+			want:   []ServantInfo{hello, {Obj: "App.Server.StoreObj", Protocol: "tars"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := reflectionCMD(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []ServantInfo
+			if err := json.Unmarshal([]byte(out), &got); err != nil {
+				t.Fatalf("%v: %s", err, out)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,20 +1,49 @@
 package tars
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/MacgradyHuang/TarsGo/tars/util/zaplog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/logf"
 )
 
+// RemoteLogStat is the counters of the logs of a RemoteTimeWriter.
+type RemoteLogStat struct {
+	// Sent is the logs sent to the log server, including the replayed ones.
+	Sent uint64
+	// Spilled is the logs spilled to the disk when the log server is down or the queue is full.
+	Spilled uint64
+	// Replayed is the spilled logs sent to the log server after it recovers.
+	Replayed uint64
+	// Dropped is the logs which are neither sent nor spilled.
+	Dropped uint64
+}
+
 // RemoteTimeWriter writer for writing remote log.
 type RemoteTimeWriter struct {
+	stat          RemoteLogStat // first for the alignment of the atomic counters
 	logInfo       *logf.LogInfo
 	logs          chan string
 	logPtr        *logf.Log
 	reportSuccPtr *PropertyReport
 	reportFailPtr *PropertyReport
 	hasPrefix     bool
+
+	mu       sync.Mutex
+	spill    *spillQueue
+	overflow []string
+	// overflowed carries the batches of the logs written when the queue is full to Sync2remote, which
+	// spills them, so that Write never waits for the disk.
+	overflowed chan []string
 }
 
 // NewRemoteTimeWriter new and init RemoteTimeWriter
@@ -23,6 +52,7 @@ func NewRemoteTimeWriter() *RemoteTimeWriter {
 	rw.logInfo = new(logf.LogInfo)
 	logs := make(chan string, remoteLogQueueSize)
 	rw.logs = logs
+	rw.overflowed = make(chan []string, remoteLogOverflowBatches)
 	rw.logPtr = new(logf.Log)
 	comm := NewCommunicator()
 	node := GetServerConfig().Log
@@ -34,35 +64,107 @@ func NewRemoteTimeWriter() *RemoteTimeWriter {
 	return rw
 }
 
-// Sync2remote syncs the log buffer to remote.
+// Sync2remote syncs the log buffer to remote. The logs failed to send after the retries are spilled to
+// the disk, and they are replayed in order when the log server recovers.
 func (rw *RemoteTimeWriter) Sync2remote() {
 	maxLen := remoteLogMaxNumOneTime
-	interval := time.Second
+	ticker := time.NewTicker(remoteLogInterval)
+	defer ticker.Stop()
 	v := make([]string, 0, maxLen)
 	for {
 		select {
 		case log := <-rw.logs:
 			v = append(v, log)
 			if len(v) >= maxLen {
-				err := rw.sync2remote(v)
-				if err != nil {
-					zaplog.Error("sync to remote error")
-					rw.reportFailPtr.Report(len(v))
-				}
-				rw.reportSuccPtr.Report(len(v))
+				rw.send(v)
 				v = make([]string, 0, maxLen) //reset the slice after syncing log to remote
 			}
-		case <-time.After(interval):
+		case overflow := <-rw.overflowed:
+			rw.spillLogs(overflow)
+		case <-ticker.C:
 			if len(v) > 0 {
-				err := rw.sync2remote(v)
-				if err != nil {
-					zaplog.Error("sync to remote error")
-					rw.reportFailPtr.Report(len(v))
-				}
-				rw.reportSuccPtr.Report(len(v))
+				rw.send(v)
 				v = make([]string, 0, maxLen) //reset the slice after syncing log to remote
 			}
+			rw.flushOverflow()
+			rw.replay()
+		}
+	}
+}
+
+// send sends the logs with retries, or spills them if it fails. The logs are spilled directly if there
+// are spilled logs not replayed, to keep the order of the logs.
+func (rw *RemoteTimeWriter) send(v []string) {
+	if q := rw.getSpill(); q != nil && !q.empty() {
+		rw.spillLogs(v)
+		rw.replay()
+		return
+	}
+	backoff := remoteLogRetryBackoff
+	for i := 0; ; i++ {
+		err := rw.sync2remote(v)
+		if err == nil {
+			atomic.AddUint64(&rw.stat.Sent, uint64(len(v)))
+			rw.reportSuccPtr.Report(len(v))
+			return
+		}
+		if i >= remoteLogRetry {
+			zaplog.Error("sync to remote error", zap.String("File", rw.logInfo.SFilename), zap.Int("Num", len(v)), zap.Error(err))
+			rw.reportFailPtr.Report(len(v))
+			rw.spillLogs(v)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// replay sends the spilled logs in order until it fails.
+func (rw *RemoteTimeWriter) replay() {
+	q := rw.getSpill()
+	if q == nil {
+		return
+	}
+	q.replayMu.Lock()
+	defer q.replayMu.Unlock()
+	for {
+		name, logs, ok := q.peek()
+		if !ok {
+			return
+		}
+		if err := rw.sync2remote(logs); err != nil {
+			return
 		}
+		q.remove(name)
+		atomic.AddUint64(&rw.stat.Sent, uint64(len(logs)))
+		atomic.AddUint64(&rw.stat.Replayed, uint64(len(logs)))
+		rw.reportSuccPtr.Report(len(logs))
+	}
+}
+
+// spillLogs writes the logs to the spill queue, or drops them if there is no spill queue or it is full.
+func (rw *RemoteTimeWriter) spillLogs(v []string) {
+	q := rw.getSpill()
+	if q == nil {
+		atomic.AddUint64(&rw.stat.Dropped, uint64(len(v)))
+		return
+	}
+	if err := q.push(v); err != nil {
+		zaplog.Error("spill remote log error", zap.String("File", rw.logInfo.SFilename), zap.Int("Num", len(v)), zap.Error(err))
+		atomic.AddUint64(&rw.stat.Dropped, uint64(len(v)))
+		return
+	}
+	atomic.AddUint64(&rw.stat.Spilled, uint64(len(v)))
+}
+
+// flushOverflow spills the logs written when the queue is full.
+func (rw *RemoteTimeWriter) flushOverflow() {
+	rw.mu.Lock()
+	v := rw.overflow
+	rw.overflow = nil
+	rw.mu.Unlock()
+	if len(v) > 0 {
+		rw.spillLogs(v)
 	}
 }
 
@@ -71,6 +173,79 @@ func (rw *RemoteTimeWriter) sync2remote(s []string) error {
 	return err
 }
 
+func (rw *RemoteTimeWriter) getSpill() *spillQueue {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.spill
+}
+
+// SetSpillPath sets the directory to spill the logs when the log server is down, the spilling is disabled
+// if the path is empty. It is DataPath/remotelog/App.Server.filename by default, or in LogPath if there
+// is no DataPath.
+func (rw *RemoteTimeWriter) SetSpillPath(path string) error {
+	var q *spillQueue
+	if path != "" {
+		var err error
+		if q, err = getSpillQueue(path, remoteLogSpillMaxSize); err != nil {
+			return err
+		}
+	}
+	rw.mu.Lock()
+	rw.spill = q
+	rw.mu.Unlock()
+	return nil
+}
+
+// Stat returns the counters of the logs.
+func (rw *RemoteTimeWriter) Stat() RemoteLogStat {
+	return RemoteLogStat{
+		Sent:     atomic.LoadUint64(&rw.stat.Sent),
+		Spilled:  atomic.LoadUint64(&rw.stat.Spilled),
+		Replayed: atomic.LoadUint64(&rw.stat.Replayed),
+		Dropped:  atomic.LoadUint64(&rw.stat.Dropped),
+	}
+}
+
+var (
+	remoteWritersMu sync.Mutex
+	remoteWriters   []*RemoteTimeWriter
+)
+
+// GetRemoteLogStats returns the counters of the remote logs by the file names.
+func GetRemoteLogStats() map[string]RemoteLogStat {
+	remoteWritersMu.Lock()
+	defer remoteWritersMu.Unlock()
+	stats := make(map[string]RemoteLogStat)
+	for _, rw := range remoteWriters {
+		name := rw.logInfo.SFilename
+		st, s := stats[name], rw.Stat()
+		st.Sent += s.Sent
+		st.Spilled += s.Spilled
+		st.Replayed += s.Replayed
+		st.Dropped += s.Dropped
+		stats[name] = st
+	}
+	return stats
+}
+
+func remoteLogStatCMD(params []string) (string, error) {
+	stats := GetRemoteLogStats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		if len(params) > 0 && name != params[0] {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		st := stats[name]
+		fmt.Fprintf(&b, "%s sent=%d spilled=%d replayed=%d dropped=%d\n", name, st.Sent, st.Spilled, st.Replayed, st.Dropped)
+	}
+	return b.String(), nil
+}
+
 // InitServerInfo init the remote log server info.
 func (rw *RemoteTimeWriter) InitServerInfo(app string, server string, filename string, setdivision string) {
 	rw.logInfo.Appname = app
@@ -85,6 +260,21 @@ func (rw *RemoteTimeWriter) InitServerInfo(app string, server string, filename s
 	succSum := NewSum()
 	rw.reportSuccPtr = CreatePropertyReport(succServerInfo, succSum)
 
+	remoteWritersMu.Lock()
+	remoteWriters = append(remoteWriters, rw)
+	remoteWritersMu.Unlock()
+
+	if cfg := GetServerConfig(); cfg != nil {
+		base := cfg.DataPath
+		if base == "" && cfg.LogPath != "" {
+			base = filepath.Join(cfg.LogPath, cfg.App, cfg.Server)
+		}
+		if base != "" {
+			if err := rw.SetSpillPath(filepath.Join(base, "remotelog", serverInfo)); err != nil {
+				zaplog.Error("init remote log spill error", zap.String("File", filename), zap.Error(err))
+			}
+		}
+	}
 }
 
 // EnableSufix puts sufix after logs.
@@ -140,7 +330,44 @@ func (rw *RemoteTimeWriter) Write(b []byte) {
 	select {
 	case rw.logs <- s:
 	default:
-		zaplog.Error("remote log chan is full")
+		// the queue is full, the logs are spilled in batches by Sync2remote, or dropped if it falls behind
+		rw.mu.Lock()
+		rw.overflow = append(rw.overflow, s)
+		var v []string
+		if len(rw.overflow) >= remoteLogMaxNumOneTime {
+			v = rw.overflow
+			rw.overflow = nil
+		}
+		rw.mu.Unlock()
+		if v != nil {
+			select {
+			case rw.overflowed <- v:
+			default:
+				atomic.AddUint64(&rw.stat.Dropped, uint64(len(v)))
+			}
+		}
+	}
+}
+
+// remoteZapWriter writes the logs of zap to the RemoteTimeWriter without the line break.
+type remoteZapWriter struct {
+	rw *RemoteTimeWriter
+}
+
+func (w remoteZapWriter) Write(p []byte) (int, error) {
+	w.rw.Write(bytes.TrimSuffix(p, []byte("\n")))
+	return len(p), nil
+}
 
+// NewRemoteZapCore returns the core writing the logs of the level to the file of the name in the remote
+// log server.
+func NewRemoteZapCore(name string, level zapcore.LevelEnabler) zapcore.Core {
+	cfg := GetServerConfig()
+	rw := NewRemoteTimeWriter()
+	var set string
+	if cfg.Enableset {
+		set = cfg.Setdivision
 	}
+	rw.InitServerInfo(cfg.App, cfg.Server, name, set)
+	return zaplog.NewWriterCore(remoteZapWriter{rw}, level)
 }
@@ -0,0 +1,154 @@
+package tars
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errSpillFull is returned when the spilled logs reach the max size.
+var errSpillFull = errors.New("remote log spill queue is full")
+
+// spillQueue keeps the batches of the logs which failed to send on the disk, one file per batch, and
+// returns them in order to replay. The batches left by the last process are replayed as well.
+type spillQueue struct {
+	// replayMu is held while replaying, so that a batch is replayed once by the writers sharing the queue
+	replayMu sync.Mutex
+	mu       sync.Mutex
+	dir      string
+	seq      uint64
+	files    []string
+	size     int64
+	maxSize  int64
+	created  bool
+}
+
+const spillSuffix = ".spill"
+
+var (
+	spillQueuesMu sync.Mutex
+	spillQueues   = make(map[string]*spillQueue)
+)
+
+// getSpillQueue returns the spill queue of the dir, which is shared by the writers of the same file.
+func getSpillQueue(dir string, maxSize int64) (*spillQueue, error) {
+	spillQueuesMu.Lock()
+	defer spillQueuesMu.Unlock()
+	if q, ok := spillQueues[dir]; ok {
+		return q, nil
+	}
+	q, err := newSpillQueue(dir, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	spillQueues[dir] = q
+	return q, nil
+}
+
+// newSpillQueue returns the queue of the batches left in dir, the dir is created on the first push.
+func newSpillQueue(dir string, maxSize int64) (*spillQueue, error) {
+	q := &spillQueue{dir: dir, maxSize: maxSize}
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.created = true
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), spillSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(info.Name(), spillSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		if seq > q.seq {
+			q.seq = seq
+		}
+		q.files = append(q.files, info.Name())
+		q.size += info.Size()
+	}
+	sort.Strings(q.files)
+	return q, nil
+}
+
+// push writes the batch to a new file.
+func (q *spillQueue) push(logs []string) error {
+	data, err := json.Marshal(logs)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxSize > 0 && q.size+int64(len(data)) > q.maxSize {
+		return errSpillFull
+	}
+	if !q.created {
+		if err := os.MkdirAll(q.dir, 0755); err != nil {
+			return err
+		}
+		q.created = true
+	}
+	q.seq++
+	// the sequence is padded to sort the files by name
+	name := fmt.Sprintf("%020d%s", q.seq, spillSuffix)
+	if err := ioutil.WriteFile(filepath.Join(q.dir, name), data, 0644); err != nil {
+		return err
+	}
+	q.files = append(q.files, name)
+	q.size += int64(len(data))
+	return nil
+}
+
+// peek returns the oldest batch, ok is false if the queue is empty. A broken file is removed and skipped.
+func (q *spillQueue) peek() (name string, logs []string, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.files) > 0 {
+		name = q.files[0]
+		data, err := ioutil.ReadFile(filepath.Join(q.dir, name))
+		if err == nil {
+			if err = json.Unmarshal(data, &logs); err == nil {
+				return name, logs, true
+			}
+		}
+		q.removeLocked(name)
+	}
+	return "", nil, false
+}
+
+// remove removes the batch which is replayed.
+func (q *spillQueue) remove(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeLocked(name)
+}
+
+func (q *spillQueue) removeLocked(name string) {
+	path := filepath.Join(q.dir, name)
+	if info, err := os.Stat(path); err == nil {
+		q.size -= info.Size()
+	}
+	os.Remove(path)
+	for i, f := range q.files {
+		if f == name {
+			q.files = append(q.files[:i], q.files[i+1:]...)
+			break
+		}
+	}
+}
+
+func (q *spillQueue) empty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.files) == 0
+}
@@ -0,0 +1,105 @@
+package tars
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSpillQueue(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int64
+		// batches are pushed in order
+		batches [][]string
+		// corrupt truncates the files of the indexes of the batches
+		corrupt []int
+		// want is the batches replayed in order
+		want [][]string
+		// full is the indexes of the batches failed to push
+		full []int
+	}{
+		{
+			name:    "replay in order",
+			batches: [][]string{{"a", "b"}, {"c"}, {"d", "e", "f"}},
+			want:    [][]string{{"a", "b"}, {"c"}, {"d", "e", "f"}},
+		},
+		{
+			name:    "drop when full",
+			maxSize: 20,
+			batches: [][]string{{"aaaa", "bbbb"}, {"cccc"}, {"d"}},
+			full:    []int{1},
+			want:    [][]string{{"aaaa", "bbbb"}, {"d"}},
+		},
+		{
+			name:    "skip corrupt files",
+			batches: [][]string{{"a"}, {"b"}, {"c"}},
+			corrupt: []int{0, 2},
+			want:    [][]string{{"b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := ioutil.TempDir("", "spill")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(base)
+			dir := filepath.Join(base, "remotelog")
+
+			q, err := newSpillQueue(dir, tt.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(dir); !os.IsNotExist(err) {
+				t.Fatalf("dir is created before the first push: %v", err)
+			}
+			var full []int
+			for i, b := range tt.batches {
+				if err := q.push(b); err == errSpillFull {
+					full = append(full, i)
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(full, tt.full) {
+				t.Fatalf("full batches: got %v, want %v", full, tt.full)
+			}
+			for _, i := range tt.corrupt {
+				name := q.files[i]
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(`["trunc`), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the batches left in the dir are replayed by a new queue as by the next process
+			q, err = newSpillQueue(dir, tt.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][]string
+			for {
+				name, logs, ok := q.peek()
+				if !ok {
+					break
+				}
+				got = append(got, logs)
+				q.remove(name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("replayed: got %v, want %v", got, tt.want)
+			}
+			if !q.empty() || q.size != 0 {
+				t.Fatalf("queue is not empty after replay: %v %d", q.files, q.size)
+			}
+			if err := q.push([]string{"next"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, logs, _ := q.peek(); !reflect.DeepEqual(logs, []string{"next"}) {
+				t.Fatalf("pushed after replay: %v", logs)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package tars
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mrand "math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
+)
+
+// sampleKey marks a sampled call of a call chain, it is carried by current.STATUS_SAMPLE_KEY in the
+// status of the request as "unid|depth|width". The depth of the first call is 1 and it grows by one
+// every hop, the width is the order of the call in the calls of the same request of the upper hop.
+type sampleKey struct {
+	unid  string
+	depth int32
+	width int32
+}
+
+func (k sampleKey) String() string {
+	return k.unid + "|" + strconv.Itoa(int(k.depth)) + "|" + strconv.Itoa(int(k.width))
+}
+
+func parseSampleKey(s string) (k sampleKey, ok bool) {
+	parts := strings.Split(s, "|")
+	if len(parts) != 3 || parts[0] == "" {
+		return k, false
+	}
+	depth, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return k, false
+	}
+	width, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return k, false
+	}
+	return sampleKey{unid: parts[0], depth: int32(depth), width: int32(width)}, true
+}
+
+// sampleRequest returns the sample key of the call to the servant and the width of its upper call. The
+// call is sampled if the request of the server in ctx is sampled, or one in sample-rate calls of the
+// client config. The calls to the framework objs are never sampled, or the reporting of the samples would be sampled.
+func sampleRequest(ctx context.Context, servant string) (k sampleKey, parentWidth int32, ok bool) {
+	cfg := GetClientConfig()
+	if cfg == nil || frameworkObj(servant, cfg, GetServerConfig()) {
+		return k, 0, false
+	}
+	if s, ok := current.GetSampleKey(ctx); ok {
+		if parent, ok := parseSampleKey(s); ok {
+			return sampleKey{unid: parent.unid, depth: parent.depth + 1, width: current.NextSampleWidth(ctx)}, parent.width, true
+		}
+	}
+	if cfg.SampleRate <= 0 || mrand.Intn(cfg.SampleRate) != 0 {
+		return k, 0, false
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return k, 0, false
+	}
+	return sampleKey{unid: hex.EncodeToString(b), depth: 1, width: 1}, 0, true
+}
+
+// frameworkObj returns whether the servant is one of the framework objs called by the communicator itself:
+// the stat, the property, the locator, the log, the notify, the config and the node.
+func frameworkObj(servant string, clt *clientConfig, svr *serverConfig) bool {
+	objs := []string{clt.Stat, clt.Property, clt.Locator}
+	if svr != nil {
+		objs = append(objs, svr.Log, svr.Notify, svr.Config, svr.Node)
+	}
+	for _, obj := range objs {
+		if obj != "" && servant == strings.SplitN(obj, "@", 2)[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// reportSample reports the sampled call to the stat in batches.
+func reportSample(msg *Message, k sampleKey, parentWidth int32) {
+	cfg := GetServerConfig()
+	if cfg == nil || statCollector == nil {
+		return
+	}
+	sample := statf.StatSampleMsg{
+		Unid:          k.unid,
+		MasterName:    cfg.App + "." + cfg.Server,
+		SlaveName:     msg.Req.SServantName,
+		InterfaceName: msg.Req.SFuncName,
+		MasterIp:      cfg.LocalIP,
+		Depth:         k.depth,
+		Width:         k.width,
+		ParentWidth:   parentWidth,
+	}
+	if sNames := strings.Split(msg.Req.SServantName, "."); len(sNames) >= 2 {
+		sample.SlaveName = sNames[0] + "." + sNames[1]
+	}
+	if msg.Adp != nil {
+		sample.SlaveIp = msg.Adp.GetPoint().Host
+	}
+	statCollector.pushBackSample(sample)
+}
+
+// reportServerSample reports the hop of the sampled request on the server, so that the chain has the hop even
+// if the client does not report it. The master is only known by its ip, and the depth and the width are the
+// ones of the call of the client.
+func reportServerSample(ctx context.Context, req *requestf.RequestPacket) {
+	cfg := GetServerConfig()
+	if cfg == nil || statCollector == nil {
+		return
+	}
+	s, ok := current.GetSampleKey(ctx)
+	if !ok {
+		return
+	}
+	k, ok := parseSampleKey(s)
+	if !ok {
+		return
+	}
+	sample := statf.StatSampleMsg{
+		Unid:          k.unid,
+		SlaveName:     cfg.App + "." + cfg.Server,
+		InterfaceName: req.SFuncName,
+		SlaveIp:       cfg.LocalIP,
+		Depth:         k.depth,
+		Width:         k.width,
+	}
+	sample.MasterIp, _ = current.GetClientIPFromContext(ctx)
+	statCollector.pushBackSample(sample)
+}
@@ -0,0 +1,118 @@
+package tars
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
+)
+
+// setSampleConfig sets the configs and the stat helper for the sampling, and returns the function to restore them.
+func setSampleConfig(rate int) (chan statf.StatSampleMsg, func()) {
+	svr, clt, stat := svrCfg, cltCfg, statCollector
+	svrCfg = &serverConfig{App: "App", Server: "Server", LocalIP: "10.0.0.9",
+		Log: "tars.tarslog.LogObj", Notify: "tars.tarsnotify.NotifyObj", Config: "tars.tarsconfig.ConfigObj",
+		Node: "tars.tarsnode.ServerObj@tcp -h 127.0.0.1 -p 19386"}
+	cltCfg = &clientConfig{SampleRate: rate, Stat: "tars.tarsstat.StatObj",
+		Property: "tars.tarsproperty.PropertyObj", Locator: "tars.tarsregistry.QueryObj@tcp -h 127.0.0.1 -p 17890"}
+	ch := make(chan statf.StatSampleMsg, 10)
+	statCollector = &StatFHelper{chSample: ch}
+	return ch, func() {
+		svrCfg, cltCfg, statCollector = svr, clt, stat
+	}
+}
+
+func TestParseSampleKey(t *testing.T) {
+	tests := []struct {
+		s    string
+		want sampleKey
+		ok   bool
+	}{
+		{"abc|1|1", sampleKey{unid: "abc", depth: 1, width: 1}, true},
+		{"abc|3|12", sampleKey{unid: "abc", depth: 3, width: 12}, true},
+		{"|1|1", sampleKey{}, false},
+		{"abc|1", sampleKey{}, false},
+		{"abc|x|1", sampleKey{}, false},
+		{"abc|1|y", sampleKey{}, false},
+		{"abc|1|1|1", sampleKey{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSampleKey(tt.s)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSampleKey(%q): got %v %v, want %v %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+		if ok && got.String() != tt.s {
+			t.Errorf("String: got %s, want %s", got.String(), tt.s)
+		}
+	}
+}
+
+// TestSampleRequest tests the calls of a sampled request are sampled one hop deeper with the widths in
+// order, and the calls to the framework objs are never sampled.
+func TestSampleRequest(t *testing.T) {
+	_, restore := setSampleConfig(1)
+	defer restore()
+
+	ctx := current.ContextWithTarsCurrent(context.Background())
+	current.SetSampleKey(ctx, "abc|2|3")
+	for width := int32(1); width <= 3; width++ {
+		k, parentWidth, ok := sampleRequest(ctx, "App.Server.HelloObj")
+		if !ok {
+			t.Fatal("call of the sampled request is not sampled")
+		}
+		if want := (sampleKey{unid: "abc", depth: 3, width: width}); k != want || parentWidth != 3 {
+			t.Fatalf("sample: got %v %d, want %v 3", k, parentWidth, want)
+		}
+	}
+
+	k, parentWidth, ok := sampleRequest(context.Background(), "App.Server.HelloObj")
+	if !ok || len(k.unid) != 32 || k.depth != 1 || k.width != 1 || parentWidth != 0 {
+		t.Fatalf("first call of the chain: got %v %d %v", k, parentWidth, ok)
+	}
+
+	for _, obj := range []string{"tars.tarsstat.StatObj", "tars.tarsproperty.PropertyObj", "tars.tarsregistry.QueryObj",
+		"tars.tarslog.LogObj", "tars.tarsnotify.NotifyObj", "tars.tarsconfig.ConfigObj", "tars.tarsnode.ServerObj"} {
+		if _, _, ok := sampleRequest(ctx, obj); ok {
+			t.Errorf("call to %s is sampled", obj)
+		}
+	}
+
+	_, restore0 := setSampleConfig(0)
+	defer restore0()
+	if _, _, ok := sampleRequest(context.Background(), "App.Server.HelloObj"); ok {
+		t.Fatal("call is sampled with the zero rate")
+	}
+}
+
+// TestReportSample tests the client reports the call and the server reports its own hop.
+func TestReportSample(t *testing.T) {
+	ch, restore := setSampleConfig(1)
+	defer restore()
+
+	msg := &Message{Req: &requestf.RequestPacket{SServantName: "Peer.Server.HelloObj", SFuncName: "echo"}}
+	reportSample(msg, sampleKey{unid: "abc", depth: 2, width: 3}, 1)
+	want := statf.StatSampleMsg{Unid: "abc", MasterName: "App.Server", SlaveName: "Peer.Server", InterfaceName: "echo",
+		MasterIp: "10.0.0.9", Depth: 2, Width: 3, ParentWidth: 1}
+	if got := <-ch; got != want {
+		t.Fatalf("client sample: got %+v, want %+v", got, want)
+	}
+
+	ctx := current.ContextWithTarsCurrent(context.Background())
+	current.SetClientIPWithContext(ctx, "10.0.0.1")
+	current.SetSampleKey(ctx, "abc|2|3")
+	reportServerSample(ctx, &requestf.RequestPacket{SServantName: "App.Server.HelloObj", SFuncName: "echo"})
+	want = statf.StatSampleMsg{Unid: "abc", SlaveName: "App.Server", InterfaceName: "echo",
+		MasterIp: "10.0.0.1", SlaveIp: "10.0.0.9", Depth: 2, Width: 3}
+	if got := <-ch; got != want {
+		t.Fatalf("server sample: got %+v, want %+v", got, want)
+	}
+
+	reportServerSample(current.ContextWithTarsCurrent(context.Background()), &requestf.RequestPacket{SFuncName: "echo"})
+	select {
+	case got := <-ch:
+		t.Fatalf("request not sampled is reported: %+v", got)
+	default:
+	}
+}
@@ -2,7 +2,6 @@ package tars
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/MacgradyHuang/TarsGo/tars/util/zaplog"
 	"go.uber.org/zap"
@@ -39,6 +38,8 @@ type ServantProxy struct {
 	version  int16
 	proto    model.Protocol
 	queueLen int32
+
+	interceptors []ClientInterceptor
 }
 
 func newServantProxy(comm *Communicator, objName string) *ServantProxy {
@@ -100,6 +101,15 @@ func (s *ServantProxy) Tars_invoke(ctx context.Context, ctype byte,
 		status[current.STATUS_DYED_KEY] = dyeingKey
 		msgType = basef.TARSMESSAGETYPEDYED
 	}
+	// 将ctx中的采样信息传入到request中
+	sample, parentWidth, sampled := sampleRequest(ctx, s.name)
+	if sampled {
+		if status == nil {
+			status = make(map[string]string)
+		}
+		status[current.STATUS_SAMPLE_KEY] = sample.String()
+		msgType |= basef.TARSMESSAGETYPESAMPLE
+	}
 
 	req := requestf.RequestPacket{
 		IVersion:     s.version,
@@ -113,6 +123,7 @@ func (s *ServantProxy) Tars_invoke(ctx context.Context, ctype byte,
 		Status:       status,
 		IMessageType: msgType,
 	}
+	ctx = requestLogContext(ctx, &req)
 	msg := &Message{Req: &req, Ser: s, Resp: resp}
 	msg.Init()
 	timeout := time.Duration(s.timeout) * time.Millisecond
@@ -128,6 +139,32 @@ func (s *ServantProxy) Tars_invoke(ctx context.Context, ctype byte,
 	}
 	var err error
 	s.manager.preInvoke()
+	err = chainClientInterceptor(ctx, msg, timeout, joinClientInterceptors(s.interceptors), s.invoke)
+	s.manager.postInvoke()
+	if sampled {
+		reportSample(msg, sample, parentWidth)
+	}
+
+	if err != nil {
+		msg.End()
+		zaplog.Error("Invoke error", zap.String("Name", s.name), zap.String("FuncName", sFuncName), zap.Int64("Cost", msg.Cost()), zap.Error(err))
+		if msg.Resp == nil {
+			ReportStat(msg, STAT_SUCCESS, STAT_SUCCESS, STAT_FAILED)
+		} else if msg.Status == basef.TARSINVOKETIMEOUT {
+			ReportStat(msg, STAT_SUCCESS, STAT_FAILED, STAT_SUCCESS)
+		} else {
+			ReportStat(msg, STAT_SUCCESS, STAT_SUCCESS, STAT_FAILED)
+		}
+		return err
+	}
+	msg.End()
+	*resp = *msg.Resp
+	ReportStat(msg, STAT_FAILED, STAT_SUCCESS, STAT_SUCCESS)
+	return err
+}
+
+// invoke runs the filters and the rpc, it is the last step of the interceptors.
+func (s *ServantProxy) invoke(ctx context.Context, msg *Message, timeout time.Duration) (err error) {
 	if allFilters.cf != nil {
 		err = allFilters.cf(ctx, msg, s.doInvoke, timeout)
 	} else {
@@ -148,33 +185,16 @@ func (s *ServantProxy) Tars_invoke(ctx context.Context, ctype byte,
 			}
 		}
 	}
-	s.manager.postInvoke()
-
-	if err != nil {
-		msg.End()
-		zaplog.Error("Invoke error", zap.String("Name", s.name), zap.String("FuncName", sFuncName), zap.Int64("Cost", msg.Cost()), zap.Error(err))
-		if msg.Resp == nil {
-			ReportStat(msg, STAT_SUCCESS, STAT_SUCCESS, STAT_FAILED)
-		} else if msg.Status == basef.TARSINVOKETIMEOUT {
-			ReportStat(msg, STAT_SUCCESS, STAT_FAILED, STAT_SUCCESS)
-		} else {
-			ReportStat(msg, STAT_SUCCESS, STAT_SUCCESS, STAT_FAILED)
-		}
-		return err
-	}
-	msg.End()
-	*resp = *msg.Resp
-	ReportStat(msg, STAT_FAILED, STAT_SUCCESS, STAT_SUCCESS)
 	return err
 }
 
 func (s *ServantProxy) doInvoke(ctx context.Context, msg *Message, timeout time.Duration) error {
 	adp, needCheck := s.manager.SelectAdapterProxy(msg)
 	if adp == nil {
-		return errors.New("no adapter Proxy selected:" + msg.Req.SServantName)
+		return NewError(basef.TARSADAPTERNULL, "no adapter Proxy selected:"+msg.Req.SServantName)
 	}
 	if s.queueLen > ObjQueueMax {
-		return errors.New("invoke queue is full:" + msg.Req.SServantName)
+		return NewError(InvokeQueueFullCode, "invoke queue is full:"+msg.Req.SServantName)
 	}
 	ep := adp.GetPoint()
 	current.SetServerIPWithContext(ctx, ep.Host)
@@ -202,7 +222,7 @@ func (s *ServantProxy) doInvoke(ctx context.Context, msg *Message, timeout time.
 		msg.Status = basef.TARSINVOKETIMEOUT
 		adp.failAdd()
 		msg.End()
-		return fmt.Errorf("request timeout, begin time:%d, cost:%d, obj:%s, func:%s, addr:(%s:%d), reqid:%d",
+		return Errorf(basef.TARSINVOKETIMEOUT, "request timeout, begin time:%d, cost:%d, obj:%s, func:%s, addr:(%s:%d), reqid:%d",
 			msg.BeginTime, msg.Cost(), msg.Req.SServantName, msg.Req.SFuncName, adp.point.Host, adp.point.Port, msg.Req.IRequestId)
 	case msg.Resp = <-readCh:
 		if needCheck {
@@ -214,11 +234,8 @@ func (s *ServantProxy) doInvoke(ctx context.Context, msg *Message, timeout time.
 		}
 		adp.succssAdd()
 		if msg.Resp != nil {
-			if msg.Status != basef.TARSSERVERSUCCESS || msg.Resp.IRet != 0 {
-				if msg.Resp.SResultDesc == "" {
-					return fmt.Errorf("basef error code %d", msg.Resp.IRet)
-				}
-				return errors.New(msg.Resp.SResultDesc)
+			if err := responseError(msg.Resp); err != nil {
+				return err
 			}
 		} else {
 			zaplog.Debug("recv nil Resp, close of the readCh?")
@@ -12,16 +12,16 @@ import (
 )
 
 //AddServant add dispatch and interface for object.
-func AddServant(v dispatch, f interface{}, obj string) {
-	addServantCommon(v, f, obj, false)
+func AddServant(v dispatch, f interface{}, obj string, interceptors ...ServerInterceptor) {
+	addServantCommon(v, f, obj, false, interceptors)
 }
 
 //AddServantWithContext add dispatch and interface for object, which have ctx,context
-func AddServantWithContext(v dispatch, f interface{}, obj string) {
-	addServantCommon(v, f, obj, true)
+func AddServantWithContext(v dispatch, f interface{}, obj string, interceptors ...ServerInterceptor) {
+	addServantCommon(v, f, obj, true, interceptors)
 }
 
-func addServantCommon(v dispatch, f interface{}, obj string, withContext bool) {
+func addServantCommon(v dispatch, f interface{}, obj string, withContext bool, interceptors []ServerInterceptor) {
 	objRunList = append(objRunList, obj)
 	cfg, ok := tarsConfig[obj]
 	if !ok {
@@ -36,8 +36,37 @@ func addServantCommon(v dispatch, f interface{}, obj string, withContext bool) {
 	zaplog.Debug("add: ", zap.Any("Config", cfg))
 
 	jp := NewTarsProtocol(v, f, withContext)
-	s := transport.NewTarsServer(jp, cfg)
-	goSvrs[obj] = s
+	jp.interceptors = interceptors
+	// the servants on the same address share the server, the requests are routed by the servant name.
+	// There is one adapter config per address, the one of the first servant, the others must match it.
+	addr := cfg.Proto + "://" + cfg.Address
+	mux, ok := servantMuxes[addr]
+	if !ok {
+		mux = newServantMux()
+		mux.svr = transport.NewTarsServer(jp, cfg)
+		mux.cfg = cfg
+		mux.obj = obj
+		servantMuxes[addr] = mux
+	} else if *cfg != *mux.cfg || adapterThreads(obj) != adapterThreads(mux.obj) {
+		msg := fmt.Sprintf("adapter config of %s differs from the one of %s on %s, which is used", obj, mux.obj, addr)
+		ReportNotifyInfo(NOTIFY_ERROR, msg)
+		zaplog.Error(msg, zap.Any("Config", cfg), zap.Any("Used", mux.cfg))
+	}
+	mux.add(obj, jp)
+	goSvrs[obj] = mux.svr
+}
+
+// adapterThreads returns the threads of the adapter of the obj.
+func adapterThreads(obj string) int {
+	if svrCfg == nil {
+		return 0
+	}
+	for _, adapter := range svrCfg.Adapters {
+		if adapter.Obj == obj {
+			return adapter.Threads
+		}
+	}
+	return 0
 }
 
 // AddHttpServant add http servant handler with default exceptionStatusChecker for obj.
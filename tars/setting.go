@@ -50,6 +50,12 @@ const (
 	//log
 	defualtRotateN      = 10
 	defaultRotateSizeMB = 100
+	//LogEncoding is the encoding of the framework log, json or console
+	LogEncoding = "json"
+	//LogRetention is the days to keep the framework log rolled by day
+	LogRetention = 3
+	//LogHTTPServer serves the level of the framework log by http
+	LogHTTPServer = true
 
 	//remotelog
 
@@ -59,6 +65,14 @@ const (
 	remoteLogMaxNumOneTime int = 2000
 	//remoteLogInterval log report interval, defaultvalue is 1000 milliseconds
 	remoteLogInterval time.Duration = 1000 * time.Millisecond
+	//remoteLogRetry is the times to retry a failed report of the logs
+	remoteLogRetry int = 3
+	//remoteLogRetryBackoff is the wait before the first retry, it is doubled every retry
+	remoteLogRetryBackoff time.Duration = 100 * time.Millisecond
+	//remoteLogSpillMaxSize is the max bytes of the logs spilled to the disk of a remote log
+	remoteLogSpillMaxSize int64 = 500 * 1024 * 1024
+	//remoteLogOverflowBatches is the batches of the logs written when the queue is full waiting to spill
+	remoteLogOverflowBatches int = 16
 
 	//report
 
@@ -68,6 +82,12 @@ const (
 	StatReportInterval = 10000
 	// StatReportChannelBufLen stat report channel len
 	StatReportChannelBufLen = 100000
+	// StatBuckets are the upper bounds in ms of the buckets of the response time in the stat
+	StatBuckets = "5,10,50,100,200,500,1000,2000,3000"
+	// SampleReportBatch is the max number of the samples reported in one time
+	SampleReportBatch = 500
+	// PropertyLabelsLimit is the max number of the label sets of a labelled property
+	PropertyLabelsLimit = 1000
 
 	//mainloop
 
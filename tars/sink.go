@@ -0,0 +1,421 @@
+package tars
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/propertyf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+)
+
+// StatSink receives the stats of a stat report interval and the sampled calls.
+type StatSink interface {
+	ReportMicMsg(msg map[statf.StatMicMsgHead]statf.StatMicMsgBody, fromClient bool) error
+	ReportSampleMsg(msg []statf.StatSampleMsg) error
+}
+
+// PropertySink receives the properties of a property report interval.
+type PropertySink interface {
+	ReportPropMsg(msg map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody) error
+}
+
+// the schemes of the stat and the property in the client config for the sinks other than the tars servers
+const (
+	sinkSchemeFile   = "file://"
+	sinkSchemeStatsD = "statsd://"
+)
+
+var (
+	sinkMu       sync.Mutex
+	statSink     StatSink
+	propertySink PropertySink
+)
+
+// SetStatSink sets the sink of the stats instead of the one of the stat in the client config, it takes
+// effect from the next report.
+func SetStatSink(sink StatSink) {
+	sinkMu.Lock()
+	statSink = sink
+	sinkMu.Unlock()
+}
+
+// SetPropertySink sets the sink of the properties instead of the one of the property in the client
+// config, it takes effect from the next report.
+func SetPropertySink(sink PropertySink) {
+	sinkMu.Lock()
+	propertySink = sink
+	sinkMu.Unlock()
+}
+
+func getStatSink() StatSink {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	return statSink
+}
+
+func getPropertySink() PropertySink {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	return propertySink
+}
+
+// urlSink is the sink of both the stats and the properties given by the url in the client config.
+type urlSink interface {
+	StatSink
+	PropertySink
+}
+
+// newURLSink returns the sink of the node in the client config: "file:///path" for NewFileSink and
+// "statsd://host:port" for NewStatsDSink, ok is false if the node is the obj of a tars server.
+func newURLSink(node string) (sink urlSink, ok bool, err error) {
+	switch {
+	case strings.HasPrefix(node, sinkSchemeFile):
+		s, err := NewFileSink(strings.TrimPrefix(node, sinkSchemeFile))
+		if err != nil {
+			return nil, true, err
+		}
+		return s, true, nil
+	case strings.HasPrefix(node, sinkSchemeStatsD):
+		s, err := NewStatsDSink(strings.TrimPrefix(node, sinkSchemeStatsD))
+		if err != nil {
+			return nil, true, err
+		}
+		return s, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// newStatSink returns the sink of the stat in the client config, the one of newURLSink or the tarsstat
+// server of the obj.
+func newStatSink(comm *Communicator, node string) (StatSink, error) {
+	sink, ok, err := newURLSink(node)
+	if !ok {
+		return NewTarsStatSink(comm, node), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// newPropertySink returns the sink of the property in the client config like newStatSink.
+func newPropertySink(comm *Communicator, node string) (PropertySink, error) {
+	sink, ok, err := newURLSink(node)
+	if !ok {
+		return NewTarsPropertySink(comm, node), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// TarsStatSink reports the stats to the tarsstat server.
+type TarsStatSink struct {
+	sf *statf.StatF
+}
+
+// NewTarsStatSink returns the sink of the tarsstat server of the obj.
+func NewTarsStatSink(comm *Communicator, obj string) *TarsStatSink {
+	s := &TarsStatSink{sf: new(statf.StatF)}
+	comm.StringToProxy(obj, s.sf)
+	return s
+}
+
+// ReportMicMsg reports the stats to the tarsstat server.
+func (s *TarsStatSink) ReportMicMsg(msg map[statf.StatMicMsgHead]statf.StatMicMsgBody, fromClient bool) error {
+	_, err := s.sf.ReportMicMsg(msg, fromClient)
+	return err
+}
+
+// ReportSampleMsg reports the sampled calls to the tarsstat server.
+func (s *TarsStatSink) ReportSampleMsg(msg []statf.StatSampleMsg) error {
+	_, err := s.sf.ReportSampleMsg(msg)
+	return err
+}
+
+// TarsPropertySink reports the properties to the tarsproperty server.
+type TarsPropertySink struct {
+	pf *propertyf.PropertyF
+}
+
+// NewTarsPropertySink returns the sink of the tarsproperty server of the obj.
+func NewTarsPropertySink(comm *Communicator, obj string) *TarsPropertySink {
+	s := &TarsPropertySink{pf: new(propertyf.PropertyF)}
+	comm.StringToProxy(obj, s.pf)
+	return s
+}
+
+// ReportPropMsg reports the properties to the tarsproperty server.
+func (s *TarsPropertySink) ReportPropMsg(msg map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody) error {
+	_, err := s.pf.ReportPropMsg(msg)
+	return err
+}
+
+// FileSink appends the stats, the sampled calls and the properties to a file in json lines, one line for
+// each head with the type "stat", "sample" or "property".
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+type fileSinkRecord struct {
+	Time       string      `json:"time"`
+	Type       string      `json:"type"`
+	FromClient *bool       `json:"fromClient,omitempty"`
+	Head       interface{} `json:"head,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// NewFileSink opens the file to append the records.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+func (s *FileSink) write(records []fileSinkRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.file.Write(buf.Bytes())
+	return err
+}
+
+// ReportMicMsg appends the stats.
+func (s *FileSink) ReportMicMsg(msg map[statf.StatMicMsgHead]statf.StatMicMsgBody, fromClient bool) error {
+	now := time.Now().Format(time.RFC3339)
+	records := make([]fileSinkRecord, 0, len(msg))
+	for head, body := range msg {
+		records = append(records, fileSinkRecord{Time: now, Type: "stat", FromClient: &fromClient, Head: head, Body: body})
+	}
+	return s.write(records)
+}
+
+// ReportSampleMsg appends the sampled calls.
+func (s *FileSink) ReportSampleMsg(msg []statf.StatSampleMsg) error {
+	now := time.Now().Format(time.RFC3339)
+	records := make([]fileSinkRecord, 0, len(msg))
+	for _, sample := range msg {
+		records = append(records, fileSinkRecord{Time: now, Type: "sample", Body: sample})
+	}
+	return s.write(records)
+}
+
+// ReportPropMsg appends the properties.
+func (s *FileSink) ReportPropMsg(msg map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody) error {
+	now := time.Now().Format(time.RFC3339)
+	records := make([]fileSinkRecord, 0, len(msg))
+	for head, body := range msg {
+		records = append(records, fileSinkRecord{Time: now, Type: "property", Head: head, Body: body})
+	}
+	return s.write(records)
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// statsDMaxPacket is the max size of a udp packet to the StatsD server, which fits the common mtu.
+const statsDMaxPacket = 1432
+
+// StatsDSink sends the stats and the properties to a StatsD server over udp. The stats are
+// tars.stat.<client|server>.<slave>.<interface>.<count|timeout|exception> counters and
+// .<avg|max|min>_rsp_time gauges in ms, the properties are tars.property.<module>.<name>.<policy>,
+// counters for Sum and Count and gauges for the others, Distr is a counter of every range. The
+// sampled calls are not sent.
+type StatsDSink struct {
+	conn net.Conn
+}
+
+// NewStatsDSink returns the sink of the StatsD server of the address.
+func NewStatsDSink(addr string) (*StatsDSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsDSink{conn: conn}, nil
+}
+
+var statsDEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", " ", "_", "\n", "_")
+
+func (s *StatsDSink) send(lines []string) error {
+	var buf bytes.Buffer
+	var err error
+	for _, l := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(l) > statsDMaxPacket {
+			if _, e := s.conn.Write(buf.Bytes()); e != nil {
+				err = e
+			}
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(l)
+	}
+	if buf.Len() > 0 {
+		if _, e := s.conn.Write(buf.Bytes()); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// ReportMicMsg sends the stats.
+func (s *StatsDSink) ReportMicMsg(msg map[statf.StatMicMsgHead]statf.StatMicMsgBody, fromClient bool) error {
+	side := "server"
+	if fromClient {
+		side = "client"
+	}
+	var lines []string
+	for head, body := range msg {
+		name := "tars.stat." + side + "." + statsDEscaper.Replace(head.SlaveName) + "." + statsDEscaper.Replace(head.InterfaceName)
+		lines = append(lines,
+			fmt.Sprintf("%s.count:%d|c", name, body.Count),
+			fmt.Sprintf("%s.timeout:%d|c", name, body.TimeoutCount),
+			fmt.Sprintf("%s.exception:%d|c", name, body.ExecCount),
+			fmt.Sprintf("%s.max_rsp_time:%d|g", name, body.MaxRspTime),
+			fmt.Sprintf("%s.min_rsp_time:%d|g", name, body.MinRspTime),
+		)
+		if n := int64(body.Count + body.TimeoutCount + body.ExecCount); n > 0 {
+			lines = append(lines, fmt.Sprintf("%s.avg_rsp_time:%d|g", name, body.TotalRspTime/n))
+		}
+	}
+	return s.send(lines)
+}
+
+// ReportSampleMsg ignores the sampled calls.
+func (s *StatsDSink) ReportSampleMsg(msg []statf.StatSampleMsg) error {
+	return nil
+}
+
+// ReportPropMsg sends the properties.
+func (s *StatsDSink) ReportPropMsg(msg map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody) error {
+	var lines []string
+	for head, body := range msg {
+		name := "tars.property." + statsDEscaper.Replace(head.ModuleName) + "." + statsDEscaper.Replace(head.PropertyName)
+		for _, info := range body.VInfo {
+			switch info.Policy {
+			case "Sum", "Count":
+				lines = append(lines, name+"."+strings.ToLower(info.Policy)+":"+info.Value+"|c")
+			case "Distr":
+				for _, r := range strings.Split(info.Value, ",") {
+					kv := strings.SplitN(r, "|", 2)
+					if len(kv) == 2 {
+						lines = append(lines, name+".distr."+kv[0]+":"+kv[1]+"|c")
+					}
+				}
+			default:
+				if _, err := strconv.ParseFloat(info.Value, 64); err == nil {
+					lines = append(lines, name+"."+strings.ToLower(info.Policy)+":"+info.Value+"|g")
+				}
+			}
+		}
+	}
+	return s.send(lines)
+}
+
+// Close closes the connection.
+func (s *StatsDSink) Close() error {
+	return s.conn.Close()
+}
+
+// MemorySink keeps the reports in memory, it is for the tests.
+type MemorySink struct {
+	mu         sync.Mutex
+	stats      []StatInfo
+	fromServer []StatInfo
+	samples    []statf.StatSampleMsg
+	props      map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody
+}
+
+// NewMemorySink returns an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{props: make(map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody)}
+}
+
+// ReportMicMsg keeps the stats.
+func (s *MemorySink) ReportMicMsg(msg map[statf.StatMicMsgHead]statf.StatMicMsgBody, fromClient bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for head, body := range msg {
+		if fromClient {
+			s.stats = append(s.stats, StatInfo{Head: head, Body: body})
+		} else {
+			s.fromServer = append(s.fromServer, StatInfo{Head: head, Body: body})
+		}
+	}
+	return nil
+}
+
+// ReportSampleMsg keeps the sampled calls.
+func (s *MemorySink) ReportSampleMsg(msg []statf.StatSampleMsg) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, msg...)
+	return nil
+}
+
+// ReportPropMsg keeps the properties, the later report of a head replaces the earlier one.
+func (s *MemorySink) ReportPropMsg(msg map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for head, body := range msg {
+		s.props[head] = body
+	}
+	return nil
+}
+
+// Stats returns the reported stats of the client calls, or the server calls if fromServer.
+func (s *MemorySink) Stats(fromServer bool) []StatInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fromServer {
+		return append([]StatInfo(nil), s.fromServer...)
+	}
+	return append([]StatInfo(nil), s.stats...)
+}
+
+// Samples returns the reported sampled calls.
+func (s *MemorySink) Samples() []statf.StatSampleMsg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]statf.StatSampleMsg(nil), s.samples...)
+}
+
+// Property returns the last reported values of the property by the policy.
+func (s *MemorySink) Property(name string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var values map[string]string
+	for head, body := range s.props {
+		if head.PropertyName != name {
+			continue
+		}
+		if values == nil {
+			values = make(map[string]string)
+		}
+		for _, info := range body.VInfo {
+			values[info.Policy] = info.Value
+		}
+	}
+	return values
+}
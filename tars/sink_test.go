@@ -0,0 +1,220 @@
+package tars
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/propertyf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+)
+
+// TestNewSink tests the sinks of the urls in the client config.
+func TestNewSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		node string
+		want interface{}
+		err  bool
+	}{
+		{node: "file://" + filepath.Join(dir, "stat.json"), want: &FileSink{}},
+		{node: "statsd://127.0.0.1:8125", want: &StatsDSink{}},
+		{node: "file://" + filepath.Join(dir, "nodir", "stat.json"), err: true},
+		{node: "statsd://127.0.0.1", err: true},
+	}
+	for _, tt := range tests {
+		stat, err := newStatSink(nil, tt.node)
+		prop, perr := newPropertySink(nil, tt.node)
+		if tt.err {
+			if err == nil || perr == nil || stat != nil || prop != nil {
+				t.Errorf("%s: got %v %v, %v %v, want errors", tt.node, stat, err, prop, perr)
+			}
+			continue
+		}
+		if err != nil || perr != nil {
+			t.Fatalf("%s: %v %v", tt.node, err, perr)
+		}
+		if reflect.TypeOf(stat) != reflect.TypeOf(tt.want) || reflect.TypeOf(prop) != reflect.TypeOf(tt.want) {
+			t.Errorf("%s: got %T %T, want %T", tt.node, stat, prop, tt.want)
+		}
+	}
+}
+
+// TestStatSink tests the stats of an interval and the samples are reported to the sink set by SetStatSink.
+func TestStatSink(t *testing.T) {
+	sink := NewMemorySink()
+	SetStatSink(sink)
+	defer SetStatSink(nil)
+
+	s := &StatFHelper{
+		mStatInfo:            make(map[statf.StatMicMsgHead]statf.StatMicMsgBody),
+		mStatCount:           make(map[statf.StatMicMsgHead]int),
+		mStatInfoFromServer:  make(map[statf.StatMicMsgHead]statf.StatMicMsgBody),
+		mStatCountFromServer: make(map[statf.StatMicMsgHead]int),
+		buckets:              []int32{10, 100},
+	}
+	client := statf.StatMicMsgHead{MasterName: "App.Server", SlaveName: "Peer.Server", InterfaceName: "echo"}
+	server := statf.StatMicMsgHead{MasterName: "Peer.Server", SlaveName: "App.Server", InterfaceName: "echo"}
+	s.collectMsg(StatInfo{Head: client, Body: statf.StatMicMsgBody{Count: 1, TotalRspTime: 5, MaxRspTime: 5, MinRspTime: 5}}, s.mStatInfo, s.mStatCount)
+	s.collectMsg(StatInfo{Head: client, Body: statf.StatMicMsgBody{ExecCount: 1, TotalRspTime: 50, MaxRspTime: 50, MinRspTime: 50}}, s.mStatInfo, s.mStatCount)
+	s.collectMsg(StatInfo{Head: server, Body: statf.StatMicMsgBody{Count: 1, TotalRspTime: 1, MaxRspTime: 1, MinRspTime: 1}}, s.mStatInfoFromServer, s.mStatCountFromServer)
+	s.samples = []statf.StatSampleMsg{{Unid: "abc", Depth: 1, Width: 1}}
+
+	s.reportAndClear("mStatInfo", true)
+	s.reportAndClear("mStatInfoFromServer", false)
+	s.reportSamples()
+
+	want := []StatInfo{{Head: client, Body: statf.StatMicMsgBody{Count: 1, ExecCount: 1, TotalRspTime: 55, MaxRspTime: 50,
+		MinRspTime: 5, IntervalCount: map[int32]int32{10: 1, 100: 1}}}}
+	if got := sink.Stats(false); !reflect.DeepEqual(got, want) {
+		t.Fatalf("client stats: got %+v, want %+v", got, want)
+	}
+	want = []StatInfo{{Head: server, Body: statf.StatMicMsgBody{Count: 1, TotalRspTime: 1, MaxRspTime: 1,
+		MinRspTime: 1, IntervalCount: map[int32]int32{10: 1}}}}
+	if got := sink.Stats(true); !reflect.DeepEqual(got, want) {
+		t.Fatalf("server stats: got %+v, want %+v", got, want)
+	}
+	if got := sink.Samples(); len(got) != 1 || got[0].Unid != "abc" || len(s.samples) != 0 {
+		t.Fatalf("samples: got %+v, left %+v", got, s.samples)
+	}
+
+	// the next interval is empty and reports nothing
+	s.reportAndClear("mStatInfo", true)
+	if got := sink.Stats(false); len(got) != 1 {
+		t.Fatalf("empty interval is reported: %+v", got)
+	}
+}
+
+// TestPropertySink tests the properties are reported to the sink set by SetPropertySink.
+func TestPropertySink(t *testing.T) {
+	sink := NewMemorySink()
+	SetPropertySink(sink)
+	defer SetPropertySink(nil)
+	defer resetPropertyHelper()()
+	cfg := svrCfg
+	svrCfg = &serverConfig{App: "App", Server: "Server", LocalIP: "10.0.0.9"}
+	defer func() { svrCfg = cfg }()
+
+	request := CreatePropertyReportWithLabels("request", map[string]string{"api": "login", PropertyLabelIP: "10.0.0.1"},
+		NewSum(), NewCount(), NewMax())
+	for _, v := range []int{3, 7} {
+		request.Report(v)
+	}
+	CreatePropertyReport("idle", NewSum())
+	ProHelper.ReportToServer()
+
+	want := map[string]string{"Sum": "10", "Count": "2", "Max": "7"}
+	if got := sink.Property("request{api=login}"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("property: got %v, want %v", got, want)
+	}
+	for head := range sink.props {
+		if head.PropertyName == "request{api=login}" && (head.Ip != "10.0.0.1" || head.ModuleName != "App.Server") {
+			t.Fatalf("head: %+v", head)
+		}
+	}
+	if got := sink.Property("idle"); len(got) != 0 {
+		t.Fatalf("values of the property not reported are sent: %v", got)
+	}
+}
+
+// TestFileSink tests the records are appended to the file in json lines.
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.json")
+
+	s, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := statf.StatMicMsgHead{MasterName: "App.Server", SlaveName: "Peer.Server", InterfaceName: "echo"}
+	prop := propertyf.StatPropMsgHead{ModuleName: "App.Server", PropertyName: "request"}
+	if err := s.ReportMicMsg(map[statf.StatMicMsgHead]statf.StatMicMsgBody{stat: {Count: 2}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReportSampleMsg([]statf.StatSampleMsg{{Unid: "abc", Depth: 1}, {Unid: "abc", Depth: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReportPropMsg(map[propertyf.StatPropMsgHead]propertyf.StatPropMsgBody{
+		prop: {VInfo: []propertyf.StatPropInfo{{Policy: "Sum", Value: "10"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// the file is appended by the sink of the next process
+	s, err = NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReportMicMsg(map[statf.StatMicMsgHead]statf.StatMicMsgBody{stat: {ExecCount: 1}}, false); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	type record struct {
+		Time       string          `json:"time"`
+		Type       string          `json:"type"`
+		FromClient *bool           `json:"fromClient"`
+		Head       json.RawMessage `json:"head"`
+		Body       json.RawMessage `json:"body"`
+	}
+	var records []record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		if r.Time == "" {
+			t.Fatalf("no time: %s", scanner.Text())
+		}
+		records = append(records, r)
+	}
+
+	types := make([]string, 0, len(records))
+	for _, r := range records {
+		types = append(types, r.Type)
+	}
+	if want := []string{"stat", "sample", "sample", "property", "stat"}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("types: got %v, want %v", types, want)
+	}
+	if records[0].FromClient == nil || !*records[0].FromClient || records[4].FromClient == nil || *records[4].FromClient {
+		t.Fatal("fromClient is not written")
+	}
+	if records[1].FromClient != nil || records[1].Head != nil {
+		t.Fatalf("sample has the stat fields: %+v", records[1])
+	}
+	var head statf.StatMicMsgHead
+	var body statf.StatMicMsgBody
+	if err := json.Unmarshal(records[0].Head, &head); err != nil || head != stat {
+		t.Fatalf("stat head: %+v %v", head, err)
+	}
+	if err := json.Unmarshal(records[0].Body, &body); err != nil || body.Count != 2 {
+		t.Fatalf("stat body: %+v %v", body, err)
+	}
+	var sample statf.StatSampleMsg
+	if err := json.Unmarshal(records[2].Body, &sample); err != nil || sample.Unid != "abc" || sample.Depth != 2 {
+		t.Fatalf("sample: %+v %v", sample, err)
+	}
+	var propBody propertyf.StatPropMsgBody
+	if err := json.Unmarshal(records[3].Body, &propBody); err != nil || propBody.VInfo[0].Value != "10" {
+		t.Fatalf("property body: %+v %v", propBody, err)
+	}
+}
@@ -1,13 +1,19 @@
 package tars
 
 import (
+	"context"
 	"fmt"
 	"github.com/MacgradyHuang/TarsGo/tars/util/zaplog"
 	"go.uber.org/zap"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
 	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
 )
 
 // StatInfo struct contains stat info' head and body.
@@ -22,14 +28,25 @@ type StatFHelper struct {
 	mStatInfo            map[statf.StatMicMsgHead]statf.StatMicMsgBody
 	mStatCount           map[statf.StatMicMsgHead]int
 	comm                 *Communicator
-	sf                   *statf.StatF
+	sink                 StatSink
 	node                 string
 	chStatInfoFromServer chan StatInfo
 	mStatInfoFromServer  map[statf.StatMicMsgHead]statf.StatMicMsgBody
 	mStatCountFromServer map[statf.StatMicMsgHead]int
+
+	// buckets are the upper bounds in ms of the IntervalCount, the last one also counts the slower calls.
+	buckets []int32
+	// mu guards the maps above and the last reported stats for the percentiles.
+	mu                     sync.Mutex
+	lastStatInfo           map[statf.StatMicMsgHead]statf.StatMicMsgBody
+	lastStatInfoFromServer map[statf.StatMicMsgHead]statf.StatMicMsgBody
+
+	chSample chan statf.StatSampleMsg
+	samples  []statf.StatSampleMsg
 }
 
-// Init init the StatFHelper
+// Init init the StatFHelper with the stat of the client config, the stats are only kept for the
+// percentiles if node is empty and no sink is set by SetStatSink.
 func (s *StatFHelper) Init(comm *Communicator, node string) {
 	s.node = node
 	s.chStatInfo = make(chan StatInfo, GetServerConfig().StatReportChannelBufLen)
@@ -38,53 +55,105 @@ func (s *StatFHelper) Init(comm *Communicator, node string) {
 	s.mStatCount = make(map[statf.StatMicMsgHead]int)
 	s.mStatInfoFromServer = make(map[statf.StatMicMsgHead]statf.StatMicMsgBody)
 	s.mStatCountFromServer = make(map[statf.StatMicMsgHead]int)
+	s.chSample = make(chan statf.StatSampleMsg, GetServerConfig().StatReportChannelBufLen)
+	s.buckets = GetServerConfig().StatBuckets
+	if len(s.buckets) == 0 {
+		s.buckets = parseStatBuckets(StatBuckets)
+	}
 	s.comm = comm
-	s.sf = new(statf.StatF)
-	s.comm.StringToProxy(s.node, s.sf)
+	if s.node != "" {
+		sink, err := newStatSink(s.comm, s.node)
+		if err != nil {
+			zaplog.Error("create stat sink error", zap.String("Stat", s.node), zap.Error(err))
+		}
+		s.sink = sink
+	}
+}
+
+// getSink returns the sink of SetStatSink or the one of the config.
+func (s *StatFHelper) getSink() StatSink {
+	if sink := getStatSink(); sink != nil {
+		return sink
+	}
+	return s.sink
+}
+
+// bucket returns the bucket of IntervalCount for the response time in ms.
+func (s *StatFHelper) bucket(rspTime int64) int32 {
+	i := sort.Search(len(s.buckets), func(i int) bool { return rspTime <= int64(s.buckets[i]) })
+	if i == len(s.buckets) {
+		i--
+	}
+	return s.buckets[i]
 }
 
 func (s *StatFHelper) collectMsg(statInfo StatInfo, mStatInfo map[statf.StatMicMsgHead]statf.StatMicMsgBody, mStatCount map[statf.StatMicMsgHead]int) {
-	if body, ok := s.mStatInfo[statInfo.Head]; ok {
-		body.Count += statInfo.Body.Count
-		body.TimeoutCount += statInfo.Body.TimeoutCount
-		body.ExecCount += statInfo.Body.ExecCount
-		body.TotalRspTime += statInfo.Body.TotalRspTime
-		body.MaxRspTime += body.MaxRspTime + statInfo.Body.MaxRspTime
-		body.MinRspTime += body.MinRspTime + statInfo.Body.MinRspTime
-		s.mStatInfo[statInfo.Head] = body
-		s.mStatCount[statInfo.Head]++
+	in := &statInfo.Body
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := mStatInfo[statInfo.Head]
+	if ok {
+		body.Count += in.Count
+		body.TimeoutCount += in.TimeoutCount
+		body.ExecCount += in.ExecCount
+		body.TotalRspTime += in.TotalRspTime
+		if in.MaxRspTime > body.MaxRspTime {
+			body.MaxRspTime = in.MaxRspTime
+		}
+		if in.MinRspTime < body.MinRspTime {
+			body.MinRspTime = in.MinRspTime
+		}
 	} else {
-		firstBody := statf.StatMicMsgBody{}
-		firstBody.Count = statInfo.Body.Count
-		firstBody.TimeoutCount = statInfo.Body.TimeoutCount
-		firstBody.ExecCount = statInfo.Body.ExecCount
-		firstBody.TotalRspTime = statInfo.Body.TotalRspTime
-		firstBody.MaxRspTime = statInfo.Body.MaxRspTime
-		firstBody.MinRspTime = statInfo.Body.MinRspTime
-		s.mStatInfo[statInfo.Head] = firstBody
-		s.mStatCount[statInfo.Head] = 1
+		body = statf.StatMicMsgBody{}
+		body.Count = in.Count
+		body.TimeoutCount = in.TimeoutCount
+		body.ExecCount = in.ExecCount
+		body.TotalRspTime = in.TotalRspTime
+		body.MaxRspTime = in.MaxRspTime
+		body.MinRspTime = in.MinRspTime
+		body.IntervalCount = make(map[int32]int32, len(s.buckets))
 	}
+	if len(in.IntervalCount) > 0 {
+		for k, v := range in.IntervalCount {
+			body.IntervalCount[k] += v
+		}
+	} else if n := in.Count + in.TimeoutCount + in.ExecCount; n > 0 {
+		// the calls of the body take the average response time
+		body.IntervalCount[s.bucket(in.TotalRspTime/int64(n))] += n
+	}
+	mStatInfo[statInfo.Head] = body
+	mStatCount[statInfo.Head]++
 }
 
 func (s *StatFHelper) reportAndClear(mStat string, bFromClient bool) {
+	var stat map[statf.StatMicMsgHead]statf.StatMicMsgBody
+	s.mu.Lock()
 	// report mStatInfo
 	if mStat == "mStatInfo" {
-		_, err := s.sf.ReportMicMsg(s.mStatInfo, bFromClient)
-		if err != nil {
-			zaplog.Debug("mStatInfo report err:", zap.Error(err))
+		stat = s.mStatInfo
+		if len(stat) > 0 {
+			s.lastStatInfo = stat
 		}
 		s.mStatInfo = make(map[statf.StatMicMsgHead]statf.StatMicMsgBody)
 		s.mStatCount = make(map[statf.StatMicMsgHead]int)
 	}
 	// report mStatInfoFromServer
 	if mStat == "mStatInfoFromServer" {
-		_, err := s.sf.ReportMicMsg(s.mStatInfoFromServer, bFromClient)
-		if err != nil {
-			zaplog.Debug("mStatInfoFromServer report err:", zap.Error(err))
+		stat = s.mStatInfoFromServer
+		if len(stat) > 0 {
+			s.lastStatInfoFromServer = stat
 		}
 		s.mStatInfoFromServer = make(map[statf.StatMicMsgHead]statf.StatMicMsgBody)
 		s.mStatCountFromServer = make(map[statf.StatMicMsgHead]int)
 	}
+	s.mu.Unlock()
+	sink := s.getSink()
+	if sink == nil || len(stat) == 0 {
+		return
+	}
+	if err := sink.ReportMicMsg(stat, bFromClient); err != nil {
+		zaplog.Debug(mStat+" report err:", zap.Error(err))
+	}
 }
 
 // Run run stat report loop
@@ -96,17 +165,40 @@ func (s *StatFHelper) Run() {
 			s.collectMsg(stStatInfo, s.mStatInfo, s.mStatCount)
 		case stStatInfoFromServer := <-s.chStatInfoFromServer:
 			s.collectMsg(stStatInfoFromServer, s.mStatInfoFromServer, s.mStatCountFromServer)
-		case <-ticker.C:
-			if len(s.mStatInfo) > 0 {
-				s.reportAndClear("mStatInfo", true)
-			}
-			if len(s.mStatInfoFromServer) > 0 {
-				s.reportAndClear("mStatInfoFromServer", false)
+		case sample := <-s.chSample:
+			s.samples = append(s.samples, sample)
+			if len(s.samples) >= SampleReportBatch {
+				s.reportSamples()
 			}
+		case <-ticker.C:
+			s.reportAndClear("mStatInfo", true)
+			s.reportAndClear("mStatInfoFromServer", false)
+			s.reportSamples()
 		}
 	}
 }
 
+func (s *StatFHelper) reportSamples() {
+	if len(s.samples) == 0 {
+		return
+	}
+	if sink := s.getSink(); sink != nil {
+		if err := sink.ReportSampleMsg(s.samples); err != nil {
+			zaplog.Debug("samples report err:", zap.Error(err))
+		}
+	}
+	s.samples = nil
+}
+
+// pushBackSample drops the sample if the channel is full, the rpc is not blocked by the sampling.
+func (s *StatFHelper) pushBackSample(sample statf.StatSampleMsg) {
+	select {
+	case s.chSample <- sample:
+	default:
+		zaplog.Debug("sample dropped, channel is full", zap.String("Unid", sample.Unid))
+	}
+}
+
 func (s *StatFHelper) pushBackMsg(stStatInfo StatInfo, fromServer bool) {
 	if fromServer {
 		s.chStatInfoFromServer <- stStatInfo
@@ -120,20 +212,31 @@ func (s *StatFHelper) ReportMicMsg(stStatInfo StatInfo, fromServer bool) {
 	s.pushBackMsg(stStatInfo, fromServer)
 }
 
-// StatReport instance pointer of StatFHelper
+// StatReport instance pointer of StatFHelper, it is nil if the stat is not configured.
 var StatReport *StatFHelper
+
+// statCollector collects the stats for the buckets and tars.viewstat even if the stat is not configured, it is
+// StatReport if the stat is configured, and reports to the sink of SetStatSink otherwise.
+var statCollector *StatFHelper
 var statInited = make(chan struct{}, 1)
 
 func initReport() {
-	if GetClientConfig() == nil || GetClientConfig().Stat == "" {
+	if GetClientConfig() == nil {
 		statInited <- struct{}{}
 		return
 	}
-	comm := NewCommunicator()
-	StatReport = new(StatFHelper)
-	StatReport.Init(comm, GetClientConfig().Stat)
+	stat := GetClientConfig().Stat
+	var comm *Communicator
+	if stat != "" {
+		comm = NewCommunicator()
+	}
+	statCollector = new(StatFHelper)
+	statCollector.Init(comm, stat)
+	if stat != "" {
+		StatReport = statCollector
+	}
 	statInited <- struct{}{}
-	go StatReport.Run()
+	go statCollector.Run()
 }
 
 // ReportStatBase is base method for report statitics.
@@ -142,8 +245,11 @@ func ReportStatBase(head *statf.StatMicMsgHead, body *statf.StatMicMsgBody, From
 	statInfo := StatInfo{Head: *head, Body: *body}
 	statInfo.Head.TarsVersion = cfg.Version
 	//statInfo.Head.IStatVer = 2
-	if StatReport != nil {
-		StatReport.ReportMicMsg(statInfo, FromServer)
+	if metrics != nil {
+		metrics.observeStat(&statInfo, FromServer)
+	}
+	if statCollector != nil {
+		statCollector.ReportMicMsg(statInfo, FromServer)
 	}
 }
 
@@ -228,7 +334,151 @@ func ReportStatFromServer(InterfaceName, MasterName string, ReturnValue int32, T
 	ReportStatBase(&head, &body, true)
 }
 
+// reportUnroutedStat reports the request which is routed to no servant or no function of the servant. The
+// slave name is the servant requested and the return value is the ret code, so that the stat shows which
+// servant or function is missed, and the master is the ip of the client.
+func reportUnroutedStat(ctx context.Context, req *requestf.RequestPacket, ret int32) {
+	cfg := GetServerConfig()
+	if cfg == nil {
+		return
+	}
+	var head statf.StatMicMsgHead
+	var body statf.StatMicMsgBody
+	head.SlaveName = req.SServantName
+	head.SlaveIp = cfg.LocalIP
+	head.InterfaceName = req.SFuncName
+	head.MasterIp, _ = current.GetClientIPFromContext(ctx)
+	head.MasterName = head.MasterIp
+	head.ReturnValue = ret
+	body.ExecCount = 1
+	ReportStatBase(&head, &body, true)
+}
+
 // ReportStat is same as ReportStatFromClient.
 func ReportStat(msg *Message, succ int32, timeout int32, exec int32) {
 	ReportStatFromClient(msg, succ, timeout, exec)
 }
+
+// parseStatBuckets parses the bucket upper bounds in ms separated by commas, e.g. "5,10,50,100".
+func parseStatBuckets(s string) []int32 {
+	var buckets []int32
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			continue
+		}
+		buckets = append(buckets, int32(n))
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	return buckets
+}
+
+// StatPercentile is the response time percentiles in ms of the calls of a stat head in the last reported
+// stat interval, they are estimated by the IntervalCount.
+type StatPercentile struct {
+	Head       statf.StatMicMsgHead
+	FromServer bool
+	Count      int32 // the calls of all the results
+	P50        float64
+	P90        float64
+	P99        float64
+	Max        int32
+	Min        int32
+}
+
+// GetStatPercentiles returns the percentiles of the client and the server calls in the last reported stat
+// interval with any call, nil if the client config is not loaded.
+func GetStatPercentiles() []StatPercentile {
+	if statCollector == nil {
+		return nil
+	}
+	s := statCollector
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ps []StatPercentile
+	for i, stat := range []map[statf.StatMicMsgHead]statf.StatMicMsgBody{s.lastStatInfo, s.lastStatInfoFromServer} {
+		for head, body := range stat {
+			ps = append(ps, StatPercentile{
+				Head:       head,
+				FromServer: i == 1,
+				Count:      body.Count + body.TimeoutCount + body.ExecCount,
+				P50:        statPercentile(&body, 0.5),
+				P90:        statPercentile(&body, 0.9),
+				P99:        statPercentile(&body, 0.99),
+				Max:        body.MaxRspTime,
+				Min:        body.MinRspTime,
+			})
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].FromServer != ps[j].FromServer {
+			return ps[i].FromServer
+		}
+		a, b := ps[i].Head, ps[j].Head
+		if a.SlaveName != b.SlaveName {
+			return a.SlaveName < b.SlaveName
+		}
+		if a.InterfaceName != b.InterfaceName {
+			return a.InterfaceName < b.InterfaceName
+		}
+		if a.MasterName != b.MasterName {
+			return a.MasterName < b.MasterName
+		}
+		return a.ReturnValue < b.ReturnValue
+	})
+	return ps
+}
+
+// statPercentile returns the q quantile of the response time in ms, it is interpolated in the bucket
+// between the previous upper bound and the upper bound, which are limited by the min and the max.
+func statPercentile(body *statf.StatMicMsgBody, q float64) float64 {
+	bounds := make([]int32, 0, len(body.IntervalCount))
+	var total int64
+	for k, v := range body.IntervalCount {
+		bounds = append(bounds, k)
+		total += int64(v)
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	rank := q * float64(total)
+	var n int64
+	lower := float64(body.MinRspTime)
+	for i, b := range bounds {
+		c := int64(body.IntervalCount[b])
+		upper := float64(b)
+		if i == len(bounds)-1 || upper > float64(body.MaxRspTime) {
+			upper = float64(body.MaxRspTime)
+		}
+		lo := lower
+		if lo > upper {
+			lo = upper
+		}
+		if c > 0 && float64(n+c) >= rank {
+			return lo + (upper-lo)*(rank-float64(n))/float64(c)
+		}
+		n += c
+		if float64(b) > lower {
+			lower = float64(b)
+		}
+	}
+	return float64(body.MaxRspTime)
+}
+
+func statPercentileCMD(params []string) (string, error) {
+	var b strings.Builder
+	for _, p := range GetStatPercentiles() {
+		if len(params) > 0 && p.Head.InterfaceName != params[0] {
+			continue
+		}
+		side := "client"
+		if p.FromServer {
+			side = "server"
+		}
+		fmt.Fprintf(&b, "%s %s->%s:%d %s ret=%d count=%d p50=%.1f p90=%.1f p99=%.1f max=%d min=%d\n",
+			side, p.Head.MasterName, p.Head.SlaveName, p.Head.SlavePort, p.Head.InterfaceName, p.Head.ReturnValue,
+			p.Count, p.P50, p.P90, p.P99, p.Max, p.Min)
+	}
+	return b.String(), nil
+}
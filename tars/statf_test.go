@@ -0,0 +1,162 @@
+package tars
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+)
+
+func newTestStatHelper(buckets []int32) *StatFHelper {
+	return &StatFHelper{
+		mStatInfo:            make(map[statf.StatMicMsgHead]statf.StatMicMsgBody),
+		mStatCount:           make(map[statf.StatMicMsgHead]int),
+		mStatInfoFromServer:  make(map[statf.StatMicMsgHead]statf.StatMicMsgBody),
+		mStatCountFromServer: make(map[statf.StatMicMsgHead]int),
+		buckets:              buckets,
+	}
+}
+
+func TestParseStatBuckets(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []int32
+	}{
+		{"5,10,50", []int32{5, 10, 50}},
+		{" 50, 5 ,10", []int32{5, 10, 50}},
+		{"5,x,-1,0,10", []int32{5, 10}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := parseStatBuckets(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStatBuckets(%q): got %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestStatBucket tests the calls are counted in the bucket of the first upper bound not less than the
+// response time, and the last bucket counts the slower calls.
+func TestStatBucket(t *testing.T) {
+	s := newTestStatHelper([]int32{5, 10, 50})
+	tests := []struct {
+		rspTime int64
+		want    int32
+	}{
+		{0, 5}, {5, 5}, {6, 10}, {10, 10}, {11, 50}, {50, 50}, {51, 50}, {3000, 50},
+	}
+	for _, tt := range tests {
+		if got := s.bucket(tt.rspTime); got != tt.want {
+			t.Errorf("bucket(%d): got %d, want %d", tt.rspTime, got, tt.want)
+		}
+	}
+}
+
+// TestCollectMsg tests the calls of a head are summed, the max and the min are the ones of all the calls,
+// and the IntervalCount of the calls are merged.
+func TestCollectMsg(t *testing.T) {
+	s := newTestStatHelper([]int32{5, 10, 50})
+	head := statf.StatMicMsgHead{SlaveName: "App.Server", InterfaceName: "echo"}
+	for _, body := range []statf.StatMicMsgBody{
+		{Count: 1, TotalRspTime: 8, MaxRspTime: 8, MinRspTime: 8},
+		{Count: 1, TotalRspTime: 2, MaxRspTime: 2, MinRspTime: 2},
+		{TimeoutCount: 1, TotalRspTime: 3000, MaxRspTime: 3000, MinRspTime: 3000},
+		// the body of many calls is counted in its own buckets
+		{Count: 3, TotalRspTime: 60, MaxRspTime: 40, MinRspTime: 4, IntervalCount: map[int32]int32{5: 1, 10: 1, 50: 1}},
+	} {
+		s.collectMsg(StatInfo{Head: head, Body: body}, s.mStatInfo, s.mStatCount)
+	}
+	want := statf.StatMicMsgBody{Count: 5, TimeoutCount: 1, TotalRspTime: 3070, MaxRspTime: 3000, MinRspTime: 2,
+		IntervalCount: map[int32]int32{5: 2, 10: 2, 50: 2}}
+	if got := s.mStatInfo[head]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("body: got %+v, want %+v", got, want)
+	}
+	if s.mStatCount[head] != 4 {
+		t.Fatalf("count: got %d, want 4", s.mStatCount[head])
+	}
+}
+
+// TestStatPercentile tests the percentiles are interpolated in the buckets limited by the min and the max.
+func TestStatPercentile(t *testing.T) {
+	tests := []struct {
+		name string
+		body statf.StatMicMsgBody
+		q    float64
+		want float64
+	}{
+		{
+			name: "in the first bucket",
+			body: statf.StatMicMsgBody{MinRspTime: 1, MaxRspTime: 80, IntervalCount: map[int32]int32{5: 10, 10: 10, 100: 0}},
+			q:    0.25,
+			want: 3, // 1 + (5-1)*5/10
+		},
+		{
+			name: "in the middle bucket",
+			body: statf.StatMicMsgBody{MinRspTime: 1, MaxRspTime: 80, IntervalCount: map[int32]int32{5: 10, 10: 10, 100: 0}},
+			q:    0.75,
+			want: 7.5, // 5 + (10-5)*5/10
+		},
+		{
+			name: "limited by the max",
+			body: statf.StatMicMsgBody{MinRspTime: 1, MaxRspTime: 60, IntervalCount: map[int32]int32{10: 50, 100: 50}},
+			q:    0.9,
+			want: 50, // 10 + (60-10)*40/50
+		},
+		{
+			name: "slower than the last bucket",
+			body: statf.StatMicMsgBody{MinRspTime: 20, MaxRspTime: 5000, IntervalCount: map[int32]int32{10: 0, 3000: 100}},
+			q:    0.99,
+			want: 4950.2, // 20 + (5000-20)*99/100
+		},
+		{
+			name: "no calls",
+			body: statf.StatMicMsgBody{},
+			q:    0.5,
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statPercentile(&tt.body, tt.q); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGetStatPercentiles tests the percentiles of the last reported interval are returned, the server calls first.
+func TestGetStatPercentiles(t *testing.T) {
+	defer func(s *StatFHelper) { statCollector = s }(statCollector)
+	statCollector = newTestStatHelper([]int32{10, 100})
+	s := statCollector
+	client := statf.StatMicMsgHead{SlaveName: "Peer.Server", InterfaceName: "echo"}
+	server := statf.StatMicMsgHead{SlaveName: "App.Server", InterfaceName: "echo"}
+	for i := 1; i <= 10; i++ {
+		s.collectMsg(StatInfo{Head: client, Body: statf.StatMicMsgBody{Count: 1, TotalRspTime: int64(i * 10),
+			MaxRspTime: int32(i * 10), MinRspTime: int32(i * 10)}}, s.mStatInfo, s.mStatCount)
+	}
+	s.collectMsg(StatInfo{Head: server, Body: statf.StatMicMsgBody{ExecCount: 1, TotalRspTime: 5, MaxRspTime: 5,
+		MinRspTime: 5}}, s.mStatInfoFromServer, s.mStatCountFromServer)
+	if ps := GetStatPercentiles(); len(ps) != 0 {
+		t.Fatalf("percentiles before the report: %+v", ps)
+	}
+	s.reportAndClear("mStatInfo", true)
+	s.reportAndClear("mStatInfoFromServer", false)
+
+	want := []StatPercentile{
+		{Head: server, FromServer: true, Count: 1, P50: 5, P90: 5, P99: 5, Max: 5, Min: 5},
+		// one call is in (0,10] and nine in (10,100], interpolated between 10 and the max 100
+		{Head: client, Count: 10, P50: 10 + 90*4.0/9, P90: 10 + 90*8.0/9, P99: 10 + 90*8.9/9, Max: 100, Min: 10},
+	}
+	got := GetStatPercentiles()
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Head != w.Head || g.FromServer != w.FromServer || g.Count != w.Count || g.Max != w.Max || g.Min != w.Min ||
+			math.Abs(g.P50-w.P50) > 1e-9 || math.Abs(g.P90-w.P90) > 1e-9 || math.Abs(g.P99-w.P99) > 1e-9 {
+			t.Fatalf("%d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package tars
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MacgradyHuang/TarsGo/tars/model"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/basef"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
+	"github.com/MacgradyHuang/TarsGo/tars/util/tools"
+	"github.com/MacgradyHuang/TarsGo/tars/util/zaplog"
+	"go.uber.org/zap"
+)
+
+// The streaming rpc is carried by normal tars requests of the function, the status tells the stream and the operation:
+// the client opens the stream, sends the messages, closes the sending side and polls the messages of the server
+// until the end of the stream. All the requests of a stream are sent to the same server by the hash of the stream id.
+const (
+	StatusStreamID   = "TARS_STREAM_ID"
+	StatusStreamOp   = "TARS_STREAM_OP"
+	StatusStreamWait = "TARS_STREAM_WAIT" // the max time in ms to wait for a message of the server
+	StatusStreamEOF  = "TARS_STREAM_EOF"  // set in the response if the stream ends
+	StatusStreamNone = "TARS_STREAM_NONE" // set in the response if there is no message in the time
+)
+
+// the operations of the stream
+const (
+	streamOpOpen      = "open"
+	streamOpSend      = "send"
+	streamOpCloseSend = "close_send"
+	streamOpRecv      = "recv"
+	streamOpCancel    = "cancel"
+)
+
+var (
+	// StreamPollWait is the max time the server waits for a message before the poll of the client returns.
+	StreamPollWait = 1000 * time.Millisecond
+	// StreamIdleTimeout is the time after which the stream without any request of the client is canceled.
+	StreamIdleTimeout = 60 * time.Second
+	// StreamBufLen is the number of messages buffered in each direction.
+	StreamBufLen = 16
+)
+
+// ErrStreamNotFound is returned if the stream is not found in the server, it has ended or been canceled.
+var ErrStreamNotFound = errors.New("tars stream not found")
+
+// ErrStreamSendTimeout is returned if the handler does not receive the message of the client in the timeout
+// of the request, the buffer of the stream is full.
+var ErrStreamSendTimeout = errors.New("tars stream send timeout")
+
+// ServerStream is the server side of a streaming rpc.
+type ServerStream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	key    string
+
+	recvCh    chan []byte
+	closeRecv sync.Once
+	sendCh    chan []byte
+	done      chan struct{}
+	err       error
+
+	mu         sync.Mutex
+	lastActive time.Time
+}
+
+// Context returns the context of the stream, it is canceled when the stream ends.
+func (s *ServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// Recv returns the next message of the client, io.EOF if the client has closed the sending side.
+func (s *ServerStream) Recv() ([]byte, error) {
+	select {
+	case msg, ok := <-s.recvCh:
+		if !ok {
+			return nil, io.EOF
+		}
+		return msg, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+// Send sends the message to the client, it blocks if the client is slower.
+func (s *ServerStream) Send(msg []byte) error {
+	select {
+	case s.sendCh <- msg:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s *ServerStream) touch() {
+	s.mu.Lock()
+	s.lastActive = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *ServerStream) idle() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastActive)
+}
+
+// StreamHandler runs the streaming rpc of the server, the stream ends when it returns.
+type StreamHandler func(ctx context.Context, stream *ServerStream) error
+
+var (
+	serverStreams sync.Map // servant name + stream id -> *ServerStream
+
+	streamCleanerMu      sync.Mutex
+	streamCleanerRunning bool
+)
+
+// startStreamCleaner starts cleanServerStreams if it is not running, it is called after a stream is stored.
+func startStreamCleaner() {
+	streamCleanerMu.Lock()
+	defer streamCleanerMu.Unlock()
+	if !streamCleanerRunning {
+		streamCleanerRunning = true
+		go cleanServerStreams(StreamIdleTimeout)
+	}
+}
+
+// cleanServerStreams cancels the streams left by the clients, it returns when there is no stream.
+func cleanServerStreams(idleTimeout time.Duration) {
+	ticker := time.NewTicker(idleTimeout / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		left := 0
+		serverStreams.Range(func(k, v interface{}) bool {
+			if s := v.(*ServerStream); s.idle() > idleTimeout {
+				zaplog.Info("cancel idle stream", zap.String("Stream", s.key))
+				s.cancel()
+				serverStreams.Delete(k)
+			} else {
+				left++
+			}
+			return true
+		})
+		if left > 0 {
+			continue
+		}
+		streamCleanerMu.Lock()
+		empty := true
+		serverStreams.Range(func(k, v interface{}) bool {
+			empty = false
+			return false
+		})
+		if empty {
+			streamCleanerRunning = false
+			streamCleanerMu.Unlock()
+			return
+		}
+		streamCleanerMu.Unlock()
+	}
+}
+
+// streamSendTimeout returns the time to wait for the handler to take the message of the send request,
+// the timeout of the request or StreamPollWait without it.
+func streamSendTimeout(req *requestf.RequestPacket) time.Duration {
+	if req.ITimeout > 0 {
+		return time.Duration(req.ITimeout) * time.Millisecond
+	}
+	return StreamPollWait
+}
+
+// DispatchStream handles the request of a streaming rpc in the generated Dispatch, the handler runs
+// in its own goroutine when the stream is opened.
+func DispatchStream(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket, handler StreamHandler) error {
+	id := req.Status[StatusStreamID]
+	if id == "" {
+		return errors.New("tars stream id is empty, " + req.SFuncName + " is a streaming rpc")
+	}
+	key := req.SServantName + "/" + id
+	*resp = requestf.ResponsePacket{
+		IVersion:    req.IVersion,
+		CPacketType: req.CPacketType,
+		IRequestId:  req.IRequestId,
+		IRet:        basef.TARSSERVERSUCCESS,
+		Status:      make(map[string]string),
+	}
+
+	op := req.Status[StatusStreamOp]
+	if op == streamOpOpen {
+		sctx, cancel := context.WithCancel(ctx)
+		s := &ServerStream{
+			ctx:        sctx,
+			cancel:     cancel,
+			key:        key,
+			recvCh:     make(chan []byte, StreamBufLen),
+			sendCh:     make(chan []byte, StreamBufLen),
+			done:       make(chan struct{}),
+			lastActive: time.Now(),
+		}
+		if _, loaded := serverStreams.LoadOrStore(key, s); loaded {
+			cancel()
+			return errors.New("tars stream " + id + " exists")
+		}
+		startStreamCleaner()
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					zaplog.Error("stream handler panic", zap.String("Stream", key), zap.Any("Error", r))
+					s.err = errors.New("stream handler panic")
+				}
+				close(s.done)
+			}()
+			s.err = handler(sctx, s)
+		}()
+		return nil
+	}
+
+	v, ok := serverStreams.Load(key)
+	if !ok {
+		return ErrStreamNotFound
+	}
+	s := v.(*ServerStream)
+	s.touch()
+	switch op {
+	case streamOpSend:
+		timer := time.NewTimer(streamSendTimeout(req))
+		defer timer.Stop()
+		select {
+		case s.recvCh <- tools.Int8ToByte(req.SBuffer):
+		case <-s.done:
+			// the handler does not read any more, the result is returned by recv
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-timer.C:
+			return ErrStreamSendTimeout
+		}
+	case streamOpCloseSend:
+		s.closeRecv.Do(func() {
+			close(s.recvCh)
+		})
+	case streamOpRecv:
+		wait := StreamPollWait
+		if ms, err := strconv.Atoi(req.Status[StatusStreamWait]); err == nil && ms > 0 && time.Duration(ms)*time.Millisecond < wait {
+			wait = time.Duration(ms) * time.Millisecond
+		}
+		return s.poll(resp, wait)
+	case streamOpCancel:
+		s.cancel()
+		serverStreams.Delete(key)
+	default:
+		return errors.New("unknown tars stream operation " + op)
+	}
+	return nil
+}
+
+// poll returns the next message of the handler, the end of the stream or nothing in the time.
+func (s *ServerStream) poll(resp *requestf.ResponsePacket, wait time.Duration) error {
+	select {
+	case msg := <-s.sendCh:
+		resp.SBuffer = tools.ByteToInt8(msg)
+		return nil
+	default:
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case msg := <-s.sendCh:
+		resp.SBuffer = tools.ByteToInt8(msg)
+		return nil
+	case <-s.done:
+		// the messages sent before the end are returned first
+		select {
+		case msg := <-s.sendCh:
+			resp.SBuffer = tools.ByteToInt8(msg)
+			return nil
+		default:
+		}
+		s.cancel()
+		serverStreams.Delete(s.key)
+		if s.err != nil {
+			return s.err
+		}
+		resp.Status[StatusStreamEOF] = "1"
+	case <-timer.C:
+		resp.Status[StatusStreamNone] = "1"
+	}
+	return nil
+}
+
+// ClientStream is the client side of a streaming rpc.
+type ClientStream struct {
+	ctx     context.Context
+	s       model.Servant
+	method  string
+	id      string
+	wait    int
+	status  map[string]string
+	context map[string]string
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewClientStream opens the stream of the streaming rpc, input is sent with the open request for the
+// server streaming rpc. The stream is canceled in the server if ctx is done before the end of the stream.
+// _opt are the context and the status of all the requests of the stream, like the unary rpc.
+func NewClientStream(ctx context.Context, s model.Servant, method string, input []byte, _opt ...map[string]string) (*ClientStream, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	cs := &ClientStream{
+		ctx:    ctx,
+		s:      s,
+		method: method,
+		id:     hex.EncodeToString(b),
+		wait:   int(StreamPollWait / time.Millisecond),
+		done:   make(chan struct{}),
+	}
+	if len(_opt) >= 1 {
+		cs.context = _opt[0]
+	}
+	if len(_opt) >= 2 {
+		cs.status = _opt[1]
+	}
+
+	// all the requests of the stream go to the same server
+	ok, timeout, isTimeout := current.GetClientTimeout(ctx)
+	cs.ctx = current.ContextWithClientCurrent(ctx)
+	current.SetClientHash(cs.ctx, int(ConsistentHash), crc32.ChecksumIEEE([]byte(cs.id)))
+	if ok && isTimeout {
+		current.SetClientTimeout(cs.ctx, timeout)
+		if timeout/2 < cs.wait {
+			cs.wait = timeout / 2
+		}
+	}
+
+	if _, err := cs.invoke(streamOpOpen, input); err != nil {
+		return nil, err
+	}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				cs.invoke(streamOpCancel, nil)
+				cs.finish()
+			case <-cs.done:
+			}
+		}()
+	}
+	return cs, nil
+}
+
+func (cs *ClientStream) finish() {
+	cs.doneOnce.Do(func() {
+		close(cs.done)
+	})
+}
+
+func (cs *ClientStream) invoke(op string, buf []byte) (*requestf.ResponsePacket, error) {
+	status := make(map[string]string, len(cs.status)+3)
+	for k, v := range cs.status {
+		status[k] = v
+	}
+	status[StatusStreamID] = cs.id
+	status[StatusStreamOp] = op
+	if op == streamOpRecv {
+		status[StatusStreamWait] = strconv.Itoa(cs.wait)
+	}
+	resp := new(requestf.ResponsePacket)
+	err := cs.s.Tars_invoke(cs.ctx, 0, cs.method, buf, status, cs.context, resp)
+	return resp, err
+}
+
+// Context returns the context of the stream.
+func (cs *ClientStream) Context() context.Context {
+	return cs.ctx
+}
+
+// Send sends the message to the server.
+func (cs *ClientStream) Send(msg []byte) error {
+	if err := cs.ctx.Err(); err != nil {
+		return err
+	}
+	_, err := cs.invoke(streamOpSend, msg)
+	return err
+}
+
+// CloseSend tells the server there is no more message.
+func (cs *ClientStream) CloseSend() error {
+	_, err := cs.invoke(streamOpCloseSend, nil)
+	return err
+}
+
+// Recv returns the next message of the server, io.EOF at the end of the stream.
+func (cs *ClientStream) Recv() ([]byte, error) {
+	for {
+		if err := cs.ctx.Err(); err != nil {
+			return nil, err
+		}
+		resp, err := cs.invoke(streamOpRecv, nil)
+		if err != nil {
+			cs.finish()
+			return nil, err
+		}
+		if resp.Status[StatusStreamEOF] != "" {
+			cs.finish()
+			return nil, io.EOF
+		}
+		if resp.Status[StatusStreamNone] != "" {
+			continue
+		}
+		return tools.Int8ToByte(resp.SBuffer), nil
+	}
+}
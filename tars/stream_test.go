@@ -0,0 +1,309 @@
+package tars
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MacgradyHuang/TarsGo/tars/model"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/tools"
+)
+
+// streamServant dispatches the requests of the client stream to DispatchStream in the process, newHandler
+// returns the handler of the stream with the input of the open request.
+type streamServant struct {
+	newHandler func(input []byte) StreamHandler
+	timeout    int32
+}
+
+func (f *streamServant) Tars_invoke(ctx context.Context, ctype byte, sFuncName string, buf []byte,
+	status map[string]string, context_ map[string]string, resp *requestf.ResponsePacket) error {
+	req := &requestf.RequestPacket{
+		IVersion:     1,
+		SServantName: "App.Server.StreamObj",
+		SFuncName:    sFuncName,
+		SBuffer:      tools.ByteToInt8(buf),
+		ITimeout:     f.timeout,
+		Status:       status,
+		Context:      context_,
+	}
+	return DispatchStream(context.Background(), req, resp, f.newHandler(buf))
+}
+
+func (f *streamServant) TarsSetTimeout(t int) {}
+
+func (f *streamServant) TarsSetProtocol(model.Protocol) {}
+
+// setStreamTimeouts shortens the timeouts of the streams for the tests, and returns the function to restore them.
+func setStreamTimeouts() func() {
+	wait, idle, bufLen := StreamPollWait, StreamIdleTimeout, StreamBufLen
+	StreamPollWait, StreamIdleTimeout = 10*time.Millisecond, 200*time.Millisecond
+	return func() {
+		StreamPollWait, StreamIdleTimeout, StreamBufLen = wait, idle, bufLen
+	}
+}
+
+// recvAll receives the messages of the server until the end of the stream.
+func recvAll(cs *ClientStream) ([]string, error) {
+	var msgs []string
+	for {
+		msg, err := cs.Recv()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, string(msg))
+	}
+}
+
+func streamLeft(t *testing.T, cs *ClientStream) bool {
+	t.Helper()
+	_, ok := serverStreams.Load("App.Server.StreamObj/" + cs.id)
+	return ok
+}
+
+// TestStream tests the messages and the ends of the client, the server and the bidirectional streaming rpcs.
+func TestStream(t *testing.T) {
+	defer setStreamTimeouts()()
+
+	tests := []struct {
+		name    string
+		input   string
+		send    []string
+		handler func(input []byte) StreamHandler
+		want    []string
+	}{
+		{
+			name: "client streaming",
+			send: []string{"a", "b", "c"},
+			handler: func([]byte) StreamHandler {
+				return func(ctx context.Context, stream *ServerStream) error {
+					var all string
+					for {
+						msg, err := stream.Recv()
+						if err == io.EOF {
+							return stream.Send([]byte(all))
+						}
+						if err != nil {
+							return err
+						}
+						all += string(msg)
+					}
+				}
+			},
+			want: []string{"abc"},
+		},
+		{
+			name:  "server streaming",
+			input: "x",
+			handler: func(input []byte) StreamHandler {
+				return func(ctx context.Context, stream *ServerStream) error {
+					for i := 0; i < 3; i++ {
+						if err := stream.Send([]byte(string(input) + strconv.Itoa(i))); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+			},
+			want: []string{"x0", "x1", "x2"},
+		},
+		{
+			name: "bidirectional streaming",
+			send: []string{"a", "b"},
+			handler: func([]byte) StreamHandler {
+				return func(ctx context.Context, stream *ServerStream) error {
+					for {
+						msg, err := stream.Recv()
+						if err == io.EOF {
+							return nil
+						}
+						if err != nil {
+							return err
+						}
+						if err := stream.Send(append(msg, msg...)); err != nil {
+							return err
+						}
+					}
+				}
+			},
+			want: []string{"aa", "bb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &streamServant{newHandler: tt.handler}
+			var input []byte
+			if tt.input != "" {
+				input = []byte(tt.input)
+			}
+			cs, err := NewClientStream(context.Background(), s, "stream", input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, msg := range tt.send {
+				if err := cs.Send([]byte(msg)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.input == "" {
+				if err := cs.CloseSend(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := recvAll(cs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+			if streamLeft(t, cs) {
+				t.Fatal("stream is left in the server after the end")
+			}
+			if _, err := cs.Recv(); err != ErrStreamNotFound {
+				t.Fatalf("recv after the end: got %v, want %v", err, ErrStreamNotFound)
+			}
+		})
+	}
+}
+
+// TestStreamError tests the error of the handler is returned to the client after the messages sent before it.
+func TestStreamError(t *testing.T) {
+	defer setStreamTimeouts()()
+
+	s := &streamServant{newHandler: func([]byte) StreamHandler {
+		return func(ctx context.Context, stream *ServerStream) error {
+			if err := stream.Send([]byte("a")); err != nil {
+				return err
+			}
+			return errors.New("bad stream")
+		}
+	}}
+	cs, err := NewClientStream(context.Background(), s, "stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := recvAll(cs)
+	if err == nil || err.Error() != "bad stream" {
+		t.Fatalf("got %v, want bad stream", err)
+	}
+	if len(got) != 1 || got[0] != "a" {
+		t.Fatalf("messages before the error: %v", got)
+	}
+	if streamLeft(t, cs) {
+		t.Fatal("stream is left in the server after the error")
+	}
+}
+
+// TestStreamCancel tests the handler is canceled when the context of the client is done.
+func TestStreamCancel(t *testing.T) {
+	defer setStreamTimeouts()()
+
+	canceled := make(chan error, 1)
+	s := &streamServant{newHandler: func([]byte) StreamHandler {
+		return func(ctx context.Context, stream *ServerStream) error {
+			_, err := stream.Recv()
+			canceled <- err
+			return err
+		}
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cs, err := NewClientStream(ctx, s, "stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case err := <-canceled:
+		if err != context.Canceled {
+			t.Fatalf("handler: got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler is not canceled")
+	}
+	if _, err := cs.Recv(); err != context.Canceled {
+		t.Fatalf("recv: got %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestStreamSendTimeout tests the send waits for the handler no longer than the timeout of the request.
+func TestStreamSendTimeout(t *testing.T) {
+	defer setStreamTimeouts()()
+	StreamBufLen = 1
+
+	s := &streamServant{timeout: 20, newHandler: func([]byte) StreamHandler {
+		return func(ctx context.Context, stream *ServerStream) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cs, err := NewClientStream(ctx, s, "stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Send([]byte("a")); err != nil {
+		t.Fatalf("send to the buffer: %v", err)
+	}
+	start := time.Now()
+	if err := cs.Send([]byte("b")); err != ErrStreamSendTimeout {
+		t.Fatalf("send to the full buffer: got %v, want %v", err, ErrStreamSendTimeout)
+	}
+	if cost := time.Since(start); cost < 20*time.Millisecond || cost > time.Second {
+		t.Fatalf("send to the full buffer returns in %v", cost)
+	}
+}
+
+// TestStreamIdle tests the stream without any request is canceled after StreamIdleTimeout, and the cleaner
+// stops when there is no stream left.
+func TestStreamIdle(t *testing.T) {
+	defer setStreamTimeouts()()
+
+	canceled := make(chan struct{})
+	s := &streamServant{newHandler: func([]byte) StreamHandler {
+		return func(ctx context.Context, stream *ServerStream) error {
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		}
+	}}
+	cs, err := NewClientStream(context.Background(), s, "stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle stream is not canceled")
+	}
+	if err := cs.Send([]byte("a")); err != ErrStreamNotFound {
+		t.Fatalf("send to the idle stream: got %v, want %v", err, ErrStreamNotFound)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		streamCleanerMu.Lock()
+		running := streamCleanerRunning
+		streamCleanerMu.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cleaner is running without any stream")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/MacgradyHuang/TarsGo/tars/protocol/codec"
 	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/basef"
 	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/transport"
 	"github.com/MacgradyHuang/TarsGo/tars/util/current"
 )
 
@@ -21,9 +22,28 @@ type dispatch interface {
 
 // TarsProtocol is struct for dispatch with tars protocol.
 type TarsProtocol struct {
-	dispatcher       dispatch
-	serverImp        interface{}
-	withContext      bool
+	dispatcher   dispatch
+	serverImp    interface{}
+	withContext  bool
+	interceptors []ServerInterceptor
+	mux          *servantMux
+}
+
+// servantMux routes the requests to the servants sharing the adapter by the servant name.
+type servantMux struct {
+	svr      *transport.TarsServer
+	cfg      *transport.TarsServerConf // the adapter config of obj, the first servant of the address
+	obj      string
+	servants map[string]*TarsProtocol
+}
+
+func newServantMux() *servantMux {
+	return &servantMux{servants: make(map[string]*TarsProtocol)}
+}
+
+func (m *servantMux) add(obj string, s *TarsProtocol) {
+	s.mux = m
+	m.servants[obj] = s
 }
 
 // NewTarsProtocol return a TarsProtocol with dipatcher and implement interface.
@@ -48,6 +68,13 @@ func (s *TarsProtocol) Invoke(ctx context.Context, req []byte) (rsp []byte) {
 			}
 		}
 	}
+	if reqPackage.HasMessageType(basef.TARSMESSAGETYPESAMPLE) {
+		if sampleKey, ok := reqPackage.Status[current.STATUS_SAMPLE_KEY]; ok {
+			current.SetSampleKey(ctx, sampleKey)
+			reportServerSample(ctx, &reqPackage)
+		}
+	}
+	ctx = requestLogContext(ctx, &reqPackage)
 
 	if reqPackage.CPacketType == basef.TARSONEWAY {
 		defer func() func() {
@@ -59,52 +86,74 @@ func (s *TarsProtocol) Invoke(ctx context.Context, req []byte) (rsp []byte) {
 		}()()
 	}
 	var err error
+	servant := s
+	if s.mux != nil {
+		servant = s.mux.servants[reqPackage.SServantName]
+	}
+	if servant == nil {
+		err = Errorf(basef.TARSSERVERNOSERVANTERR, "servant not found: %s", reqPackage.SServantName)
+	} else {
+		err = servant.invoke(ctx, &reqPackage, &rspPackage)
+	}
+	if err != nil {
+		zaplog.Error("found err", zap.Int32("IRequestId", reqPackage.IRequestId), zap.Error(err))
+		rspPackage.IVersion = basef.TARSVERSION
+		rspPackage.CPacketType = basef.TARSNORMAL
+		rspPackage.IRequestId = reqPackage.IRequestId
+		setErrorResponse(&rspPackage, err)
+		switch rspPackage.IRet {
+		case basef.TARSSERVERNOSERVANTERR, basef.TARSSERVERNOFUNCERR:
+			reportUnroutedStat(ctx, &reqPackage, rspPackage.IRet)
+		}
+	}
+
+	//return ctype
+	rspPackage.CPacketType = reqPackage.CPacketType
+	ok := current.SetPacketTypeFromContext(ctx, rspPackage.CPacketType)
+	if !ok {
+		zaplog.Error("SetPacketType in context fail!")
+	}
+
+	return s.rsp2Byte(&rspPackage)
+}
+
+// invoke runs the interceptors and the dispatcher of the servant for the request.
+func (s *TarsProtocol) invoke(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket) error {
 	if s.withContext {
-		ok := current.SetRequestStatus(ctx, reqPackage.Status)
+		ok := current.SetRequestStatus(ctx, req.Status)
 		if !ok {
 			zaplog.Error("Set reqeust status in context fail!")
 		}
-		ok = current.SetRequestContext(ctx, reqPackage.Context)
+		ok = current.SetRequestContext(ctx, req.Context)
 		if !ok {
 			zaplog.Error("Set request context in context fail!")
 		}
 	}
+	return chainServerInterceptor(ctx, req, resp, joinServerInterceptors(s.interceptors), s.dispatch)
+}
+
+// dispatch runs the filters and the dispatcher, it is the last step of the interceptors.
+func (s *TarsProtocol) dispatch(ctx context.Context, req *requestf.RequestPacket, resp *requestf.ResponsePacket) (err error) {
 	if allFilters.sf != nil {
-		err = allFilters.sf(ctx, s.dispatcher.Dispatch, s.serverImp, &reqPackage, &rspPackage, s.withContext)
+		err = allFilters.sf(ctx, s.dispatcher.Dispatch, s.serverImp, req, resp, s.withContext)
 	} else {
 		// execute pre server filters
 		for i, v := range allFilters.preSfs {
-			err = v(ctx, s.dispatcher.Dispatch, s.serverImp, &reqPackage, &rspPackage, s.withContext)
+			err = v(ctx, s.dispatcher.Dispatch, s.serverImp, req, resp, s.withContext)
 			if err != nil {
 				zaplog.Error("Pre filter error", zap.Int("Index", i), zap.Error(err))
 			}
 		}
-		err = s.dispatcher.Dispatch(ctx, s.serverImp, &reqPackage, &rspPackage, s.withContext)
+		err = s.dispatcher.Dispatch(ctx, s.serverImp, req, resp, s.withContext)
 		// execute post server filters
 		for i, v := range allFilters.postSfs {
-			err = v(ctx, s.dispatcher.Dispatch, s.serverImp, &reqPackage, &rspPackage, s.withContext)
+			err = v(ctx, s.dispatcher.Dispatch, s.serverImp, req, resp, s.withContext)
 			if err != nil {
 				zaplog.Error("Post filter error", zap.Int("Index", i), zap.Error(err))
 			}
 		}
 	}
-	if err != nil {
-		zaplog.Error("found err", zap.Int32("IRequestId", reqPackage.IRequestId), zap.Error(err))
-		rspPackage.IVersion = basef.TARSVERSION
-		rspPackage.CPacketType = basef.TARSNORMAL
-		rspPackage.IRequestId = reqPackage.IRequestId
-		rspPackage.IRet = 1
-		rspPackage.SResultDesc = err.Error()
-	}
-
-	//return ctype
-	rspPackage.CPacketType = reqPackage.CPacketType
-	ok := current.SetPacketTypeFromContext(ctx, rspPackage.CPacketType)
-	if !ok {
-		zaplog.Error("SetPacketType in context fail!")
-	}
-
-	return s.rsp2Byte(&rspPackage)
+	return err
 }
 
 func (s *TarsProtocol) rsp2Byte(rsp *requestf.ResponsePacket) []byte {
@@ -0,0 +1,98 @@
+package tars
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/codec"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/basef"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
+	"github.com/MacgradyHuang/TarsGo/tars/util/tools"
+)
+
+// fakeDispatch answers the function "echo" with the name of the servant, and returns the no func error
+// for the others.
+type fakeDispatch struct {
+	name string
+	// calls is the functions dispatched
+	calls []string
+}
+
+func (d *fakeDispatch) Dispatch(ctx context.Context, imp interface{}, req *requestf.RequestPacket,
+	resp *requestf.ResponsePacket, withContext bool) error {
+	d.calls = append(d.calls, req.SFuncName)
+	if req.SFuncName != "echo" {
+		return NoFuncError(req.SFuncName)
+	}
+	resp.IVersion = basef.TARSVERSION
+	resp.IRequestId = req.IRequestId
+	resp.SBuffer = tools.ByteToInt8([]byte(d.name))
+	return nil
+}
+
+// invokeRequest encodes the request, invokes the protocol with it and decodes the response.
+func invokeRequest(t *testing.T, s *TarsProtocol, req *requestf.RequestPacket) *requestf.ResponsePacket {
+	t.Helper()
+	os := codec.NewBuffer()
+	if err := req.WriteTo(os); err != nil {
+		t.Fatal(err)
+	}
+	bs := os.ToBytes()
+	pkg := make([]byte, 4+len(bs))
+	binary.BigEndian.PutUint32(pkg, uint32(len(pkg)))
+	copy(pkg[4:], bs)
+
+	rsp := s.Invoke(current.ContextWithTarsCurrent(context.Background()), pkg)
+	resp := &requestf.ResponsePacket{}
+	if err := resp.ReadFrom(codec.NewReader(rsp[4:])); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServantMux(t *testing.T) {
+	hello := &fakeDispatch{name: "App.Server.HelloObj"}
+	store := &fakeDispatch{name: "App.Server.StoreObj"}
+	mux := newServantMux()
+	helloProto := NewTarsProtocol(hello, nil, false)
+	mux.add(hello.name, helloProto)
+	mux.add(store.name, NewTarsProtocol(store, nil, false))
+
+	tests := []struct {
+		name    string
+		servant string
+		fn      string
+		ret     int32
+		buffer  string
+	}{
+		{"hello", "App.Server.HelloObj", "echo", basef.TARSSERVERSUCCESS, "App.Server.HelloObj"},
+		{"store", "App.Server.StoreObj", "echo", basef.TARSSERVERSUCCESS, "App.Server.StoreObj"},
+		{"no servant", "App.Server.NoObj", "echo", basef.TARSSERVERNOSERVANTERR, ""},
+		{"no func", "App.Server.StoreObj", "nofunc", basef.TARSSERVERNOFUNCERR, ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the request is routed by the servant name whichever servant of the address receives it
+			resp := invokeRequest(t, helloProto, &requestf.RequestPacket{
+				IVersion:     basef.TARSVERSION,
+				IRequestId:   int32(i + 1),
+				SServantName: tt.servant,
+				SFuncName:    tt.fn,
+			})
+			if resp.IRet != tt.ret {
+				t.Fatalf("ret: got %d, want %d (%s)", resp.IRet, tt.ret, resp.SResultDesc)
+			}
+			if resp.IRequestId != int32(i+1) {
+				t.Fatalf("request id: got %d, want %d", resp.IRequestId, i+1)
+			}
+			if got := string(tools.Int8ToByte(resp.SBuffer)); got != tt.buffer {
+				t.Fatalf("buffer: got %q, want %q", got, tt.buffer)
+			}
+		})
+	}
+	if len(hello.calls) != 1 || len(store.calls) != 2 {
+		t.Fatalf("calls: hello %v, store %v", hello.calls, store.calls)
+	}
+}
@@ -1,3 +0,0 @@
-#!/bin/bash
-make
-./_SERVER_ --config=config.conf
@@ -1,95 +1,14 @@
 #!/bin/sh
 
-# check params
-if [ $# -lt 3 ]
+# create_tars_server.sh is deprecated, the server is created by tars2go -scaffold with go module.
+if [ $# -lt 4 ]
 then
-    echo "<Usage: sh $0  App  Server  Servant>"
-    echo ">>>>>>  sh $0  TeleSafe PhonenumSogouServer SogouInfo"
+    echo "$0 is deprecated, servers are created with go module now, run:"
+    echo "  tars2go -scaffold Server -app App -server Server -servant Servant -module GoModuleName"
+    echo "or: sh $(dirname $0)/create_tars_server_gomod.sh App Server Servant GoModuleName"
+    echo ">>>>>>  sh $(dirname $0)/create_tars_server_gomod.sh TeleSafe PhonenumSogouServer SogouInfo github.com/TeleSafe/PhonenumSogouServer"
     exit 1
 fi
 
-export GOPATH=$(echo $GOPATH | cut -f1 -d ':')
-if [ "$GOPATH" == "" ]; then
-    echo "GOPATH must be set"
-    exit 1
-fi
-
-APP=$1
-SERVER=$2
-SERVANT=$3
-TARGET="$GOPATH/src/$APP/$SERVER/"
-
-if [ -d $TARGET ];then
-    echo "! Already have some file in $TARGET! Please clear files in prevent of overwrite!"
-    exit 1
-fi
-
-
-if [ "$SERVER" == "$SERVANT" ]
-then
-    echo "Error!(ServerName == ServantName)"
-    exit 1
-fi
-echo "[create server: $APP.$SERVER ...]"
-
-SRC_DIR=$(cd $(dirname $0); pwd)
-DEMODIR=$SRC_DIR/Demo
-DEBUGDIR=$SRC_DIR/debugtool
-cd $DEMODIR || exit 1
-SRC_FILE=`find . -maxdepth 1 -type f`
-
-echo "[mkdir: $TARGET]"
-mkdir -p $TARGET
-cd $TARGET || exit 1
-
-cp -r $DEMODIR/* $TARGET
-cp -r $DEBUGDIR $TARGET
-
-if [ `uname` == "Darwin" ] # support macOS
-then
-    for FILE in $SRC_FILE client/client.go vendor/vendor.json
-    do
-        echo ">>>Now doing:"$FILE" >>>>"
-        sed  -i "" "s/_APP_/$APP/g"   $FILE
-        sed  -i "" "s/_SERVER_/$SERVER/g" $FILE
-        sed  -i "" "s/_SERVANT_/$SERVANT/g" $FILE
-    done
-
-    for RENAMEFILE in `find . -maxdepth 1 -type f`
-    do
-        # $SERVER cant contain "Servant" string
-        NEWFILE=`echo $RENAMEFILE | sed "s/Server/$SERVER/" | sed "s/Servant/$SERVANT/"`
-        mv $RENAMEFILE $NEWFILE
-
-        # or use `rename`，default not install rename, you should execute ``` brew install rename ```
-        # rename "s/Server/$SERVER/" $RENAMEFILE
-        # rename "s/Servant/$SERVANT/" $RENAMEFILE
-    done
-else
-    for FILE in $SRC_FILE client/client.go vendor/vendor.json debugtool/dumpstack.go
-    do
-        echo ">>>Now doing:"$FILE" >>>>"
-        sed -i "s/_APP_/$APP/g"   $FILE
-        sed -i "s/_SERVER_/$SERVER/g" $FILE
-        sed -i "s/_SERVANT_/$SERVANT/g" $FILE
-    done
-
-    SERVANT_LC=`echo $SERVANT|tr 'A-Z' 'a-z'`
-    for RENAMEFILE in `ls `
-    do
-        rename "Server" "$SERVER" $RENAMEFILE
-        rename "Servant.tars" "${SERVANT}.tars" $RENAMEFILE
-        rename "Servant_imp.go" "${SERVANT_LC}_imp.go" $RENAMEFILE
-    done
-fi
-
-# try build tars2go
-cd "$SRC_DIR/tars2go"
-go install
-cd $TARGET
-echo ">>> Great！Done! You can jump in "`pwd`
-
-# show tips: how to convert tars to golang
-echo ">>> Tips: After editing the Tars file, execute the following cmd to automatically generate golang files."
-echo ">>>       $GOPATH/bin/tars2go *.tars"
-
+echo "$0 is deprecated, use tars2go -scaffold or create_tars_server_gomod.sh instead" >&2
+exec sh $(dirname $0)/create_tars_server_gomod.sh "$1" "$2" "$3" "$4"
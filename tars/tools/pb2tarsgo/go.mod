@@ -0,0 +1,5 @@
+module github.com/MacgradyHuang/TarsGo/tars/tools/pb2tarsgo
+
+go 1.13
+
+require github.com/golang/protobuf v1.5.4
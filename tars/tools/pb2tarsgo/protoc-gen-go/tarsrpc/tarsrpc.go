@@ -113,6 +113,14 @@ func (t *tarsrpc) Generate(file *generator.FileDescriptor) {
 	_ = t.gen.AddImport(toolsPath)
 	_ = t.gen.AddImport(currentPath)
 	_ = t.gen.AddImport("context")
+	for _, service := range file.FileDescriptorProto.Service {
+		for _, method := range service.Method {
+			if method.GetClientStreaming() && !method.GetServerStreaming() {
+				// used by CloseAndRecv
+				_ = t.gen.AddImport("io")
+			}
+		}
+	}
 	for i, service := range file.FileDescriptorProto.Service {
 		t.generateService(file, service, i)
 	}
@@ -139,14 +147,14 @@ func (t *tarsrpc) generateService(file *generator.FileDescriptor, service *pb.Se
 	t.P()
 	//generate AddServant
 	t.P(fmt.Sprintf(`//AddServant is required by the servant interface
-	func (obj *%s) AddServant(imp imp%s, objStr string){
-		tars.AddServant(obj, imp, objStr)
+	func (obj *%s) AddServant(imp imp%s, objStr string, interceptors ...tars.ServerInterceptor){
+		tars.AddServant(obj, imp, objStr, interceptors...)
 	}`, serviceName, serviceName))
 
 	//generate AddServantWithContext
 	t.P(fmt.Sprintf(`////AddServant adds servant  for the service with context
-	func (obj *%s) AddServantWithContext(imp imp%sWithContext, objStr string) {
-		tars.AddServantWithContext(obj, imp, objStr)
+	func (obj *%s) AddServantWithContext(imp imp%sWithContext, objStr string, interceptors ...tars.ServerInterceptor) {
+		tars.AddServantWithContext(obj, imp, objStr, interceptors...)
 	}`, serviceName, serviceName))
 	t.P()
 
@@ -169,8 +177,7 @@ func (t *tarsrpc) generateService(file *generator.FileDescriptor, service *pb.Se
 	//generate the interface
 	t.P(fmt.Sprintf("type imp%s interface{", serviceName))
 	for _, method := range service.Method {
-		t.P(fmt.Sprintf("%s (input %s) (output %s, err error)",
-			upperFirstLatter(method.GetName()), t.typeName(method.GetInputType()), t.typeName(method.GetOutputType())))
+		t.P(t.impMethod(service, method, ""))
 	}
 	t.P("}")
 	t.P()
@@ -178,8 +185,7 @@ func (t *tarsrpc) generateService(file *generator.FileDescriptor, service *pb.Se
 	//generate the context interface
 	t.P(fmt.Sprintf("type imp%sWithContext interface{", serviceName))
 	for _, method := range service.Method {
-		t.P(fmt.Sprintf("%s (ctx context.Context, input %s) (output %s, err error)",
-			upperFirstLatter(method.GetName()), t.typeName(method.GetInputType()), t.typeName(method.GetOutputType())))
+		t.P(t.impMethod(service, method, "ctx context.Context, "))
 	}
 	t.P("}")
 	t.P()
@@ -188,8 +194,236 @@ func (t *tarsrpc) generateService(file *generator.FileDescriptor, service *pb.Se
 	t.generateDispatch(service)
 
 	for _, method := range service.Method {
-		t.generateClientCode(service, method)
+		if isStreaming(method) {
+			t.generateStream(service, method)
+			t.generateStreamClientCode(service, method)
+		} else {
+			t.generateClientCode(service, method)
+		}
+	}
+}
+
+// isStreaming reports whether the client or the server of the method sends a stream of messages.
+func isStreaming(method *pb.MethodDescriptorProto) bool {
+	return method.GetClientStreaming() || method.GetServerStreaming()
+}
+
+// streamName returns the name of the stream type of the method, suffix is Client or Server.
+func streamName(service *pb.ServiceDescriptorProto, method *pb.MethodDescriptorProto, suffix string) string {
+	return upperFirstLatter(service.GetName()) + "_" + upperFirstLatter(method.GetName()) + suffix
+}
+
+// impMethod returns the method of the servant interface, the streaming methods get the stream.
+func (t *tarsrpc) impMethod(service *pb.ServiceDescriptorProto, method *pb.MethodDescriptorProto, ctx string) string {
+	methodName := upperFirstLatter(method.GetName())
+	inType := t.typeName(method.GetInputType())
+	outType := t.typeName(method.GetOutputType())
+	if !isStreaming(method) {
+		return fmt.Sprintf("%s (%sinput %s) (output %s, err error)", methodName, ctx, inType, outType)
+	}
+	if method.GetClientStreaming() {
+		return fmt.Sprintf("%s (%sstream %s) (err error)", methodName, ctx, streamName(service, method, "Server"))
 	}
+	return fmt.Sprintf("%s (%sinput %s, stream %s) (err error)", methodName, ctx, inType, streamName(service, method, "Server"))
+}
+
+// generateStream generates the typed streams of the client and the server of the streaming method,
+// the messages are carried by the tars stream.
+func (t *tarsrpc) generateStream(service *pb.ServiceDescriptorProto, method *pb.MethodDescriptorProto) {
+	inType := t.typeName(method.GetInputType())
+	outType := t.typeName(method.GetOutputType())
+	clientName := streamName(service, method, "Client")
+	serverName := streamName(service, method, "Server")
+	clientImp := strings.ToLower(clientName[:1]) + clientName[1:]
+	serverImp := strings.ToLower(serverName[:1]) + serverName[1:]
+
+	// the client
+	t.P(fmt.Sprintf("// %s is the client stream of %s.", clientName, method.GetName()))
+	t.P(fmt.Sprintf("type %s interface {", clientName))
+	if method.GetClientStreaming() {
+		t.P(fmt.Sprintf("Send(*%s) error", inType))
+		if method.GetServerStreaming() {
+			t.P(fmt.Sprintf("Recv() (*%s, error)", outType))
+			t.P("CloseSend() error")
+		} else {
+			t.P(fmt.Sprintf("CloseAndRecv() (*%s, error)", outType))
+		}
+	} else {
+		t.P(fmt.Sprintf("Recv() (*%s, error)", outType))
+	}
+	t.P("Context() context.Context")
+	t.P("}")
+	t.P()
+	t.P(fmt.Sprintf(`type %s struct {
+		stream *tars.ClientStream
+	}
+
+	func (x *%s) Context() context.Context {
+		return x.stream.Context()
+	}
+
+	func (x *%s) recv() (*%s, error) {
+		b, err := x.stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		m := new(%s)
+		if err = proto.Unmarshal(b, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	`, clientImp, clientImp, clientImp, outType, outType))
+	if method.GetClientStreaming() {
+		t.P(fmt.Sprintf(`func (x *%s) Send(m *%s) error {
+			b, err := proto.Marshal(m)
+			if err != nil {
+				return err
+			}
+			return x.stream.Send(b)
+		}
+		`, clientImp, inType))
+		if method.GetServerStreaming() {
+			t.P(fmt.Sprintf(`func (x *%s) Recv() (*%s, error) {
+				return x.recv()
+			}
+
+			func (x *%s) CloseSend() error {
+				return x.stream.CloseSend()
+			}
+			`, clientImp, outType, clientImp))
+		} else {
+			t.P(fmt.Sprintf(`func (x *%s) CloseAndRecv() (*%s, error) {
+				if err := x.stream.CloseSend(); err != nil {
+					return nil, err
+				}
+				m, err := x.recv()
+				if err != nil {
+					return nil, err
+				}
+				// read the end of the stream
+				if _, err = x.stream.Recv(); err != io.EOF {
+					if err == nil {
+						err = fmt.Errorf("%s returns more than one message")
+					}
+					return nil, err
+				}
+				return m, nil
+			}
+			`, clientImp, outType, method.GetName()))
+		}
+	} else {
+		t.P(fmt.Sprintf(`func (x *%s) Recv() (*%s, error) {
+			return x.recv()
+		}
+		`, clientImp, outType))
+	}
+
+	// the server
+	t.P(fmt.Sprintf("// %s is the server stream of %s.", serverName, method.GetName()))
+	t.P(fmt.Sprintf("type %s interface {", serverName))
+	if method.GetServerStreaming() {
+		t.P(fmt.Sprintf("Send(*%s) error", outType))
+	} else {
+		t.P(fmt.Sprintf("SendAndClose(*%s) error", outType))
+	}
+	if method.GetClientStreaming() {
+		t.P(fmt.Sprintf("Recv() (*%s, error)", inType))
+	}
+	t.P("Context() context.Context")
+	t.P("}")
+	t.P()
+	t.P(fmt.Sprintf(`type %s struct {
+		stream *tars.ServerStream
+	}
+
+	func (x *%s) Context() context.Context {
+		return x.stream.Context()
+	}
+
+	func (x *%s) send(m *%s) error {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return x.stream.Send(b)
+	}
+	`, serverImp, serverImp, serverImp, outType))
+	if method.GetServerStreaming() {
+		t.P(fmt.Sprintf(`func (x *%s) Send(m *%s) error {
+			return x.send(m)
+		}
+		`, serverImp, outType))
+	} else {
+		t.P(fmt.Sprintf(`func (x *%s) SendAndClose(m *%s) error {
+			return x.send(m)
+		}
+		`, serverImp, outType))
+	}
+	if method.GetClientStreaming() {
+		t.P(fmt.Sprintf(`func (x *%s) Recv() (*%s, error) {
+			b, err := x.stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			m := new(%s)
+			if err = proto.Unmarshal(b, m); err != nil {
+				return nil, err
+			}
+			return m, nil
+		}
+		`, serverImp, inType, inType))
+	}
+}
+
+// generateStreamClientCode generates the client methods opening the stream of the streaming method.
+func (t *tarsrpc) generateStreamClientCode(service *pb.ServiceDescriptorProto, method *pb.MethodDescriptorProto) {
+	methodName := upperFirstLatter(method.GetName())
+	serviceName := upperFirstLatter(service.GetName())
+	inType := t.typeName(method.GetInputType())
+	clientName := streamName(service, method, "Client")
+	clientImp := strings.ToLower(clientName[:1]) + clientName[1:]
+	if method.GetClientStreaming() {
+		t.P(fmt.Sprintf(`// %s is client rpc method as defined, it opens the stream.
+			func (obj *%s) %s(_opt ...map[string]string)(%s, error){
+				ctx := context.Background()
+				return obj.%sWithContext(ctx, _opt...)
+			}
+
+			// %sWithContext is client rpc method as defined, it opens the stream.
+			// The stream is canceled if ctx is done.
+			func (obj *%s) %sWithContext(ctx context.Context, _opt ...map[string]string)(%s, error){
+				stream, err := tars.NewClientStream(ctx, obj.s, "%s", nil, _opt...)
+				if err != nil {
+					return nil, err
+				}
+				return &%s{stream}, nil
+			}
+		`, methodName, serviceName, methodName, clientName, methodName,
+			methodName, serviceName, methodName, clientName, method.GetName(), clientImp))
+		return
+	}
+	t.P(fmt.Sprintf(`// %s is client rpc method as defined, it opens the stream with the input.
+		func (obj *%s) %s(input %s, _opt ...map[string]string)(%s, error){
+			ctx := context.Background()
+			return obj.%sWithContext(ctx, input, _opt...)
+		}
+
+		// %sWithContext is client rpc method as defined, it opens the stream with the input.
+		// The stream is canceled if ctx is done.
+		func (obj *%s) %sWithContext(ctx context.Context, input %s, _opt ...map[string]string)(%s, error){
+			inputMarshal, err := proto.Marshal(&input)
+			if err != nil {
+				return nil, err
+			}
+			stream, err := tars.NewClientStream(ctx, obj.s, "%s", inputMarshal, _opt...)
+			if err != nil {
+				return nil, err
+			}
+			return &%s{stream}, nil
+		}
+	`, methodName, serviceName, methodName, inType, clientName, methodName,
+		methodName, serviceName, methodName, inType, clientName, method.GetName(), clientImp))
 }
 func (t *tarsrpc) generateClientCode(service *pb.ServiceDescriptorProto, method *pb.MethodDescriptorProto) {
 	methodName := upperFirstLatter(method.GetName())
@@ -266,6 +500,10 @@ func (t *tarsrpc) generateDispatch(service *pb.ServiceDescriptorProto) {
 		switch funcName {
 	`, serviceName))
 	for _, method := range service.Method {
+		if isStreaming(method) {
+			t.generateStreamDispatch(service, method)
+			continue
+		}
 		t.P(fmt.Sprintf(`case "%s":
 			inputDefine := %s{}
 			if err = proto.Unmarshal(input,&inputDefine); err != nil{
@@ -292,7 +530,7 @@ func (t *tarsrpc) generateDispatch(service *pb.ServiceDescriptorProto) {
 		`, method.GetName(), t.typeName(method.GetInputType()), t.typeName(method.GetOutputType()), serviceName, upperFirstLatter(method.GetName()), serviceName, upperFirstLatter(method.GetName())))
 	}
 	t.P(`default:
-			return fmt.Errorf("func mismatch")
+			return tars.NoFuncError(funcName)
 	}
 	var _status map[string]string
 	s, ok := current.GetResponseStatus(ctx)
@@ -320,3 +558,31 @@ func (t *tarsrpc) generateDispatch(service *pb.ServiceDescriptorProto) {
 	`)
 	t.P()
 }
+
+// generateStreamDispatch generates the case of the streaming method in the dispatcher,
+// the requests of the stream are handled by the tars stream.
+func (t *tarsrpc) generateStreamDispatch(service *pb.ServiceDescriptorProto, method *pb.MethodDescriptorProto) {
+	serviceName := upperFirstLatter(service.GetName())
+	methodName := upperFirstLatter(method.GetName())
+	serverName := streamName(service, method, "Server")
+	serverImp := strings.ToLower(serverName[:1]) + serverName[1:]
+	t.P(fmt.Sprintf(`case "%s":
+		return tars.DispatchStream(ctx, req, resp, func(ctx context.Context, stream *tars.ServerStream) error {`, method.GetName()))
+	args := "x"
+	if !method.GetClientStreaming() {
+		t.P(fmt.Sprintf(`inputDefine := %s{}
+			if err := proto.Unmarshal(input, &inputDefine); err != nil {
+				return err
+			}`, t.typeName(method.GetInputType())))
+		args = "inputDefine, x"
+	}
+	t.P(fmt.Sprintf(`x := &%s{stream}
+			if withContext == false {
+				imp := val.(imp%s)
+				return imp.%s(%s)
+			}
+			imp := val.(imp%sWithContext)
+			return imp.%s(ctx, %s)
+		})
+	`, serverImp, serviceName, methodName, args, serviceName, methodName, args))
+}
@@ -0,0 +1,173 @@
+package tarsrpc
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+)
+
+// field returns an optional field of the message.
+func field(name string, number int32, ty pb.FieldDescriptorProto_Type, typeName string) *pb.FieldDescriptorProto {
+	f := &pb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    pb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     ty.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// method returns the method of the service, streaming from the client or the server.
+func method(name, in, out string, client, server bool) *pb.MethodDescriptorProto {
+	return &pb.MethodDescriptorProto{
+		Name:            proto.String(name),
+		InputType:       proto.String(in),
+		OutputType:      proto.String(out),
+		ClientStreaming: proto.Bool(client),
+		ServerStreaming: proto.Bool(server),
+	}
+}
+
+// routeGuide is the descriptor of a proto file with the unary, the server streaming, the client streaming
+// and the bidirectional streaming methods, like the one protoc passes to the plugin.
+func routeGuide() *pb.FileDescriptorProto {
+	return &pb.FileDescriptorProto{
+		Name:    proto.String("routeguide/route_guide.proto"),
+		Package: proto.String("routeguide"),
+		Syntax:  proto.String("proto3"),
+		Options: &pb.FileOptions{GoPackage: proto.String("gentest/routeguide;routeguide")},
+		MessageType: []*pb.DescriptorProto{
+			{
+				Name: proto.String("Point"),
+				Field: []*pb.FieldDescriptorProto{
+					field("latitude", 1, pb.FieldDescriptorProto_TYPE_INT32, ""),
+					field("longitude", 2, pb.FieldDescriptorProto_TYPE_INT32, ""),
+				},
+			},
+			{
+				Name: proto.String("Feature"),
+				Field: []*pb.FieldDescriptorProto{
+					field("name", 1, pb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("location", 2, pb.FieldDescriptorProto_TYPE_MESSAGE, ".routeguide.Point"),
+				},
+			},
+			{
+				Name:  proto.String("RouteSummary"),
+				Field: []*pb.FieldDescriptorProto{field("point_count", 1, pb.FieldDescriptorProto_TYPE_INT32, "")},
+			},
+		},
+		Service: []*pb.ServiceDescriptorProto{
+			{
+				Name: proto.String("RouteGuide"),
+				Method: []*pb.MethodDescriptorProto{
+					method("GetFeature", ".routeguide.Point", ".routeguide.Feature", false, false),
+					method("ListFeatures", ".routeguide.Point", ".routeguide.Feature", false, true),
+					method("RecordRoute", ".routeguide.Point", ".routeguide.RouteSummary", true, false),
+					method("RouteChat", ".routeguide.Feature", ".routeguide.Feature", true, true),
+				},
+			},
+		},
+	}
+}
+
+// generate runs protoc-gen-go with the tarsrpc plugin on the file, and returns the generated go code.
+func generate(t *testing.T, file *pb.FileDescriptorProto) string {
+	t.Helper()
+	g := generator.New()
+	g.Request.FileToGenerate = []string{file.GetName()}
+	g.Request.Parameter = proto.String("plugins=tarsrpc,paths=source_relative")
+	g.Request.ProtoFile = []*pb.FileDescriptorProto{file}
+	g.CommandLineParameters(g.Request.GetParameter())
+	g.WrapTypes()
+	g.SetPackageNames()
+	g.BuildTypeNameMap()
+	g.GenerateAllFiles()
+	if len(g.Response.File) != 1 {
+		t.Fatalf("got %d files, want 1", len(g.Response.File))
+	}
+	if name := g.Response.File[0].GetName(); name != "routeguide/route_guide.pb.go" {
+		t.Fatalf("generated file %s", name)
+	}
+	return g.Response.File[0].GetContent()
+}
+
+// goBuild builds the generated code in dir with the tars package of this repo and the protobuf of this module.
+func goBuild(t *testing.T, dir string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("building the generated code in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not found")
+	}
+	root, err := filepath.Abs("../../../../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod, err := ioutil.ReadFile("../../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the requirements of this module, with the tars package of this repo
+	gomod := strings.Replace(string(mod), "module github.com/MacgradyHuang/TarsGo/tars/tools/pb2tarsgo", "module gentest", 1) +
+		"\nrequire github.com/MacgradyHuang/TarsGo v0.0.0\n\nreplace github.com/MacgradyHuang/TarsGo => " + root + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goBin, "build", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off", "GOPROXY=off", "GOSUMDB=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+}
+
+// TestGenerate tests the code generated for the unary and the streaming methods, and builds it.
+func TestGenerate(t *testing.T) {
+	code := generate(t, routeGuide())
+	for _, want := range []string{
+		"type impRouteGuide interface",
+		"GetFeature(input Point) (output Feature, err error)",
+		"ListFeatures(input Point, stream RouteGuide_ListFeaturesServer) (err error)",
+		"RecordRoute(stream RouteGuide_RecordRouteServer) (err error)",
+		"RouteChat(ctx context.Context, stream RouteGuide_RouteChatServer) (err error)",
+		"type RouteGuide_ListFeaturesClient interface",
+		"type RouteGuide_RecordRouteClient interface",
+		"type RouteGuide_RouteChatClient interface",
+		"CloseAndRecv() (*RouteSummary, error)",
+		"SendAndClose(*RouteSummary) error",
+		"func (obj *RouteGuide) RouteChatWithContext(ctx context.Context, _opt ...map[string]string) (RouteGuide_RouteChatClient, error)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("%q is not generated", want)
+		}
+	}
+	if t.Failed() {
+		t.Fatal(code)
+	}
+
+	dir, err := ioutil.TempDir("", "tarsrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "routeguide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "routeguide", "route_guide.pb.go"), []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	goBuild(t, dir)
+}
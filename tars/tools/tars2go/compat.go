@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exit codes of the compatibility check
+const (
+	compatOK       = 0
+	compatBreaking = 1
+	compatError    = 2
+)
+
+// compatChange is a difference found between two versions of the tars file.
+type compatChange struct {
+	breaking bool
+	msg      string
+}
+
+// compatSchema is everything defined by a tars file and its includes, keyed by module::name.
+type compatSchema struct {
+	structs    map[string]*StructInfo
+	enums      map[string]*EnumInfo
+	interfaces map[string]*InterfaceInfo
+	modules    map[string]string // module of every key above
+}
+
+func newCompatSchema(p *Parse) *compatSchema {
+	s := &compatSchema{
+		structs:    make(map[string]*StructInfo),
+		enums:      make(map[string]*EnumInfo),
+		interfaces: make(map[string]*InterfaceInfo),
+		modules:    make(map[string]string),
+	}
+	s.add(p)
+	return s
+}
+
+func (s *compatSchema) add(p *Parse) {
+	for i := range p.Struct {
+		key := p.Module + "::" + p.Struct[i].Name
+		s.structs[key] = &p.Struct[i]
+		s.modules[key] = p.Module
+	}
+	for i := range p.Enum {
+		key := p.Module + "::" + p.Enum[i].Name
+		s.enums[key] = &p.Enum[i]
+		s.modules[key] = p.Module
+	}
+	for i := range p.Interface {
+		key := p.Module + "::" + p.Interface[i].Name
+		s.interfaces[key] = &p.Interface[i]
+		s.modules[key] = p.Module
+	}
+	for _, inc := range p.IncParse {
+		s.add(inc)
+	}
+}
+
+// compatTypeName returns the type as written in the tars file, custom types are qualified with the module.
+func compatTypeName(ty *VarType, module string) string {
+	if ty == nil {
+		return "void"
+	}
+	switch ty.Type {
+	case tkTVector:
+		return "vector<" + compatTypeName(ty.TypeK, module) + ">"
+	case tkTMap:
+		return "map<" + compatTypeName(ty.TypeK, module) + ", " + compatTypeName(ty.TypeV, module) + ">"
+	case tkTArray:
+		return compatTypeName(ty.TypeK, module) + "[" + strconv.FormatInt(ty.TypeL, 10) + "]"
+	case tkName:
+		if strings.Contains(ty.TypeSt, "::") {
+			return ty.TypeSt
+		}
+		return module + "::" + ty.TypeSt
+	}
+	name := TokenMap[ty.Type]
+	if ty.Unsigned {
+		name = "unsigned " + name
+	}
+	return name
+}
+
+// compatEnumValues returns the value of every member by the name in the tars file, like the generated constants.
+func compatEnumValues(en *EnumInfo) map[string]int32 {
+	values := make(map[string]int32)
+	var it int32
+	for _, v := range en.Mb {
+		switch v.Type {
+		case 0:
+			it = v.Value
+		case 1:
+			it = values[v.Name]
+		}
+		if v.OriginKey != "" {
+			values[v.OriginKey] = it
+		} else {
+			values[v.Key] = it
+		}
+		it++
+	}
+	return values
+}
+
+type compatChecker struct {
+	oldS, newS *compatSchema
+	changes    []compatChange
+}
+
+func (c *compatChecker) breaking(format string, args ...interface{}) {
+	c.changes = append(c.changes, compatChange{breaking: true, msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *compatChecker) safe(format string, args ...interface{}) {
+	c.changes = append(c.changes, compatChange{msg: fmt.Sprintf(format, args...)})
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *compatChecker) check() {
+	for _, key := range sortedKeys(c.oldS.modules) {
+		if st, ok := c.oldS.structs[key]; ok {
+			if nst, ok := c.newS.structs[key]; ok {
+				c.checkStruct(key, st, nst)
+			} else {
+				c.breaking("struct %s removed", key)
+			}
+		} else if en, ok := c.oldS.enums[key]; ok {
+			if nen, ok := c.newS.enums[key]; ok {
+				c.checkEnum(key, en, nen)
+			} else {
+				c.breaking("enum %s removed", key)
+			}
+		} else if itf, ok := c.oldS.interfaces[key]; ok {
+			if nitf, ok := c.newS.interfaces[key]; ok {
+				c.checkInterface(key, itf, nitf)
+			} else {
+				c.breaking("interface %s removed", key)
+			}
+		}
+	}
+	for _, key := range sortedKeys(c.newS.modules) {
+		if _, ok := c.oldS.modules[key]; !ok {
+			c.safe("%s added", key)
+		}
+	}
+}
+
+func (c *compatChecker) checkStruct(key string, st, nst *StructInfo) {
+	oldModule, newModule := c.oldS.modules[key], c.newS.modules[key]
+	newMb := make(map[int32]*StructMember)
+	for i := range nst.Mb {
+		newMb[nst.Mb[i].Tag] = &nst.Mb[i]
+	}
+	oldTags := make(map[int32]bool)
+	for _, mb := range st.Mb {
+		oldTags[mb.Tag] = true
+		nmb, ok := newMb[mb.Tag]
+		if !ok {
+			if mb.Require {
+				c.breaking("struct %s: require member %d %s removed", key, mb.Tag, mb.Key)
+			} else {
+				c.safe("struct %s: optional member %d %s removed", key, mb.Tag, mb.Key)
+			}
+			continue
+		}
+		oldType, newType := compatTypeName(mb.Type, oldModule), compatTypeName(nmb.Type, newModule)
+		if oldType != newType {
+			c.breaking("struct %s: member %d %s type changed from %s to %s", key, mb.Tag, mb.Key, oldType, newType)
+		}
+		if !mb.Require && nmb.Require {
+			c.breaking("struct %s: member %d %s changed from optional to require", key, mb.Tag, mb.Key)
+		} else if mb.Require && !nmb.Require {
+			c.safe("struct %s: member %d %s changed from require to optional", key, mb.Tag, mb.Key)
+		}
+		if mb.Key != nmb.Key {
+			c.safe("struct %s: member %d renamed from %s to %s", key, mb.Tag, mb.Key, nmb.Key)
+		}
+		if mb.Default != nmb.Default {
+			c.safe("struct %s: member %d %s default changed from %q to %q", key, mb.Tag, mb.Key, mb.Default, nmb.Default)
+		}
+	}
+	for _, nmb := range nst.Mb {
+		if oldTags[nmb.Tag] {
+			continue
+		}
+		if nmb.Require {
+			c.breaking("struct %s: require member %d %s added", key, nmb.Tag, nmb.Key)
+		} else {
+			c.safe("struct %s: optional member %d %s added", key, nmb.Tag, nmb.Key)
+		}
+	}
+}
+
+func (c *compatChecker) checkEnum(key string, en, nen *EnumInfo) {
+	oldValues, newValues := compatEnumValues(en), compatEnumValues(nen)
+	for _, mb := range en.Mb {
+		v, ok := newValues[mb.Key]
+		if !ok {
+			c.breaking("enum %s: member %s removed", key, mb.Key)
+		} else if v != oldValues[mb.Key] {
+			c.breaking("enum %s: member %s value changed from %d to %d", key, mb.Key, oldValues[mb.Key], v)
+		}
+	}
+	for _, mb := range nen.Mb {
+		if _, ok := oldValues[mb.Key]; !ok {
+			c.safe("enum %s: member %s added", key, mb.Key)
+		}
+	}
+}
+
+func compatSameArg(arg, narg *ArgInfo, oldModule, newModule string) bool {
+	return arg.IsOut == narg.IsOut && compatTypeName(arg.Type, oldModule) == compatTypeName(narg.Type, newModule)
+}
+
+// compatOneArg finds the only argument added to or removed from the arguments, the others keep their types
+// and order. It returns the position of the argument, and whether it is added.
+func compatOneArg(args, nargs []ArgInfo, oldModule, newModule string) (i int, added bool, ok bool) {
+	short, long := args, nargs
+	added = true
+	if len(args) == len(nargs)+1 {
+		short, long = nargs, args
+		added = false
+	} else if len(nargs) != len(args)+1 {
+		return 0, false, false
+	}
+	same := func(j, k int) bool {
+		if added {
+			return compatSameArg(&short[j], &long[k], oldModule, newModule)
+		}
+		return compatSameArg(&long[k], &short[j], oldModule, newModule)
+	}
+	// the names tell where the argument is among the arguments of the same type
+	for i < len(short) && same(i, i) && short[i].Name == long[i].Name {
+		i++
+	}
+	for j := i; j < len(short); j++ {
+		if !same(j, j+1) {
+			return 0, false, false
+		}
+	}
+	return i, added, true
+}
+
+func (c *compatChecker) checkInterface(key string, itf, nitf *InterfaceInfo) {
+	oldModule, newModule := c.oldS.modules[key], c.newS.modules[key]
+	newFun := make(map[string]*FunInfo)
+	for i := range nitf.Fun {
+		newFun[nitf.Fun[i].Name] = &nitf.Fun[i]
+	}
+	for _, fun := range itf.Fun {
+		nfun, ok := newFun[fun.Name]
+		if !ok {
+			c.breaking("interface %s: function %s removed", key, fun.Name)
+			continue
+		}
+		delete(newFun, fun.Name)
+
+		oldRet, newRet := compatTypeName(fun.RetType, oldModule), compatTypeName(nfun.RetType, newModule)
+		if oldRet != newRet {
+			c.breaking("interface %s: function %s return type changed from %s to %s", key, fun.Name, oldRet, newRet)
+		}
+		// arguments are encoded with the tag of their position
+		if i, added, ok := compatOneArg(fun.Args, nfun.Args, oldModule, newModule); ok {
+			if added {
+				c.breaking("interface %s: function %s argument %d %s added", key, fun.Name, i+1, nfun.Args[i].Name)
+			} else {
+				c.breaking("interface %s: function %s argument %d %s removed", key, fun.Name, i+1, fun.Args[i].Name)
+			}
+			continue
+		}
+		for i, arg := range fun.Args {
+			if i >= len(nfun.Args) {
+				c.breaking("interface %s: function %s argument %d %s removed", key, fun.Name, i+1, arg.Name)
+				continue
+			}
+			narg := nfun.Args[i]
+			oldType, newType := compatTypeName(arg.Type, oldModule), compatTypeName(narg.Type, newModule)
+			if oldType != newType {
+				c.breaking("interface %s: function %s argument %d %s type changed from %s to %s",
+					key, fun.Name, i+1, arg.Name, oldType, newType)
+			}
+			if arg.IsOut != narg.IsOut {
+				c.breaking("interface %s: function %s argument %d %s changed out", key, fun.Name, i+1, arg.Name)
+			}
+			if arg.Name != narg.Name {
+				c.safe("interface %s: function %s argument %d renamed from %s to %s", key, fun.Name, i+1, arg.Name, narg.Name)
+			}
+		}
+		for i := len(fun.Args); i < len(nfun.Args); i++ {
+			c.breaking("interface %s: function %s argument %d %s added", key, fun.Name, i+1, nfun.Args[i].Name)
+		}
+	}
+	for _, nfun := range nitf.Fun {
+		if _, ok := newFun[nfun.Name]; ok {
+			c.safe("interface %s: function %s added", key, nfun.Name)
+		}
+	}
+}
+
+// checkCompat compares two versions of a tars file and prints the changes, it returns the exit code.
+func checkCompat(oldPath string, newPath string) (code int) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = compatError
+		}
+	}()
+
+	for _, path := range []string{oldPath, newPath} {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return compatError
+		}
+	}
+	c := &compatChecker{
+		oldS: newCompatSchema(ParseFile(oldPath, make([]string, 0))),
+		newS: newCompatSchema(ParseFile(newPath, make([]string, 0))),
+	}
+	c.check()
+
+	code = compatOK
+	for _, v := range c.changes {
+		if v.breaking {
+			fmt.Println("BREAKING: " + v.msg)
+			code = compatBreaking
+		}
+	}
+	for _, v := range c.changes {
+		if !v.breaking {
+			fmt.Println("SAFE: " + v.msg)
+		}
+	}
+	return code
+}
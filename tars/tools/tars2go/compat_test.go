@@ -0,0 +1,307 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTars writes the body into the module Test of a tars file in dir, and returns the path.
+func writeTars(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte("module Test\n{\n"+body+"\n};\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// capture returns what f prints to the file, such as os.Stdout.
+func capture(t *testing.T, file **os.File, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := *file
+	*file = w
+	defer func() { *file = old }()
+	out := make(chan string)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		out <- string(b)
+	}()
+	f()
+	w.Close()
+	return <-out
+}
+
+func TestCompat(t *testing.T) {
+	const (
+		st   = "struct A\n{\n%s\n};"
+		en   = "enum E\n{\n%s\n};"
+		itf  = "interface I\n{\n%s\n};"
+		base = "0 require int a;\n1 optional string b = \"x\";"
+	)
+	f := func(format, body string) string { return strings.Replace(format, "%s", body, 1) }
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{
+			name: "unchanged",
+			old:  f(st, base) + f(en, "RED, GREEN") + f(itf, "int hello(int a, out string b);"),
+			new:  f(st, base) + f(en, "RED, GREEN") + f(itf, "int hello(int a, out string b);"),
+		},
+		{
+			name: "struct removed",
+			old:  f(st, base) + "struct B\n{\n0 require int a;\n};",
+			new:  f(st, base),
+			want: []string{"BREAKING: struct Test::B removed"},
+		},
+		{
+			name: "enum removed",
+			old:  f(st, base) + f(en, "RED"),
+			new:  f(st, base),
+			want: []string{"BREAKING: enum Test::E removed"},
+		},
+		{
+			name: "interface removed",
+			old:  f(st, base) + f(itf, "void ping();"),
+			new:  f(st, base),
+			want: []string{"BREAKING: interface Test::I removed"},
+		},
+		{
+			name: "type added",
+			old:  f(st, base),
+			new:  f(st, base) + f(en, "RED") + f(itf, "void ping();"),
+			want: []string{"SAFE: Test::E added", "SAFE: Test::I added"},
+		},
+		{
+			name: "require member removed",
+			old:  f(st, base),
+			new:  f(st, "1 optional string b = \"x\";"),
+			want: []string{"BREAKING: struct Test::A: require member 0 a removed"},
+		},
+		{
+			name: "optional member removed",
+			old:  f(st, base),
+			new:  f(st, "0 require int a;"),
+			want: []string{"SAFE: struct Test::A: optional member 1 b removed"},
+		},
+		{
+			name: "member type changed",
+			old:  f(st, base) + "struct B\n{\n0 optional A a;\n1 optional vector<int> v;\n};",
+			new:  f(st, base) + "struct C\n{\n0 optional int a;\n};\nstruct B\n{\n0 optional C a;\n1 optional vector<long> v;\n};",
+			want: []string{
+				"BREAKING: struct Test::B: member 0 a type changed from Test::A to Test::C",
+				"BREAKING: struct Test::B: member 1 v type changed from vector<int> to vector<long>",
+				"SAFE: Test::C added",
+			},
+		},
+		{
+			name: "member changed to require",
+			old:  f(st, base),
+			new:  f(st, "0 require int a;\n1 require string b = \"x\";"),
+			want: []string{"BREAKING: struct Test::A: member 1 b changed from optional to require"},
+		},
+		{
+			name: "member changed to optional",
+			old:  f(st, base),
+			new:  f(st, "0 optional int a;\n1 optional string b = \"x\";"),
+			want: []string{"SAFE: struct Test::A: member 0 a changed from require to optional"},
+		},
+		{
+			name: "member renamed",
+			old:  f(st, base),
+			new:  f(st, "0 require int c;\n1 optional string b = \"x\";"),
+			want: []string{"SAFE: struct Test::A: member 0 renamed from a to c"},
+		},
+		{
+			name: "member default changed",
+			old:  f(st, base),
+			new:  f(st, "0 require int a;\n1 optional string b = \"y\";"),
+			want: []string{`SAFE: struct Test::A: member 1 b default changed from "\"x\"" to "\"y\""`},
+		},
+		{
+			name: "require member added",
+			old:  f(st, base),
+			new:  f(st, base+"\n2 require int c;"),
+			want: []string{"BREAKING: struct Test::A: require member 2 c added"},
+		},
+		{
+			name: "optional member added",
+			old:  f(st, base),
+			new:  f(st, base+"\n2 optional int c;"),
+			want: []string{"SAFE: struct Test::A: optional member 2 c added"},
+		},
+		{
+			name: "enum member removed",
+			old:  f(en, "RED, GREEN"),
+			new:  f(en, "RED"),
+			want: []string{"BREAKING: enum Test::E: member GREEN removed"},
+		},
+		{
+			name: "enum member value changed",
+			old:  f(en, "RED, GREEN"),
+			new:  f(en, "RED, BLUE, GREEN"),
+			want: []string{"BREAKING: enum Test::E: member GREEN value changed from 1 to 2", "SAFE: enum Test::E: member BLUE added"},
+		},
+		{
+			name: "enum member added",
+			old:  f(en, "RED = 1, GREEN"),
+			new:  f(en, "RED = 1, GREEN, BLUE = 5"),
+			want: []string{"SAFE: enum Test::E: member BLUE added"},
+		},
+		{
+			name: "function removed",
+			old:  f(itf, "void ping();\nint hello(int a);"),
+			new:  f(itf, "void ping();"),
+			want: []string{"BREAKING: interface Test::I: function hello removed"},
+		},
+		{
+			name: "function added",
+			old:  f(itf, "void ping();"),
+			new:  f(itf, "void ping();\nint hello(int a);"),
+			want: []string{"SAFE: interface Test::I: function hello added"},
+		},
+		{
+			name: "return type changed",
+			old:  f(itf, "int hello(int a);"),
+			new:  f(itf, "long hello(int a);"),
+			want: []string{"BREAKING: interface Test::I: function hello return type changed from int to long"},
+		},
+		{
+			name: "argument type changed",
+			old:  f(itf, "int hello(int a, string b);"),
+			new:  f(itf, "int hello(int a, vector<byte> b);"),
+			want: []string{"BREAKING: interface Test::I: function hello argument 2 b type changed from string to vector<byte>"},
+		},
+		{
+			name: "argument out changed",
+			old:  f(itf, "int hello(int a, string b);"),
+			new:  f(itf, "int hello(int a, out string b);"),
+			want: []string{"BREAKING: interface Test::I: function hello argument 2 b changed out"},
+		},
+		{
+			name: "argument renamed",
+			old:  f(itf, "int hello(int a, string b);"),
+			new:  f(itf, "int hello(int a, string c);"),
+			want: []string{"SAFE: interface Test::I: function hello argument 2 renamed from b to c"},
+		},
+		{
+			name: "argument added",
+			old:  f(itf, "int hello(int a);"),
+			new:  f(itf, "int hello(int a, string b);"),
+			want: []string{"BREAKING: interface Test::I: function hello argument 2 b added"},
+		},
+		{
+			name: "argument removed",
+			old:  f(itf, "int hello(int a, string b);"),
+			new:  f(itf, "int hello(int a);"),
+			want: []string{"BREAKING: interface Test::I: function hello argument 2 b removed"},
+		},
+		{
+			name: "argument inserted",
+			old:  f(itf, "int hello(int a, string b, long c, out string d);"),
+			new:  f(itf, "int hello(int a, bool x, string b, long c, out string d);"),
+			want: []string{"BREAKING: interface Test::I: function hello argument 2 x added"},
+		},
+		{
+			name: "argument inserted before the same type",
+			old:  f(itf, "int hello(int a, int b);"),
+			new:  f(itf, "int hello(int x, int a, int b);"),
+			want: []string{"BREAKING: interface Test::I: function hello argument 1 x added"},
+		},
+		{
+			name: "argument removed from the middle",
+			old:  f(itf, "int hello(int a, bool x, string b, out string d);"),
+			new:  f(itf, "int hello(int a, string b, out string d);"),
+			want: []string{"BREAKING: interface Test::I: function hello argument 2 x removed"},
+		},
+		{
+			name: "arguments changed",
+			old:  f(itf, "int hello(int a, string b);"),
+			new:  f(itf, "int hello(string b, int a, bool c);"),
+			want: []string{
+				"BREAKING: interface Test::I: function hello argument 1 a type changed from int to string",
+				"SAFE: interface Test::I: function hello argument 1 renamed from a to b",
+				"BREAKING: interface Test::I: function hello argument 2 b type changed from string to int",
+				"SAFE: interface Test::I: function hello argument 2 renamed from b to a",
+				"BREAKING: interface Test::I: function hello argument 3 c added",
+			},
+		},
+	}
+
+	defer func(quiet bool) { gQuiet = quiet }(gQuiet)
+	gQuiet = true
+	dir, err := ioutil.TempDir("", "tars2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &compatChecker{
+				oldS: newCompatSchema(ParseFile(writeTars(t, dir, "old.tars", tt.old), make([]string, 0))),
+				newS: newCompatSchema(ParseFile(writeTars(t, dir, "new.tars", tt.new), make([]string, 0))),
+			}
+			c.check()
+			var got []string
+			for _, v := range c.changes {
+				if v.breaking {
+					got = append(got, "BREAKING: "+v.msg)
+				} else {
+					got = append(got, "SAFE: "+v.msg)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestCheckCompat tests the exit codes and the output of -compat, which prints nothing but the changes.
+func TestCheckCompat(t *testing.T) {
+	defer func(quiet bool) { gQuiet = quiet }(gQuiet)
+	gQuiet = true
+	dir, err := ioutil.TempDir("", "tars2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldPath := writeTars(t, dir, "old.tars", "struct A\n{\n0 require int a;\n};")
+	safePath := writeTars(t, dir, "safe.tars", "struct A\n{\n0 require int a;\n1 optional int b;\n};")
+	breakingPath := writeTars(t, dir, "breaking.tars", "struct A\n{\n0 require long a;\n1 optional int b;\n};")
+	badPath := writeTars(t, dir, "bad.tars", "struct A\n{\n0 require int a\n};")
+
+	tests := []struct {
+		name     string
+		old, new string
+		code     int
+		out      string
+	}{
+		{name: "same", old: oldPath, new: oldPath, code: compatOK},
+		{name: "safe", old: oldPath, new: safePath, code: compatOK,
+			out: "SAFE: struct Test::A: optional member 1 b added\n"},
+		{name: "breaking", old: oldPath, new: breakingPath, code: compatBreaking,
+			out: "BREAKING: struct Test::A: member 0 a type changed from int to long\n" +
+				"SAFE: struct Test::A: optional member 1 b added\n"},
+		{name: "missing", old: oldPath, new: filepath.Join(dir, "missing.tars"), code: compatError},
+		{name: "bad", old: oldPath, new: badPath, code: compatError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			out := capture(t, &os.Stdout, func() { code = checkCompat(tt.old, tt.new) })
+			if code != tt.code || out != tt.out {
+				t.Fatalf("got %d %q, want %d %q", code, out, tt.code, tt.out)
+			}
+		})
+	}
+}
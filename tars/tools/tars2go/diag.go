@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Pos is a position in the tars file, line and column start from 1.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+// before reports whether p is before q.
+func (p Pos) before(q Pos) bool {
+	return p.Line < q.Line || (p.Line == q.Line && p.Col < q.Col)
+}
+
+// severity of the diagnostics, the same as the language server protocol.
+const (
+	diagError   = 1
+	diagWarning = 2
+)
+
+// Diagnostic is an error found in the tars file, from Pos to End(exclusive).
+type Diagnostic struct {
+	Source   string
+	Pos      Pos
+	End      Pos
+	Severity int
+	Msg      string
+}
+
+// Error returns the diagnostic as file:line:col: msg.
+func (d *Diagnostic) Error() string {
+	return d.Source + ":" + strconv.Itoa(d.Pos.Line) + ":" + strconv.Itoa(d.Pos.Col) + ": " + d.Msg
+}
+
+// try runs f and records the diagnostic it panics with, it reports whether f succeeded.
+// Other panics are not recovered.
+func (p *Parse) try(f func()) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			d, isDiag := err.(*Diagnostic)
+			if !isDiag {
+				panic(err)
+			}
+			p.Diags = append(p.Diags, *d)
+			ok = false
+		}
+	}()
+	f()
+	return true
+}
+
+// AllDiags returns the diagnostics of the file and its includes, each reported once.
+func (p *Parse) AllDiags() []Diagnostic {
+	var diags []Diagnostic
+	seen := make(map[string]bool)
+	var walk func(p *Parse)
+	walk = func(p *Parse) {
+		for _, inc := range p.IncParse {
+			walk(inc)
+		}
+		for _, d := range p.Diags {
+			if !seen[d.Error()] {
+				seen[d.Error()] = true
+				diags = append(diags, d)
+			}
+		}
+	}
+	walk(p)
+	return diags
+}
+
+// sortDiags sorts the diagnostics by file and position.
+func sortDiags(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Source != diags[j].Source {
+			return diags[i].Source < diags[j].Source
+		}
+		return diags[i].Pos.before(diags[j].Pos)
+	})
+}
+
+// checkFiles parses the files and prints all the diagnostics, it returns the exit code.
+func checkFiles(paths []string) int {
+	code := 0
+	for _, path := range paths {
+		p := parseFile(path, make([]string, 0))
+		diags := p.AllDiags()
+		sortDiags(diags)
+		for _, d := range diags {
+			level := "error"
+			if d.Severity == diagWarning {
+				level = "warning"
+			} else {
+				code = 1
+			}
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s: %s\n", d.Source, d.Pos.Line, d.Pos.Col, level, d.Msg)
+		}
+	}
+	return code
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files by name into a temp dir, and returns the dir.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tars2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const badTars = `module Test
+{
+	struct A
+	{
+		0 require int a;
+		0 optional int b;
+	};
+
+	struct B
+	{
+		0 require Unknown u;
+		1 optional vector<int> v
+	};
+
+	interface I
+	{
+		int hello(A a, out Missing m);
+	};
+
+	struct C
+	{
+		0 optional int c = "x";
+	};
+};
+`
+
+// TestCheckFiles tests -check reports all the errors of the files and their includes at their positions.
+func TestCheckFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Bad.tars": badTars,
+		"Inc.tars": "#include \"Bad.tars\"\nmodule Inc\n{\n\tstruct D\n\t{\n\t\t0 require Test::A a;\n" +
+			"\t\t1 require Test::Nope n;\n\t};\n};\n",
+		"Lex.tars":    "module Lex\n{\n\tstruct A\n\t{\n\t\t0 require int a @;\n\t};\n};\n",
+		"Cycle1.tars": "#include \"Cycle2.tars\"\nmodule Cycle1\n{\n};\n",
+		"Cycle2.tars": "#include \"Cycle1.tars\"\nmodule Cycle2\n{\n};\n",
+		"Miss.tars":   "#include \"Missing.tars\"\nmodule Miss\n{\n};\n",
+		"Ok.tars":     "module Ok\n{\n\tstruct A\n\t{\n\t\t0 require int a;\n\t};\n};\n",
+	})
+	defer os.RemoveAll(dir)
+	defer func(quiet bool) { gQuiet = quiet }(gQuiet)
+	gQuiet = true
+
+	bad := []string{
+		"Bad.tars:6:18: error: tag = 0. have duplicates",
+		"Bad.tars:11:13: error: Unknown not find define",
+		"Bad.tars:13:2: error: expect ; or =",
+		"Bad.tars:17:22: error: Missing not find define",
+		"Bad.tars:22:22: error: type does not accept string",
+	}
+	tests := []struct {
+		name  string
+		files []string
+		code  int
+		want  []string
+	}{
+		{name: "errors", files: []string{"Bad.tars"}, code: 1, want: bad},
+		{name: "errors of includes", files: []string{"Inc.tars"}, code: 1,
+			want: append(append([]string(nil), bad...), "Inc.tars:7:13: error: Test::Nope not find define")},
+		{name: "lexer", files: []string{"Lex.tars"}, code: 1, want: []string{"Lex.tars:5:19: error: unrecognized characters, @"}},
+		{name: "circular include", files: []string{"Cycle1.tars"}, code: 1,
+			want: []string{"Cycle1.tars:1:1: error: jce circular reference: " + filepath.Join(dir, "Cycle1.tars")}},
+		{name: "missing include", files: []string{"Miss.tars"}, code: 1,
+			want: []string{"Miss.tars:1:10: error: file read error: open Missing.tars: no such file or directory"}},
+		{name: "files", files: []string{"Ok.tars", "Lex.tars"}, code: 1, want: []string{"Lex.tars:5:19: error: unrecognized characters, @"}},
+		{name: "ok", files: []string{"Ok.tars"}, code: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			for _, f := range tt.files {
+				paths = append(paths, filepath.Join(dir, f))
+			}
+			var code int
+			var stdout string
+			stderr := capture(t, &os.Stderr, func() {
+				stdout = capture(t, &os.Stdout, func() { code = checkFiles(paths) })
+			})
+			var want string
+			for _, line := range tt.want {
+				want += filepath.Join(dir, line) + "\n"
+			}
+			if code != tt.code || stderr != want || stdout != "" {
+				t.Fatalf("got %d\n%s\nwant %d\n%s\nstdout: %q", code, stderr, tt.code, want, stdout)
+			}
+		})
+	}
+}
+
+// TestDiagsOfInclude tests the errors of an included file are reported once with the file.
+func TestDiagsOfInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Bad.tars": badTars,
+		"Two.tars": "#include \"Bad.tars\"\n#include \"One.tars\"\nmodule Two\n{\n};\n",
+		"One.tars": "#include \"Bad.tars\"\nmodule One\n{\n};\n",
+	})
+	defer os.RemoveAll(dir)
+	defer func(quiet bool) { gQuiet = quiet }(gQuiet)
+	gQuiet = true
+
+	diags := parseFile(filepath.Join(dir, "Two.tars"), make([]string, 0)).AllDiags()
+	sortDiags(diags)
+	var got []string
+	for _, d := range diags {
+		got = append(got, strings.TrimPrefix(d.Error(), dir+string(filepath.Separator)))
+	}
+	want := "Bad.tars:6:18: tag = 0. have duplicates\nBad.tars:11:13: Unknown not find define\n" +
+		"Bad.tars:13:2: expect ; or =\nBad.tars:17:22: Missing not find define\nBad.tars:22:22: type does not accept string"
+	if strings.Join(got, "\n") != want {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), want)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGenDoc tests the markdown, the html and the openapi documents of testdata/doc/Doc.tars by their golden
+// files, with the comments of the tars file.
+func TestGenDoc(t *testing.T) {
+	defer func(quiet bool) { gQuiet = quiet }(gQuiet)
+	gQuiet = true
+	dir, err := ioutil.TempDir("", "tars2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gDocFileMap = make(map[string]bool)
+	NewGenDoc("testdata/doc/Doc.tars", dir, []string{"markdown", "html", "openapi"}).Gen()
+	for _, name := range []string{"Doc.md", "Doc.html", "Doc.openapi.json"} {
+		checkGolden(t, filepath.Join(dir, name), filepath.Join("testdata/doc", name+".golden"))
+	}
+}
+
+// TestGenComments tests the comments of the tars file are kept in the generated structs, enums and proxies,
+// and the reflection has the tars names of the renamed functions and arguments.
+func TestGenComments(t *testing.T) {
+	defer setFlags(map[*bool]bool{gReflection: true})()
+	dir := genGo(t, "testdata/doc/Doc.tars")
+	defer os.RemoveAll(dir)
+
+	files, err := filepath.Glob(filepath.Join(dir, "Doc", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		checkGolden(t, file, filepath.Join("testdata/doc", filepath.Base(file)+".golden"))
+	}
+	goTest(t, dir, "./Doc")
+}
@@ -0,0 +1,687 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The formatter re-emits a tars file in the canonical style: 4 spaces indentation, braces on their own line,
+// aligned struct members and trailing comments, and sorted includes. All the comments are kept.
+// It works on the tokens, so the file does not need to resolve its includes.
+
+const fmtIndent = "    "
+
+// fmtRow is a line in a block: a definition split in columns, a comment or a blank line.
+type fmtRow struct {
+	cells    []string // the cells are aligned, except the last one
+	trailing string   // comment following the definition
+	comment  string   // comment on its own line
+	blank    bool
+}
+
+type formatter struct {
+	toks     []*Token
+	i        int
+	lastLine int      // the line of the last token read
+	pending  []string // comments inside a definition, appended to its trailing comment
+	out      bytes.Buffer
+}
+
+// formatTars formats the tars file.
+func formatTars(source string, src []byte) (out []byte, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if d, ok := e.(*Diagnostic); ok {
+				err = d
+				return
+			}
+			panic(e)
+		}
+	}()
+
+	ls := NewLexState(source, src)
+	ls.keepComments = true
+	f := &formatter{}
+	for {
+		t := ls.NextToken()
+		f.toks = append(f.toks, t)
+		if t.T == tkEos {
+			break
+		}
+	}
+	f.formatFile(source)
+	return f.out.Bytes(), nil
+}
+
+func (f *formatter) errAt(t *Token, msg string) {
+	panic(&Diagnostic{Source: "", Pos: t.Pos, End: t.End, Severity: diagError, Msg: msg})
+}
+
+// peek returns the next token which is not a comment.
+func (f *formatter) peek() *Token {
+	for i := f.i; ; i++ {
+		if f.toks[i].T != tkComment {
+			return f.toks[i]
+		}
+	}
+}
+
+// next returns the next token, the comments before are kept in pending.
+func (f *formatter) next() *Token {
+	for f.toks[f.i].T == tkComment {
+		f.pending = append(f.pending, f.toks[f.i].S.S)
+		f.i++
+	}
+	t := f.toks[f.i]
+	if t.T != tkEos {
+		f.i++
+	}
+	f.lastLine = t.End.Line
+	return t
+}
+
+func (f *formatter) expect(tk TK) *Token {
+	t := f.next()
+	if t.T != tk {
+		f.errAt(t, "expect "+TokenMap[tk])
+	}
+	return t
+}
+
+// leading returns the comments before the next token as rows, with the blank lines.
+func (f *formatter) leading() []fmtRow {
+	var rows []fmtRow
+	for {
+		t := f.toks[f.i]
+		if f.lastLine > 0 && t.Pos.Line > f.lastLine+1 {
+			rows = append(rows, fmtRow{blank: true})
+		}
+		if t.T != tkComment {
+			return rows
+		}
+		rows = append(rows, fmtRow{comment: t.S.S})
+		f.i++
+		f.lastLine = t.End.Line
+	}
+}
+
+// trailing returns the comments following the last token on the same line, with the pending ones.
+func (f *formatter) trailing() string {
+	comments := f.pending
+	f.pending = nil
+	for f.toks[f.i].T == tkComment && f.toks[f.i].Pos.Line == f.lastLine {
+		comments = append(comments, f.toks[f.i].S.S)
+		f.lastLine = f.toks[f.i].End.Line
+		f.i++
+	}
+	return strings.Join(comments, " ")
+}
+
+func (f *formatter) formatFile(source string) {
+	var header []fmtRow
+	type include struct {
+		rows     []fmtRow
+		path     string
+		trailing string
+	}
+	var includes []include
+	first := true
+	for {
+		rows := f.leading()
+		t := f.peek()
+		if t.T == tkInclude {
+			// comments separated by a blank line stay in place, the others move with the include
+			attached := rows
+			for i := len(rows) - 1; i >= 0; i-- {
+				if rows[i].blank {
+					header = append(header, rows[:i+1]...)
+					attached = rows[i+1:]
+					break
+				}
+			}
+			f.next()
+			path := f.expect(tkString).S.S
+			includes = append(includes, include{rows: attached, path: path, trailing: f.trailing()})
+			continue
+		}
+
+		if first {
+			f.writeRows(trimBlank(header), 0)
+			if len(header) > 0 && len(includes) > 0 {
+				f.out.WriteString("\n")
+			}
+			sort.SliceStable(includes, func(i, j int) bool { return includes[i].path < includes[j].path })
+			for i, inc := range includes {
+				if i > 0 && inc.path == includes[i-1].path {
+					continue
+				}
+				f.writeRows(trimBlank(inc.rows), 0)
+				f.writeRows([]fmtRow{{cells: []string{`#include "` + inc.path + `"`}, trailing: inc.trailing}}, 0)
+			}
+			if len(includes) > 0 && (len(rows) > 0 || t.T != tkEos) {
+				f.out.WriteString("\n")
+			}
+			rows = trimLeadingBlank(rows)
+			first = false
+		}
+
+		switch t.T {
+		case tkEos:
+			f.writeRows(trimTrailingBlank(rows), 0)
+			return
+		case tkModule:
+			f.writeRows(rows, 0)
+			f.formatModule()
+		default:
+			f.errAt(t, "Expect include or module.")
+		}
+	}
+}
+
+// trimLeadingBlank removes the blank rows at the beginning.
+func trimLeadingBlank(rows []fmtRow) []fmtRow {
+	for len(rows) > 0 && rows[0].blank {
+		rows = rows[1:]
+	}
+	return rows
+}
+
+// trimTrailingBlank removes the blank rows at the end.
+func trimTrailingBlank(rows []fmtRow) []fmtRow {
+	for len(rows) > 0 && rows[len(rows)-1].blank {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
+
+// trimBlank removes the blank rows at the beginning and the end.
+func trimBlank(rows []fmtRow) []fmtRow {
+	return trimTrailingBlank(trimLeadingBlank(rows))
+}
+
+func (f *formatter) formatModule() {
+	f.next()
+	name := f.expect(tkName).S.S
+	f.writeRows([]fmtRow{{cells: []string{"module " + name}, trailing: f.trailing()}}, 0)
+	f.expect(tkBracel)
+	f.out.WriteString("{\n")
+	f.trailingLine()
+
+	firstItem := true
+	for {
+		rows := f.leading()
+		if firstItem {
+			rows = trimLeadingBlank(rows)
+		}
+		t := f.peek()
+		if t.T == tkBracer {
+			f.writeRows(trimTrailingBlank(rows), 1)
+			f.next()
+			f.expect(tkSemi)
+			f.writeRows([]fmtRow{{cells: []string{"};"}, trailing: f.trailing()}}, 0)
+			return
+		}
+		f.writeRows(rows, 1)
+		firstItem = false
+
+		switch t.T {
+		case tkStruct:
+			f.formatStruct()
+		case tkEnum:
+			f.formatEnum()
+		case tkInterface:
+			f.formatInterface()
+		case tkConst:
+			f.formatConst()
+		case tkKey:
+			f.formatKey()
+		default:
+			f.errAt(t, "not except "+TokenMap[t.T])
+		}
+	}
+}
+
+// trailingLine writes the comment after { as a line in the block.
+func (f *formatter) trailingLine() {
+	if c := f.trailing(); c != "" {
+		f.writeRows([]fmtRow{{comment: c}}, 1)
+	}
+}
+
+// openBlock writes the header of a definition and the {.
+func (f *formatter) openBlock(header string, indent int) {
+	f.writeRows([]fmtRow{{cells: []string{header}, trailing: f.trailing()}}, indent)
+	f.expect(tkBracel)
+	f.writeRows([]fmtRow{{cells: []string{"{"}}}, indent)
+	f.trailingLine()
+}
+
+// closeBlock writes the rows of the block and the };.
+func (f *formatter) closeBlock(rows []fmtRow, indent int) {
+	f.writeRows(trimBlank(rows), indent+1)
+	f.expect(tkBracer)
+	f.expect(tkSemi)
+	f.writeRows([]fmtRow{{cells: []string{"};"}, trailing: f.trailing()}}, indent)
+}
+
+func (f *formatter) formatType() string {
+	t := f.next()
+	switch t.T {
+	case tkName:
+		return t.S.S
+	case tkTInt, tkTBool, tkTShort, tkTLong, tkTByte, tkTFloat, tkTDouble, tkTString:
+		return TokenMap[t.T]
+	case tkTVector:
+		f.expect(tkShl)
+		k := f.formatType()
+		f.expect(tkShr)
+		return "vector<" + k + ">"
+	case tkTMap:
+		f.expect(tkShl)
+		k := f.formatType()
+		f.expect(tkComma)
+		v := f.formatType()
+		f.expect(tkShr)
+		return "map<" + k + ", " + v + ">"
+	case tkUnsigned:
+		return "unsigned " + f.formatType()
+	}
+	f.errAt(t, "expert type")
+	return ""
+}
+
+// formatValue returns the literal as written.
+func (f *formatter) formatValue() string {
+	t := f.next()
+	switch t.T {
+	case tkInteger, tkFloat, tkName:
+		return t.S.S
+	case tkString:
+		return `"` + t.S.S + `"`
+	case tkTrue, tkFalse:
+		return TokenMap[t.T]
+	}
+	f.errAt(t, "default value format error")
+	return ""
+}
+
+func (f *formatter) formatStruct() {
+	f.next()
+	name := f.expect(tkName).S.S
+	f.openBlock("struct "+name, 1)
+
+	var rows []fmtRow
+	for {
+		rows = append(rows, f.leading()...)
+		if f.peek().T == tkBracer {
+			break
+		}
+		tag := f.expect(tkInteger).S.S
+		require := f.next()
+		if require.T != tkRequire && require.T != tkOptional {
+			f.errAt(require, "expect require or optional")
+		}
+		ty := f.formatType()
+		key := f.expect(tkName).S.S
+		switch t := f.next(); t.T {
+		case tkSquarel:
+			key += "[" + f.expect(tkInteger).S.S + "]"
+			f.expect(tkSquarer)
+			f.expect(tkSemi)
+		case tkEq:
+			key += " = " + f.formatValue()
+			f.expect(tkSemi)
+		case tkSemi:
+		default:
+			f.errAt(t, "expect ; or =")
+		}
+		rows = append(rows, fmtRow{cells: []string{tag, TokenMap[require.T], ty, key + ";"}, trailing: f.trailing()})
+	}
+	f.closeBlock(rows, 1)
+}
+
+func (f *formatter) formatEnum() {
+	f.next()
+	name := f.expect(tkName).S.S
+	f.openBlock("enum "+name, 1)
+
+	var rows []fmtRow
+	last := -1
+	for {
+		rows = append(rows, f.leading()...)
+		if f.peek().T == tkBracer {
+			break
+		}
+		member := f.expect(tkName).S.S
+		if f.peek().T == tkEq {
+			f.next()
+			member += " = " + f.formatValue()
+		}
+		// the comments before the } of the last member without comma are left for the next rows
+		if f.peek().T != tkBracer {
+			if t := f.next(); t.T != tkComma {
+				f.errAt(t, "expect , or }")
+			}
+		}
+		rows = append(rows, fmtRow{cells: []string{member + ","}, trailing: f.trailing()})
+		last = len(rows) - 1
+	}
+	if last >= 0 {
+		rows[last].cells[0] = strings.TrimSuffix(rows[last].cells[0], ",")
+	}
+	f.closeBlock(rows, 1)
+}
+
+func (f *formatter) formatInterface() {
+	f.next()
+	name := f.expect(tkName).S.S
+	f.openBlock("interface "+name, 1)
+
+	var rows []fmtRow
+	for {
+		rows = append(rows, f.leading()...)
+		if f.peek().T == tkBracer {
+			break
+		}
+		ret := "void"
+		if f.peek().T == tkVoid {
+			f.next()
+		} else {
+			ret = f.formatType()
+		}
+		fun := f.expect(tkName).S.S
+		f.expect(tkPtl)
+		var args []string
+		if f.peek().T == tkPtr {
+			f.next()
+		} else {
+			for {
+				arg := ""
+				if f.peek().T == tkOut {
+					f.next()
+					arg = "out "
+				}
+				arg += f.formatType()
+				if f.peek().T == tkName {
+					arg += " " + f.next().S.S
+				}
+				args = append(args, arg)
+				t := f.next()
+				if t.T == tkPtr {
+					break
+				}
+				if t.T != tkComma {
+					f.errAt(t, "expect , or )")
+				}
+			}
+		}
+		f.expect(tkSemi)
+		sig := ret + " " + fun + "(" + strings.Join(args, ", ") + ");"
+		rows = append(rows, fmtRow{cells: []string{sig}, trailing: f.trailing()})
+	}
+	f.closeBlock(rows, 1)
+}
+
+func (f *formatter) formatConst() {
+	f.next()
+	ty := f.formatType()
+	name := f.expect(tkName).S.S
+	f.expect(tkEq)
+	value := f.formatValue()
+	f.expect(tkSemi)
+	f.writeRows([]fmtRow{{cells: []string{"const " + ty + " " + name + " = " + value + ";"}, trailing: f.trailing()}}, 1)
+}
+
+func (f *formatter) formatKey() {
+	f.next()
+	f.expect(tkSquarel)
+	names := []string{f.expect(tkName).S.S}
+	for {
+		f.expect(tkComma)
+		names = append(names, f.expect(tkName).S.S)
+		if f.peek().T == tkSquarer {
+			break
+		}
+	}
+	f.expect(tkSquarer)
+	f.expect(tkSemi)
+	f.writeRows([]fmtRow{{cells: []string{"key[" + strings.Join(names, ", ") + "];"}, trailing: f.trailing()}}, 1)
+}
+
+// writeRows writes the rows with the cells aligned, the trailing comments are aligned
+// in every section separated by blank lines or comments.
+func (f *formatter) writeRows(rows []fmtRow, indent int) {
+	prefix := strings.Repeat(fmtIndent, indent)
+	var widths []int
+	for _, row := range rows {
+		for i := 0; i < len(row.cells)-1; i++ {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if len(row.cells[i]) > widths[i] {
+				widths[i] = len(row.cells[i])
+			}
+		}
+	}
+	code := make([]string, len(rows))
+	for i, row := range rows {
+		var b strings.Builder
+		for j, cell := range row.cells {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(cell)
+			if j < len(row.cells)-1 {
+				b.WriteString(strings.Repeat(" ", widths[j]-len(cell)))
+			}
+		}
+		code[i] = b.String()
+	}
+
+	blank := false
+	for begin := 0; begin < len(rows); {
+		end := begin
+		width := 0
+		for end < len(rows) && !rows[end].blank && rows[end].comment == "" {
+			if len(code[end]) > width {
+				width = len(code[end])
+			}
+			end++
+		}
+		if end == begin {
+			// a blank line or a comment
+			if rows[begin].blank {
+				if !blank {
+					f.out.WriteString("\n")
+				}
+				blank = true
+			} else {
+				f.writeComment(rows[begin].comment, prefix)
+				blank = false
+			}
+			begin++
+			continue
+		}
+		for i := begin; i < end; i++ {
+			line := prefix + code[i]
+			if rows[i].trailing != "" {
+				line += strings.Repeat(" ", width-len(code[i])) + " " + rows[i].trailing
+			}
+			f.out.WriteString(line + "\n")
+		}
+		blank = false
+		begin = end
+	}
+}
+
+// writeComment writes the comment on its own lines, the lines of a block comment
+// starting with * are indented with the first line.
+func (f *formatter) writeComment(comment string, prefix string) {
+	lines := strings.Split(comment, "\n")
+	f.out.WriteString(prefix + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "*") {
+			line = prefix + " " + trimmed
+		}
+		f.out.WriteString(strings.TrimRight(line, " \t") + "\n")
+	}
+}
+
+// === tarsfmt ===
+
+// fmtMain is the command line of tarsfmt, like gofmt. It returns the exit code.
+func fmtMain(args []string) int {
+	fs := flag.NewFlagSet("tarsfmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write result to the source file instead of stdout")
+	diff := fs.Bool("d", false, "display diffs instead of rewriting files")
+	list := fs.Bool("l", false, "list files whose formatting differs from tarsfmt's")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: tarsfmt [flags] [path ...]\n")
+		fmt.Fprintf(os.Stderr, "       tars2go -fmt [flags] [path ...]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "tarsfmt: cannot use -w with standard input")
+			return 2
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		return fmtFile("<standard input>", src, false, *diff, *list)
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// only the .tars files in the directories are formatted
+			if info.IsDir() || (file != path && !strings.HasSuffix(file, ".tars")) {
+				return nil
+			}
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if c := fmtFile(file, src, *write, *diff, *list); c > code {
+				code = c
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 2
+		}
+	}
+	return code
+}
+
+func fmtFile(path string, src []byte, write bool, diff bool, list bool) int {
+	res, err := formatTars(path, src)
+	if err != nil {
+		d := err.(*Diagnostic)
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", path, d.Pos.Line, d.Pos.Col, d.Msg)
+		return 2
+	}
+	changed := !bytes.Equal(src, res)
+	if list && changed {
+		fmt.Println(path)
+	}
+	if write && changed {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if err = ioutil.WriteFile(path, res, info.Mode().Perm()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if diff && changed {
+		d, err := diffBytes(path, src, res)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "computing diff:", err)
+			return 2
+		}
+		os.Stdout.Write(d)
+	}
+	if !list && !write && !diff {
+		os.Stdout.Write(res)
+	}
+	return 0
+}
+
+// diffBytes runs diff -u on the original and the formatted content, like gofmt.
+func diffBytes(path string, b1 []byte, b2 []byte) ([]byte, error) {
+	f1, err := writeTempFile("tarsfmt", b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+	f2, err := writeTempFile("tarsfmt", b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	data, err := exec.Command("diff", "-u", f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with 1 if the files differ
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// replace the names of the temporary files in the header
+	lines := bytes.SplitN(data, []byte("\n"), 3)
+	if len(lines) == 3 {
+		lines[0] = []byte("--- " + path + ".orig")
+		lines[1] = []byte("+++ " + path)
+		data = bytes.Join(lines, []byte("\n"))
+	}
+	return data, nil
+}
+
+func writeTempFile(prefix string, data []byte) (string, error) {
+	file, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if err1 := file.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// isFmtCommand reports whether to run as tarsfmt, by the name of the binary or -fmt as the first argument.
+func isFmtCommand(args []string) bool {
+	name := strings.TrimSuffix(filepath.Base(args[0]), ".exe")
+	return name == "tarsfmt" || (len(args) > 1 && (args[1] == "-fmt" || args[1] == "--fmt"))
+}
+
+// fmtArgs returns the arguments of tarsfmt.
+func fmtArgs(args []string) []string {
+	if len(args) > 1 && (args[1] == "-fmt" || args[1] == "--fmt") {
+		return args[2:]
+	}
+	return args[1:]
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// tarsComments returns the comments of the tars file, sorted.
+func tarsComments(t *testing.T, src []byte) []string {
+	t.Helper()
+	ls := NewLexState("", src)
+	ls.keepComments = true
+	var comments []string
+	for {
+		tok := ls.NextToken()
+		if tok.T == tkEos {
+			break
+		}
+		if tok.T == tkComment {
+			comments = append(comments, tok.S.S)
+		}
+	}
+	sort.Strings(comments)
+	return comments
+}
+
+// TestFormat tests the files of testdata/fmt are formatted as their golden files, keeping all the comments,
+// and the golden files are left as they are.
+func TestFormat(t *testing.T) {
+	files, err := filepath.Glob("testdata/fmt/*.tars")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no tars file in testdata/fmt: %v", err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := formatTars(file, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGoldenBytes(t, file, got, file+".golden")
+
+			if want := tarsComments(t, src); !reflect.DeepEqual(tarsComments(t, got), want) {
+				t.Errorf("comments are not kept: got %q, want %q", tarsComments(t, got), want)
+			}
+			again, err := formatTars(file, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(got) {
+				t.Errorf("formatted file is changed again:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "module Test\n{\n    struct A\n    {\n        0 require int a\n    };\n};\n", err: "6:5: expect ; or ="},
+		{src: "module Test\n{\n    enum E\n    {\n        A B\n    };\n};\n", err: "5:11: expect , or }"},
+		{src: "#include \"a.tars\"\nstruct A\n", err: "2:1: Expect include or module."},
+		{src: "module Test\n{\n    void f();\n};\n", err: "3:5: not except void"},
+	}
+	for _, tt := range tests {
+		_, err := formatTars("test.tars", []byte(tt.src))
+		d, ok := err.(*Diagnostic)
+		if !ok {
+			t.Errorf("%q: got %v, want %s", tt.src, err, tt.err)
+			continue
+		}
+		if got := strings.TrimPrefix(d.Error(), ":"); got != tt.err {
+			t.Errorf("%q: got %s, want %s", tt.src, got, tt.err)
+		}
+	}
+}
+
+// TestFmtMain tests the output and the exit codes of the command line, with -l, -d and -w.
+func TestFmtMain(t *testing.T) {
+	formatted, err := ioutil.ReadFile("testdata/fmt/Fmt.tars.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const unformatted = "module Test {\nstruct A{0 require int a;};\n};\n"
+	const want = "module Test\n{\n    struct A\n    {\n        0 require int a;\n    };\n};\n"
+	dir := writeFiles(t, map[string]string{
+		"Bad.tars":  unformatted,
+		"Good.tars": string(formatted),
+		"Err.tars":  "module Test\n{\n",
+		"Other.txt": unformatted,
+	})
+	defer os.RemoveAll(dir)
+	bad, good, bogus := filepath.Join(dir, "Bad.tars"), filepath.Join(dir, "Good.tars"), filepath.Join(dir, "Err.tars")
+
+	run := func(t *testing.T, stdin string, args ...string) (int, string, string) {
+		t.Helper()
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(stdin))
+		w.Close()
+		defer func(in *os.File) { os.Stdin = in }(os.Stdin)
+		os.Stdin = r
+		var code int
+		var stdout string
+		stderr := capture(t, &os.Stderr, func() {
+			stdout = capture(t, &os.Stdout, func() { code = fmtMain(args) })
+		})
+		return code, stdout, stderr
+	}
+
+	t.Run("stdout", func(t *testing.T) {
+		if code, out, _ := run(t, "", bad); code != 0 || out != want {
+			t.Fatalf("got %d %q, want %q", code, out, want)
+		}
+	})
+	t.Run("stdin", func(t *testing.T) {
+		if code, out, _ := run(t, unformatted); code != 0 || out != want {
+			t.Fatalf("got %d %q, want %q", code, out, want)
+		}
+		if code, _, errOut := run(t, unformatted, "-w"); code != 2 || errOut != "tarsfmt: cannot use -w with standard input\n" {
+			t.Fatalf("-w: got %d %q", code, errOut)
+		}
+	})
+	t.Run("list", func(t *testing.T) {
+		// only the .tars files of the directory are checked
+		code, out, errOut := run(t, "", "-l", dir)
+		if code != 2 || out != bad+"\n" || !strings.HasPrefix(errOut, bogus+":3:1: ") {
+			t.Fatalf("got %d %q %q", code, out, errOut)
+		}
+		if code, out, _ := run(t, "", "-l", good); code != 0 || out != "" {
+			t.Fatalf("formatted file: got %d %q", code, out)
+		}
+	})
+	t.Run("diff", func(t *testing.T) {
+		if _, err := exec.LookPath("diff"); err != nil {
+			t.Skip("diff is not found")
+		}
+		code, out, _ := run(t, "", "-d", bad, good)
+		lines := strings.Split(out, "\n")
+		if code != 0 || len(lines) < 3 || lines[0] != "--- "+bad+".orig" || lines[1] != "+++ "+bad ||
+			!strings.HasPrefix(lines[2], "@@ ") || !strings.Contains(out, "\n-module Test {\n") ||
+			!strings.Contains(out, "\n+        0 require int a;\n") || strings.Contains(out, good) {
+			t.Fatalf("got %d\n%s", code, out)
+		}
+	})
+	t.Run("write", func(t *testing.T) {
+		if code, out, _ := run(t, "", "-w", bad, good); code != 0 || out != "" {
+			t.Fatalf("got %d %q", code, out)
+		}
+		for path, want := range map[string]string{bad: want, good: string(formatted)} {
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("%s: got %q, want %q", path, got, want)
+			}
+		}
+		if code, out, _ := run(t, "", "-l", bad); code != 0 || out != "" {
+			t.Fatalf("written file is not formatted: got %d %q", code, out)
+		}
+	})
+}
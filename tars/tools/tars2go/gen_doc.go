@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var gDoc = flag.String("doc", "", "Generate documents instead of go code, comma separated formats: markdown, html, openapi")
+
+var gDocFileMap = make(map[string]bool)
+
+// GenDoc generates the reference documents of a tars file from the comments in it,
+// and the OpenAPI 3 description for calling the interfaces with json through a gateway.
+type GenDoc struct {
+	path    string
+	prefix  string
+	formats []string
+	p       *Parse
+
+	// proto file name(not include .tars)
+	ProtoName string
+}
+
+// NewGenDoc build up a new document generator.
+func NewGenDoc(path string, outdir string, formats []string) *GenDoc {
+	if outdir != "" && !strings.HasSuffix(outdir, "/") {
+		outdir += "/"
+	}
+	return &GenDoc{path: path, prefix: outdir, formats: formats, ProtoName: path2ProtoName(path)}
+}
+
+// Gen parses the file and generates the documents.
+func (gen *GenDoc) Gen() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}()
+
+	gen.p = ParseFile(gen.path, make([]string, 0))
+	for _, format := range gen.formats {
+		switch format {
+		case "markdown", "md":
+			gen.genAll(&mdWriter{})
+		case "html":
+			gen.genAll(&htmlWriter{})
+		case "openapi":
+			gen.genOpenAPI()
+		default:
+			gen.genErr("unknown document format " + format)
+		}
+	}
+}
+
+func (gen *GenDoc) genErr(err string) {
+	panic(err)
+}
+
+func (gen *GenDoc) save(name string, data []byte) {
+	if err := os.MkdirAll(gen.prefix+".", 0766); err != nil {
+		gen.genErr(err.Error())
+	}
+	if err := ioutil.WriteFile(gen.prefix+name, data, 0666); err != nil {
+		gen.genErr(err.Error())
+	}
+}
+
+// === reference document area ===
+
+// docWriter renders the reference document in a format.
+type docWriter interface {
+	// new returns an empty writer of the same format
+	new() docWriter
+	ext() string
+	begin(title string)
+	end()
+	heading(level int, id string, text string)
+	para(content string)
+	code(text string)
+	table(header []string, rows [][]string)
+	// escape and link return inline content, used to build paragraphs and table cells
+	escape(text string) string
+	link(text string, target string) string
+	bytes() []byte
+}
+
+func (gen *GenDoc) genAll(w docWriter) {
+	key := gen.path + "." + w.ext()
+	if gDocFileMap[key] {
+		return
+	}
+	gDocFileMap[key] = true
+
+	for _, inc := range gen.p.IncParse {
+		gen2 := &GenDoc{
+			path:      inc.Source,
+			prefix:    gen.prefix,
+			ProtoName: path2ProtoName(inc.Source),
+			p:         inc,
+		}
+		gen2.genAll(w.new())
+	}
+
+	p := gen.p
+	w.begin(p.Module)
+	w.heading(1, "", p.Module)
+	if p.ModuleComment != "" {
+		w.para(w.escape(p.ModuleComment))
+	}
+	src := w.escape("Generated from " + gen.ProtoName + ".tars.")
+	if len(p.IncParse) > 0 {
+		var incs []string
+		for _, inc := range p.IncParse {
+			name := path2ProtoName(inc.Source)
+			incs = append(incs, w.link(name+".tars", name+"."+w.ext()))
+		}
+		src += " " + w.escape("Includes ") + strings.Join(incs, ", ") + "."
+	}
+	w.para(src)
+
+	if len(p.Const) > 0 {
+		w.heading(2, "", "Constants")
+		var rows [][]string
+		for _, v := range p.Const {
+			rows = append(rows, []string{w.escape(v.Name), gen.typeRef(w, v.Type), w.escape(v.Value), w.escape(v.Comment)})
+		}
+		w.table([]string{"Name", "Type", "Value", "Description"}, rows)
+	}
+
+	if len(p.Enum) > 0 {
+		w.heading(2, "", "Enums")
+		for _, en := range p.Enum {
+			w.heading(3, en.Name, en.Name)
+			if en.Comment != "" {
+				w.para(w.escape(en.Comment))
+			}
+			values := compatEnumValues(&en)
+			var rows [][]string
+			for _, mb := range en.Mb {
+				rows = append(rows, []string{w.escape(mb.Key), strconv.Itoa(int(values[mb.Key])), w.escape(mb.Comment)})
+			}
+			w.table([]string{"Name", "Value", "Description"}, rows)
+		}
+	}
+
+	if len(p.Struct) > 0 {
+		w.heading(2, "", "Structs")
+		for _, st := range p.Struct {
+			w.heading(3, st.Name, st.Name)
+			if st.Comment != "" {
+				w.para(w.escape(st.Comment))
+			}
+			var rows [][]string
+			for _, mb := range st.Mb {
+				require := "optional"
+				if mb.Require {
+					require = "require"
+				}
+				rows = append(rows, []string{strconv.Itoa(int(mb.Tag)), w.escape(mb.Key), gen.typeRef(w, mb.Type),
+					require, w.escape(mb.Default), w.escape(mb.Comment)})
+			}
+			w.table([]string{"Tag", "Name", "Type", "Require", "Default", "Description"}, rows)
+		}
+	}
+
+	if len(p.Interface) > 0 {
+		w.heading(2, "", "Interfaces")
+		for _, itf := range p.Interface {
+			w.heading(3, itf.Name, itf.Name)
+			if itf.Comment != "" {
+				w.para(w.escape(itf.Comment))
+			}
+			for _, fun := range itf.Fun {
+				w.heading(4, itf.Name+"."+fun.Name, fun.Name)
+				w.code(docSignature(&fun))
+				if fun.Comment != "" {
+					w.para(w.escape(fun.Comment))
+				}
+				var rows [][]string
+				for _, arg := range fun.Args {
+					dir := "in"
+					if arg.IsOut {
+						dir = "out"
+					}
+					rows = append(rows, []string{w.escape(arg.Name), gen.typeRef(w, arg.Type), dir})
+				}
+				if fun.HasRet {
+					rows = append(rows, []string{w.escape("(return)"), gen.typeRef(w, fun.RetType), "out"})
+				}
+				if len(rows) > 0 {
+					w.table([]string{"Argument", "Type", "Direction"}, rows)
+				}
+			}
+		}
+	}
+	w.end()
+
+	gen.save(gen.ProtoName+"."+w.ext(), w.bytes())
+}
+
+// docTypeName returns the type as written in the tars file.
+func docTypeName(ty *VarType) string {
+	if ty == nil {
+		return "void"
+	}
+	switch ty.Type {
+	case tkTVector:
+		return "vector<" + docTypeName(ty.TypeK) + ">"
+	case tkTMap:
+		return "map<" + docTypeName(ty.TypeK) + ", " + docTypeName(ty.TypeV) + ">"
+	case tkTArray:
+		return docTypeName(ty.TypeK) + "[" + strconv.FormatInt(ty.TypeL, 10) + "]"
+	case tkName:
+		return ty.TypeSt
+	}
+	name := TokenMap[ty.Type]
+	if ty.Unsigned {
+		name = "unsigned " + name
+	}
+	return name
+}
+
+// docSignature returns the signature of the function as written in the tars file, with the original
+// names if the function has been renamed for the generated code.
+func docSignature(fun *FunInfo) string {
+	ret := "void"
+	if fun.HasRet {
+		ret = docTypeName(fun.RetType)
+	}
+	var args []string
+	for _, arg := range fun.Args {
+		name := arg.Name
+		if arg.OriginName != "" {
+			name = arg.OriginName
+		}
+		s := docTypeName(arg.Type) + " " + name
+		if arg.IsOut {
+			s = "out " + s
+		}
+		args = append(args, s)
+	}
+	name := fun.Name
+	if fun.OriginName != "" {
+		name = fun.OriginName
+	}
+	return ret + " " + name + "(" + strings.Join(args, ", ") + ");"
+}
+
+// findType returns the file defining the custom type, searching the includes.
+func findType(p *Parse, module string, name string) (*Parse, bool) {
+	if p.Module == module {
+		for _, st := range p.Struct {
+			if st.Name == name {
+				return p, true
+			}
+		}
+		for _, en := range p.Enum {
+			if en.Name == name {
+				return p, true
+			}
+		}
+	}
+	for _, inc := range p.IncParse {
+		if found, ok := findType(inc, module, name); ok {
+			return found, true
+		}
+	}
+	return nil, false
+}
+
+// splitDocType returns the module and the name of a custom type used in p.
+func splitDocType(p *Parse, ty *VarType) (string, string) {
+	if pos := strings.Index(ty.TypeSt, "::"); pos >= 0 {
+		return ty.TypeSt[:pos], ty.TypeSt[pos+2:]
+	}
+	return p.Module, ty.TypeSt
+}
+
+// typeRef returns the type with links to the custom types.
+func (gen *GenDoc) typeRef(w docWriter, ty *VarType) string {
+	switch ty.Type {
+	case tkTVector:
+		return w.escape("vector<") + gen.typeRef(w, ty.TypeK) + w.escape(">")
+	case tkTMap:
+		return w.escape("map<") + gen.typeRef(w, ty.TypeK) + w.escape(", ") + gen.typeRef(w, ty.TypeV) + w.escape(">")
+	case tkTArray:
+		return gen.typeRef(w, ty.TypeK) + w.escape("["+strconv.FormatInt(ty.TypeL, 10)+"]")
+	case tkName:
+		module, name := splitDocType(gen.p, ty)
+		found, ok := findType(gen.p, module, name)
+		if !ok {
+			return w.escape(ty.TypeSt)
+		}
+		target := "#" + name
+		if found != gen.p {
+			target = path2ProtoName(found.Source) + "." + w.ext() + target
+		}
+		return w.link(ty.TypeSt, target)
+	}
+	return w.escape(docTypeName(ty))
+}
+
+type mdWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *mdWriter) new() docWriter { return &mdWriter{} }
+func (w *mdWriter) ext() string    { return "md" }
+func (w *mdWriter) bytes() []byte  { return w.buf.Bytes() }
+func (w *mdWriter) begin(string)   {}
+func (w *mdWriter) end()           {}
+
+func (w *mdWriter) heading(level int, id string, text string) {
+	if id != "" {
+		w.buf.WriteString(`<a id="` + id + `"></a>` + "\n\n")
+	}
+	w.buf.WriteString(strings.Repeat("#", level) + " " + text + "\n\n")
+}
+
+func (w *mdWriter) para(content string) {
+	w.buf.WriteString(content + "\n\n")
+}
+
+func (w *mdWriter) code(text string) {
+	w.buf.WriteString("```\n" + text + "\n```\n\n")
+}
+
+func (w *mdWriter) table(header []string, rows [][]string) {
+	w.buf.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	w.buf.WriteString(strings.Repeat("| --- ", len(header)) + "|\n")
+	for _, row := range rows {
+		w.buf.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+	w.buf.WriteString("\n")
+}
+
+func (w *mdWriter) escape(text string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "|", `\|`, "\n", "<br>")
+	return r.Replace(text)
+}
+
+func (w *mdWriter) link(text string, target string) string {
+	return "[" + w.escape(text) + "](" + target + ")"
+}
+
+type htmlWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *htmlWriter) new() docWriter { return &htmlWriter{} }
+func (w *htmlWriter) ext() string    { return "html" }
+func (w *htmlWriter) bytes() []byte  { return w.buf.Bytes() }
+
+func (w *htmlWriter) begin(title string) {
+	w.buf.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>` + html.EscapeString(title) + `</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+pre { background: #f5f5f5; padding: 8px; }
+</style>
+</head>
+<body>
+`)
+}
+
+func (w *htmlWriter) end() {
+	w.buf.WriteString("</body>\n</html>\n")
+}
+
+func (w *htmlWriter) heading(level int, id string, text string) {
+	h := "h" + strconv.Itoa(level)
+	if id != "" {
+		w.buf.WriteString("<" + h + ` id="` + html.EscapeString(id) + `">` + html.EscapeString(text) + "</" + h + ">\n")
+	} else {
+		w.buf.WriteString("<" + h + ">" + html.EscapeString(text) + "</" + h + ">\n")
+	}
+}
+
+func (w *htmlWriter) para(content string) {
+	w.buf.WriteString("<p>" + content + "</p>\n")
+}
+
+func (w *htmlWriter) code(text string) {
+	w.buf.WriteString("<pre>" + html.EscapeString(text) + "</pre>\n")
+}
+
+func (w *htmlWriter) table(header []string, rows [][]string) {
+	w.buf.WriteString("<table>\n<tr><th>" + strings.Join(header, "</th><th>") + "</th></tr>\n")
+	for _, row := range rows {
+		w.buf.WriteString("<tr><td>" + strings.Join(row, "</td><td>") + "</td></tr>\n")
+	}
+	w.buf.WriteString("</table>\n")
+}
+
+func (w *htmlWriter) escape(text string) string {
+	return strings.Replace(html.EscapeString(text), "\n", "<br>", -1)
+}
+
+func (w *htmlWriter) link(text string, target string) string {
+	return `<a href="` + html.EscapeString(target) + `">` + w.escape(text) + "</a>"
+}
+
+// === openapi area ===
+
+type openAPIDoc struct {
+	OpenAPI    string                 `json:"openapi"`
+	Info       map[string]string      `json:"info"`
+	Paths      map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+type jsonObject map[string]interface{}
+
+// genOpenAPI describes every function as POST /<Interface>/<function>, the request body is a
+// json object of the input arguments by name, and the response has the return value as _ret
+// and the output arguments by name, the same as tarscurl.
+func (gen *GenDoc) genOpenAPI() {
+	p := gen.p
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    map[string]string{"title": p.Module, "version": "1.0.0"},
+		Paths:   make(map[string]interface{}),
+	}
+	desc := "Generated from " + gen.ProtoName + ".tars."
+	if p.ModuleComment != "" {
+		desc = p.ModuleComment + "\n\n" + desc
+	}
+	doc.Info["description"] = desc
+	doc.Components.Schemas = make(map[string]interface{})
+	gen.genSchemas(p, doc.Components.Schemas)
+
+	for _, itf := range p.Interface {
+		for _, fun := range itf.Fun {
+			req := jsonObject{"type": "object"}
+			rsp := jsonObject{"type": "object"}
+			reqProps, rspProps := jsonObject{}, jsonObject{}
+			var required []string
+			if fun.HasRet {
+				rspProps["_ret"] = gen.schema(p, fun.RetType)
+			}
+			for _, arg := range fun.Args {
+				if arg.IsOut {
+					rspProps[arg.Name] = gen.schema(p, arg.Type)
+				} else {
+					reqProps[arg.Name] = gen.schema(p, arg.Type)
+					required = append(required, arg.Name)
+				}
+			}
+			req["properties"] = reqProps
+			if len(required) > 0 {
+				req["required"] = required
+			}
+			rsp["properties"] = rspProps
+
+			op := jsonObject{
+				"operationId": itf.Name + "_" + fun.Name,
+				"tags":        []string{itf.Name},
+				"requestBody": jsonObject{
+					"required": true,
+					"content":  jsonObject{"application/json": jsonObject{"schema": req}},
+				},
+				"responses": jsonObject{
+					"200": jsonObject{
+						"description": "OK",
+						"content":     jsonObject{"application/json": jsonObject{"schema": rsp}},
+					},
+				},
+			}
+			if fun.Comment != "" {
+				op["summary"] = strings.SplitN(fun.Comment, "\n", 2)[0]
+				op["description"] = fun.Comment
+			}
+			doc.Paths["/"+itf.Name+"/"+fun.Name] = jsonObject{"post": op}
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		gen.genErr(err.Error())
+	}
+	gen.save(gen.ProtoName+".openapi.json", append(data, '\n'))
+}
+
+// genSchemas adds the schemas of the structs and enums of p and its includes.
+func (gen *GenDoc) genSchemas(p *Parse, schemas map[string]interface{}) {
+	for _, inc := range p.IncParse {
+		gen.genSchemas(inc, schemas)
+	}
+	for _, en := range p.Enum {
+		values := compatEnumValues(&en)
+		var enum []int32
+		var names []string
+		desc := en.Comment
+		for _, mb := range en.Mb {
+			enum = append(enum, values[mb.Key])
+			names = append(names, mb.Key)
+			line := "- " + mb.Key + " = " + strconv.Itoa(int(values[mb.Key]))
+			if mb.Comment != "" {
+				line += ": " + strings.Replace(mb.Comment, "\n", " ", -1)
+			}
+			if desc != "" {
+				desc += "\n"
+			}
+			desc += line
+		}
+		schemas[p.Module+"."+en.Name] = jsonObject{
+			"type":            "integer",
+			"format":          "int32",
+			"enum":            enum,
+			"x-enum-varnames": names,
+			"description":     desc,
+		}
+	}
+	for _, st := range p.Struct {
+		props := jsonObject{}
+		var required []string
+		for _, mb := range st.Mb {
+			s := gen.schema(p, mb.Type)
+			if mb.Comment != "" || mb.Default != "" {
+				// siblings of $ref are ignored, so wrap it
+				if _, ok := s["$ref"]; ok {
+					s = jsonObject{"allOf": []interface{}{s}}
+				}
+			}
+			if mb.Comment != "" {
+				s["description"] = mb.Comment
+			}
+			var def interface{}
+			if mb.Default != "" && json.Unmarshal([]byte(mb.Default), &def) == nil {
+				s["default"] = def
+			}
+			props[mb.Key] = s
+			if mb.Require {
+				required = append(required, mb.Key)
+			}
+		}
+		s := jsonObject{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		if st.Comment != "" {
+			s["description"] = st.Comment
+		}
+		schemas[p.Module+"."+st.Name] = s
+	}
+}
+
+// schema returns the json schema of the type, the same as the json encoding of the generated go code.
+func (gen *GenDoc) schema(p *Parse, ty *VarType) jsonObject {
+	switch ty.Type {
+	case tkTBool:
+		return jsonObject{"type": "boolean"}
+	case tkTByte, tkTShort, tkTInt:
+		if ty.Unsigned {
+			return jsonObject{"type": "integer", "format": "int64", "minimum": 0}
+		}
+		return jsonObject{"type": "integer", "format": "int32"}
+	case tkTLong:
+		return jsonObject{"type": "integer", "format": "int64"}
+	case tkTFloat:
+		return jsonObject{"type": "number", "format": "float"}
+	case tkTDouble:
+		return jsonObject{"type": "number", "format": "double"}
+	case tkTString:
+		return jsonObject{"type": "string"}
+	case tkTVector:
+		// []uint8 is encoded as base64 string
+		if ty.TypeK.Type == tkTByte && ty.TypeK.Unsigned {
+			return jsonObject{"type": "string", "format": "byte"}
+		}
+		return jsonObject{"type": "array", "items": gen.schema(p, ty.TypeK)}
+	case tkTArray:
+		return jsonObject{"type": "array", "items": gen.schema(p, ty.TypeK), "maxItems": ty.TypeL}
+	case tkTMap:
+		return jsonObject{"type": "object", "additionalProperties": gen.schema(p, ty.TypeV)}
+	case tkName:
+		module, name := splitDocType(p, ty)
+		return jsonObject{"$ref": "#/components/schemas/" + module + "." + name}
+	}
+	gen.genErr("unknown type " + TokenMap[ty.Type])
+	return nil
+}
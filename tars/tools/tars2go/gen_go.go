@@ -17,6 +17,14 @@ var gAddServant = flag.Bool("add-servant", true, "Generate AddServant function")
 var gModuleCycle = flag.Bool("module-cycle", false, "support jce module cycle include(do not support jce file cycle include)")
 var gModuleUpper = flag.Bool("module-upper", false, "native module names are supported, otherwise the system will upper the first letter of the module name")
 var gJsonOmitEmpty = flag.Bool("json-omitempty", false, "Generate json emitempty support")
+var gJsonTag = flag.Bool("json-tag", true, "Generate json tags with the member names of the tars file")
+var gGenEqual = flag.Bool("gen-equal", false, "Generate Equal method for structs")
+var gGenDeepCopy = flag.Bool("gen-deepcopy", false, "Generate DeepCopy method for structs")
+var gGenString = flag.Bool("gen-string", false, "Generate String method for structs")
+var gGenEnumString = flag.Bool("gen-enum-string", false, "Generate String method and Parse function for enums")
+var gGenMock = flag.Bool("gen-mock", false, "Generate client interface, mock and in-process fake for interfaces")
+var gReflection = flag.Bool("reflection", false, "Generate TarsReflection method describing the interface for the reflection of the server")
+var gReflectionSource = flag.Bool("reflection-source", false, "Embed the tars file in TarsReflection method, implies -reflection")
 
 var gFileMap map[string]bool
 
@@ -135,6 +143,7 @@ func (en *EnumInfo) rename() {
 	en.OriginName = en.Name
 	en.Name = upperFirstLetter(en.Name)
 	for i := range en.Mb {
+		en.Mb[i].OriginKey = en.Mb[i].Key
 		en.Mb[i].Key = upperFirstLetter(en.Mb[i].Key)
 	}
 }
@@ -338,10 +347,7 @@ import (
 	"unsafe"
 
 `)
-	if *gAddServant {
-		gen.code.WriteString("\"" + gen.tarsPath + "\"\n")
-	}
-
+	gen.code.WriteString("\"" + gen.tarsPath + "\"\n")
 	gen.code.WriteString("\"" + gen.tarsPath + "/protocol/res/requestf\"\n")
 	gen.code.WriteString("m \"" + gen.tarsPath + "/model\"\n")
 	gen.code.WriteString("\"" + gen.tarsPath + "/protocol/codec\"\n")
@@ -466,13 +472,30 @@ func (gen *GenGo) genType(ty *VarType) string {
 	return ret
 }
 
+// genComment writes the comment from the tars file as go comment.
+func (gen *GenGo) genComment(comment string) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		gen.code.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+	}
+}
+
 func (gen *GenGo) genStructDefine(st *StructInfo) {
 	c := &gen.code
-	c.WriteString("// " + st.Name + " struct implement\n")
+	if st.Comment != "" {
+		gen.genComment(st.Comment)
+	} else {
+		c.WriteString("// " + st.Name + " struct implement\n")
+	}
 	c.WriteString("type " + st.Name + " struct {\n")
 
 	for _, v := range st.Mb {
-		if *gJsonOmitEmpty {
+		gen.genComment(v.Comment)
+		if !*gJsonTag {
+			c.WriteString("\t" + v.Key + " " + gen.genType(v.Type) + "\n")
+		} else if *gJsonOmitEmpty {
 			c.WriteString("\t" + v.Key + " " + gen.genType(v.Type) + " `json:\"" + v.OriginKey + ",omitempty\"`\n")
 		} else {
 			c.WriteString("\t" + v.Key + " " + gen.genType(v.Type) + " `json:\"" + v.OriginKey + "\"`\n")
@@ -700,7 +723,7 @@ func (gen *GenGo) genReadSimpleList(mb *StructMember, prefix string, hasRet bool
 	c.WriteString(`
 err, _ = _is.SkipTo(codec.BYTE, 0, true)
 ` + errStr + `
-err = _is.Read_int32(&length, 0, true)
+err = _is.ReadLength(&length, codec.SIMPLE_LIST)
 ` + errStr + `
 err = _is.Read_slice_` + unsign + `int8(&` + prefix + mb.Key + `, length, true)
 ` + errStr + `
@@ -729,7 +752,7 @@ err, have, ty = _is.SkipToNoCheck(` + tag + `,` + require + `)
 
 	c.WriteString(`
 if ty == codec.LIST {
-	err = _is.Read_int32(&length, 0, true)
+	err = _is.ReadLength(&length, codec.LIST)
   ` + errStr + `
   ` + prefix + mb.Key + ` = make(` + gen.genType(mb.Type) + `, length)
   ` + genForHead(vc) + `{
@@ -783,8 +806,12 @@ err, have, ty = _is.SkipToNoCheck(` + tag + `,` + require + `)
 
 	c.WriteString(`
 if ty == codec.LIST {
-	err = _is.Read_int32(&length, 0, true)
+	err = _is.ReadLength(&length, codec.LIST)
   ` + errStr + `
+  if int(length) > len(` + prefix + mb.Key + `) {
+    err = fmt.Errorf("array length %d exceeds %d", length, len(` + prefix + mb.Key + `))
+    ` + errStr + `
+  }
   ` + genForHead(vc) + `{
 `)
 
@@ -845,7 +872,7 @@ err, have = _is.SkipTo(codec.MAP, ` + tag + `, ` + require + `)
 		c.WriteString("if have {")
 	}
 	c.WriteString(`
-err = _is.Read_int32(&length, 0, true)
+err = _is.ReadLength(&length, codec.MAP)
 ` + errStr + `
 ` + prefix + mb.Key + ` = make(` + gen.genType(mb.Type) + `)
 ` + genForHead(vc) + `{
@@ -984,6 +1011,190 @@ func (gen *GenGo) genStruct(st *StructInfo) {
 
 	gen.genFunWriteTo(st)
 	gen.genFunWriteBlock(st)
+
+	if *gGenEqual {
+		gen.genFunEqual(st)
+	}
+	if *gGenDeepCopy {
+		gen.genFunDeepCopy(st)
+	}
+	if *gGenString {
+		gen.genFunString(st)
+	}
+}
+
+func (gen *GenGo) genEqualVar(ty *VarType, a string, b string) {
+	c := &gen.code
+	switch ty.Type {
+	case tkTVector, tkTArray:
+		vc := strconv.Itoa(gen.vc)
+		gen.vc++
+		if ty.Type == tkTVector {
+			c.WriteString("if len(" + a + ") != len(" + b + ") {\nreturn false\n}\n")
+		}
+		c.WriteString("for i" + vc + " := range " + a + " {\n")
+		gen.genEqualVar(ty.TypeK, a+"[i"+vc+"]", b+"[i"+vc+"]")
+		c.WriteString("}\n")
+	case tkTMap:
+		vc := strconv.Itoa(gen.vc)
+		gen.vc++
+		c.WriteString("if len(" + a + ") != len(" + b + ") {\nreturn false\n}\n")
+		c.WriteString("for k" + vc + ", va" + vc + " := range " + a + " {\n")
+		c.WriteString("vb" + vc + ", ok := " + b + "[k" + vc + "]\n")
+		c.WriteString("if !ok {\nreturn false\n}\n")
+		gen.genEqualVar(ty.TypeV, "va"+vc, "vb"+vc)
+		c.WriteString("}\n")
+	default:
+		if ty.Type == tkName && ty.CType == tkStruct {
+			c.WriteString("if !" + a + ".Equal(&" + b + ") {\nreturn false\n}\n")
+		} else {
+			c.WriteString("if " + a + " != " + b + " {\nreturn false\n}\n")
+		}
+	}
+}
+
+func (gen *GenGo) genFunEqual(st *StructInfo) {
+	c := &gen.code
+	c.WriteString(`
+// Equal reports whether st and o hold the same values.
+func (st *` + st.Name + `) Equal(o *` + st.Name + `) bool {
+	if st == o {
+		return true
+	}
+	if st == nil || o == nil {
+		return false
+	}
+`)
+	for _, v := range st.Mb {
+		gen.genEqualVar(v.Type, "st."+v.Key, "o."+v.Key)
+	}
+	c.WriteString("return true\n}\n")
+}
+
+// needDeepCopy reports whether assigning a value of the type shares memory.
+func needDeepCopy(ty *VarType) bool {
+	switch ty.Type {
+	case tkTVector, tkTMap:
+		return true
+	case tkTArray:
+		return needDeepCopy(ty.TypeK)
+	case tkName:
+		return ty.CType == tkStruct
+	}
+	return false
+}
+
+// genCopyVar copies src to dst, dst already holds a shallow copy of src for arrays.
+func (gen *GenGo) genCopyVar(ty *VarType, dst string, src string) {
+	c := &gen.code
+	switch ty.Type {
+	case tkTVector:
+		c.WriteString("if " + src + " != nil {\n")
+		c.WriteString(dst + " = make(" + gen.genType(ty) + ", len(" + src + "))\n")
+		if needDeepCopy(ty.TypeK) {
+			vc := strconv.Itoa(gen.vc)
+			gen.vc++
+			c.WriteString("for i" + vc + " := range " + src + " {\n")
+			gen.genCopyVar(ty.TypeK, dst+"[i"+vc+"]", src+"[i"+vc+"]")
+			c.WriteString("}\n")
+		} else {
+			c.WriteString("copy(" + dst + ", " + src + ")\n")
+		}
+		c.WriteString("}\n")
+	case tkTArray:
+		vc := strconv.Itoa(gen.vc)
+		gen.vc++
+		c.WriteString("for i" + vc + " := range " + src + " {\n")
+		gen.genCopyVar(ty.TypeK, dst+"[i"+vc+"]", src+"[i"+vc+"]")
+		c.WriteString("}\n")
+	case tkTMap:
+		vc := strconv.Itoa(gen.vc)
+		gen.vc++
+		c.WriteString("if " + src + " != nil {\n")
+		c.WriteString(dst + " = make(" + gen.genType(ty) + ", len(" + src + "))\n")
+		c.WriteString("for k" + vc + ", v" + vc + " := range " + src + " {\n")
+		if needDeepCopy(ty.TypeV) {
+			c.WriteString("var c" + vc + " " + gen.genType(ty.TypeV) + "\n")
+			if ty.TypeV.Type == tkTArray {
+				c.WriteString("c" + vc + " = v" + vc + "\n")
+			}
+			gen.genCopyVar(ty.TypeV, "c"+vc, "v"+vc)
+			c.WriteString(dst + "[k" + vc + "] = c" + vc + "\n")
+		} else {
+			c.WriteString(dst + "[k" + vc + "] = v" + vc + "\n")
+		}
+		c.WriteString("}\n}\n")
+	default:
+		if ty.Type == tkName && ty.CType == tkStruct {
+			c.WriteString(dst + " = *" + src + ".DeepCopy()\n")
+		} else {
+			c.WriteString(dst + " = " + src + "\n")
+		}
+	}
+}
+
+func (gen *GenGo) genFunDeepCopy(st *StructInfo) {
+	c := &gen.code
+	c.WriteString(`
+// DeepCopy returns a copy of st which shares no memory with it.
+func (st *` + st.Name + `) DeepCopy() *` + st.Name + ` {
+	if st == nil {
+		return nil
+	}
+	ret := new(` + st.Name + `)
+	*ret = *st
+`)
+	for _, v := range st.Mb {
+		if needDeepCopy(v.Type) {
+			gen.genCopyVar(v.Type, "ret."+v.Key, "st."+v.Key)
+		}
+	}
+	c.WriteString("return ret\n}\n")
+}
+
+// quotedString reports whether the value of the type is formatted by %q, the strings and the vectors and the
+// maps of the strings.
+func quotedString(ty *VarType) bool {
+	switch ty.Type {
+	case tkTString:
+		return true
+	case tkTVector, tkTArray:
+		return quotedString(ty.TypeK)
+	case tkTMap:
+		return quotedString(ty.TypeK) && quotedString(ty.TypeV)
+	}
+	return false
+}
+
+func (gen *GenGo) genFunString(st *StructInfo) {
+	var format []string
+	var args []string
+	for _, v := range st.Mb {
+		switch {
+		case (v.Type.Type == tkTVector || v.Type.Type == tkTArray) && v.Type.TypeK.Type == tkTByte:
+			// the buffers are not dumped in the logs
+			format = append(format, v.OriginKey+":[%d bytes]")
+			args = append(args, "len(st."+v.Key+")")
+		case quotedString(v.Type):
+			format = append(format, v.OriginKey+":%q")
+			args = append(args, "st."+v.Key)
+		default:
+			format = append(format, v.OriginKey+":%v")
+			args = append(args, "st."+v.Key)
+		}
+	}
+	c := &gen.code
+	c.WriteString(`
+// String returns the members of st with the names in the tars file for logging, the strings are quoted and
+// the buffers are replaced by their lengths. The receiver is a value, so the structs in the members,
+// the vectors and the maps are formatted by their String too.
+func (st ` + st.Name + `) String() string {
+`)
+	if len(args) == 0 {
+		c.WriteString("return \"{}\"\n}\n")
+		return
+	}
+	c.WriteString("return fmt.Sprintf(" + strconv.Quote("{"+strings.Join(format, " ")+"}") + ", " + strings.Join(args, ", ") + ")\n}\n")
 }
 
 func (gen *GenGo) makeEnumName(en *EnumInfo, mb *EnumMember) string {
@@ -998,22 +1209,28 @@ func (gen *GenGo) genEnum(en *EnumInfo) {
 	en.rename()
 
 	c := &gen.code
+	gen.genComment(en.Comment)
 	c.WriteString("type " + en.Name + " int32\n")
 	c.WriteString("const (\n")
 	var it int32
-	for _, v := range en.Mb {
+	values := make([]int32, len(en.Mb))
+	for i, v := range en.Mb {
+		values[i] = it
+		gen.genComment(v.Comment)
 		if v.Type == 0 {
 			//use value
 			c.WriteString(gen.makeEnumName(en, &v) + ` = ` + strconv.Itoa(int(v.Value)) + "\n")
+			values[i] = v.Value
 			it = v.Value + 1
 		} else if v.Type == 1 {
 			// use name
 			find := false
-			for _, ref := range en.Mb {
+			for j, ref := range en.Mb {
 				if ref.Key == v.Name {
 					find = true
 					c.WriteString(gen.makeEnumName(en, &v) + ` = ` + gen.makeEnumName(en, &ref) + "\n")
-					it = ref.Value + 1
+					values[i] = values[j]
+					it = values[j] + 1
 					break
 				}
 				if ref.Key == v.Key {
@@ -1032,6 +1249,43 @@ func (gen *GenGo) genEnum(en *EnumInfo) {
 	}
 
 	c.WriteString(")\n")
+
+	if *gGenEnumString {
+		gen.genEnumString(en, values)
+	}
+}
+
+func (gen *GenGo) genEnumString(en *EnumInfo, values []int32) {
+	c := &gen.code
+	c.WriteString(`
+// String returns the name of the enum as defined in the tars file.
+func (e ` + en.Name + `) String() string {
+	switch e {
+`)
+	// aliases share the value, only the first name is used
+	seen := make(map[int32]bool)
+	for i, v := range en.Mb {
+		if seen[values[i]] {
+			continue
+		}
+		seen[values[i]] = true
+		c.WriteString("case " + gen.makeEnumName(en, &v) + ":\nreturn \"" + v.OriginKey + "\"\n")
+	}
+	c.WriteString(`}
+	return fmt.Sprintf("` + en.Name + `(%d)", int32(e))
+}
+
+// Parse` + en.Name + ` returns the enum of the name as defined in the tars file.
+func Parse` + en.Name + `(name string) (` + en.Name + `, error) {
+	switch name {
+`)
+	for _, v := range en.Mb {
+		c.WriteString("case \"" + v.OriginKey + "\":\nreturn " + gen.makeEnumName(en, &v) + ", nil\n")
+	}
+	c.WriteString(`}
+	return 0, fmt.Errorf("unknown ` + en.Name + ` %q", name)
+}
+`)
 }
 
 func (gen *GenGo) genConst(cst []ConstInfo) {
@@ -1045,6 +1299,7 @@ func (gen *GenGo) genConst(cst []ConstInfo) {
 
 	for _, v := range gen.p.Const {
 		v.rename()
+		gen.genComment(v.Comment)
 		c.WriteString(v.Name + " " + gen.genType(v.Type) + " = " + v.Value + "\n")
 	}
 
@@ -1079,12 +1334,27 @@ func (gen *GenGo) genInterface(itf *InterfaceInfo) {
 
 	gen.genIFDispatch(itf)
 
+	if *gReflection || *gReflectionSource {
+		gen.genIFReflection(itf)
+	}
+
 	gen.saveToSourceFile(itf.Name + ".tars.go")
+
+	if *gGenMock {
+		gen.code.Reset()
+		gen.genHead()
+		gen.genIFMockPackage(itf)
+		gen.genIFClient(itf)
+		gen.genIFMock(itf)
+		gen.genIFFake(itf)
+		gen.saveToSourceFile(itf.Name + "_mock.tars.go")
+	}
 }
 
 func (gen *GenGo) genIFProxy(itf *InterfaceInfo) {
 	c := &gen.code
 	c.WriteString("//" + itf.Name + " struct\n")
+	gen.genComment(itf.Comment)
 	c.WriteString("type " + itf.Name + " struct {" + "\n")
 	c.WriteString("s m.Servant" + "\n")
 	c.WriteString("}" + "\n")
@@ -1114,13 +1384,13 @@ func (_obj *` + itf.Name + `) TarsSetProtocol(p m.Protocol) {
 
 	if *gAddServant {
 		c.WriteString(`//AddServant adds servant  for the service.
-func (_obj *` + itf.Name + `) AddServant(imp _imp` + itf.Name + `, obj string) {
-  tars.AddServant(_obj, imp, obj)
+func (_obj *` + itf.Name + `) AddServant(imp _imp` + itf.Name + `, obj string, interceptors ...tars.ServerInterceptor) {
+  tars.AddServant(_obj, imp, obj, interceptors...)
 }
 `)
 		c.WriteString(`//AddServant adds servant  for the service with context.
-func (_obj *` + itf.Name + `) AddServantWithContext(imp _imp` + itf.Name + `WithContext, obj string) {
-  tars.AddServantWithContext(_obj, imp, obj)
+func (_obj *` + itf.Name + `) AddServantWithContext(imp _imp` + itf.Name + `WithContext, obj string, interceptors ...tars.ServerInterceptor) {
+  tars.AddServantWithContext(_obj, imp, obj, interceptors...)
 }
 `)
 	}
@@ -1131,13 +1401,16 @@ func (gen *GenGo) genIFProxyFun(interfName string, fun *FunInfo, withContext boo
 	if withContext == true {
 		if isOneWay {
 			c.WriteString("//" + fun.Name + "OneWayWithContext is the proxy function for the method defined in the tars file, with the context\n")
+			gen.genComment(fun.Comment)
 			c.WriteString("func (_obj *" + interfName + ") " + fun.Name + "OneWayWithContext(ctx context.Context,")
 		} else {
 			c.WriteString("//" + fun.Name + "WithContext is the proxy function for the method defined in the tars file, with the context\n")
+			gen.genComment(fun.Comment)
 			c.WriteString("func (_obj *" + interfName + ") " + fun.Name + "WithContext(ctx context.Context,")
 		}
 	} else {
 		c.WriteString("//" + fun.Name + " is the proxy function for the method defined in the tars file, with the context\n")
+		gen.genComment(fun.Comment)
 		c.WriteString("func (_obj *" + interfName + ") " + fun.Name + "(")
 	}
 	for _, v := range fun.Args {
@@ -1275,6 +1548,228 @@ if len(_opt) == 1{
 	c.WriteString("}" + "\n")
 }
 
+func (gen *GenGo) genIFMockPackage(itf *InterfaceInfo) {
+	gen.code.WriteString("package " + gen.p.Module + "\n\n")
+	gen.code.WriteString(`
+import (
+	"context"
+	"sync"
+
+`)
+	gen.code.WriteString("\"" + gen.tarsPath + "\"\n")
+	gen.code.WriteString("\"" + gen.tarsPath + "/protocol/res/requestf\"\n")
+	gen.code.WriteString("m \"" + gen.tarsPath + "/model\"\n")
+	gen.code.WriteString("\"" + gen.tarsPath + "/util/tools\"\n")
+	gen.code.WriteString("\"" + gen.tarsPath + "/util/current\"\n")
+
+	if *gModuleCycle == true {
+		for k, v := range itf.DependModuleWithJce {
+			gen.genIFImport(k, v)
+		}
+	} else {
+		for k := range itf.DependModule {
+			gen.genIFImport(k, "")
+		}
+	}
+	gen.code.WriteString(")\n")
+}
+
+// genIFClientFun writes the signature of the proxy method, suffix is "", "WithContext" or "OneWayWithContext".
+func (gen *GenGo) genIFClientFun(fun *FunInfo, suffix string) {
+	c := &gen.code
+	c.WriteString(fun.Name + suffix + "(")
+	if suffix != "" {
+		c.WriteString("ctx context.Context, ")
+	}
+	for _, v := range fun.Args {
+		gen.genArgs(&v)
+	}
+	c.WriteString(" _opt ...map[string]string)")
+	if fun.HasRet {
+		c.WriteString("(ret " + gen.genType(fun.RetType) + ", err error)")
+	} else {
+		c.WriteString("(err error)")
+	}
+}
+
+func (gen *GenGo) genIFClient(itf *InterfaceInfo) {
+	c := &gen.code
+	c.WriteString(`
+// ` + itf.Name + `Client is the client side of ` + itf.Name + `, implemented by *` + itf.Name + ` and *` + itf.Name + `Mock.
+type ` + itf.Name + `Client interface {
+`)
+	for _, v := range itf.Fun {
+		for _, suffix := range []string{"", "WithContext", "OneWayWithContext"} {
+			gen.genIFClientFun(&v, suffix)
+			c.WriteString("\n")
+		}
+	}
+	c.WriteString(`}
+
+var _ ` + itf.Name + `Client = (*` + itf.Name + `)(nil)
+var _ ` + itf.Name + `Client = (*` + itf.Name + `Mock)(nil)
+`)
+}
+
+func (gen *GenGo) genIFMock(itf *InterfaceInfo) {
+	c := &gen.code
+	c.WriteString(`
+// ` + itf.Name + `MockCall records a call made to ` + itf.Name + `Mock.
+type ` + itf.Name + `MockCall struct {
+	Method  string // method name as defined in the tars file
+	Args    []interface{}
+	Context map[string]string
+	Status  map[string]string
+}
+
+// ` + itf.Name + `Mock implements ` + itf.Name + `Client without a server, for unit tests.
+// A method calls its Func field if set, or returns zero values and Err.
+// The variants with context and one way share the Func field of the method.
+type ` + itf.Name + `Mock struct {
+	Err error
+`)
+	for _, v := range itf.Fun {
+		c.WriteString(v.Name + "Func func(ctx context.Context, ")
+		for _, arg := range v.Args {
+			gen.genArgs(&arg)
+		}
+		c.WriteString(" _opt ...map[string]string)")
+		if v.HasRet {
+			c.WriteString("(" + gen.genType(v.RetType) + ", error)\n")
+		} else {
+			c.WriteString("error\n")
+		}
+	}
+	c.WriteString(`
+	mu    sync.Mutex
+	calls []` + itf.Name + `MockCall
+}
+
+// Calls returns the calls recorded so far.
+func (_m *` + itf.Name + `Mock) Calls() []` + itf.Name + `MockCall {
+	_m.mu.Lock()
+	defer _m.mu.Unlock()
+	return append([]` + itf.Name + `MockCall(nil), _m.calls...)
+}
+
+// Reset clears the recorded calls.
+func (_m *` + itf.Name + `Mock) Reset() {
+	_m.mu.Lock()
+	_m.calls = nil
+	_m.mu.Unlock()
+}
+
+func (_m *` + itf.Name + `Mock) record(method string, _opt []map[string]string, args ...interface{}) {
+	call := ` + itf.Name + `MockCall{Method: method, Args: args}
+	if len(_opt) > 0 {
+		call.Context = _opt[0]
+	}
+	if len(_opt) > 1 {
+		call.Status = _opt[1]
+	}
+	_m.mu.Lock()
+	_m.calls = append(_m.calls, call)
+	_m.mu.Unlock()
+}
+`)
+
+	for _, v := range itf.Fun {
+		var args []string
+		for _, arg := range v.Args {
+			args = append(args, arg.Name)
+		}
+		argList := strings.Join(args, ", ")
+		if argList != "" {
+			argList += ", "
+		}
+
+		c.WriteString("\n// " + v.Name + " records the call and calls " + v.Name + "Func.\n")
+		c.WriteString("func (_m *" + itf.Name + "Mock) ")
+		gen.genIFClientFun(&v, "")
+		c.WriteString("{\nreturn _m." + v.Name + "WithContext(context.Background(), " + argList + "_opt...)\n}\n")
+
+		c.WriteString("\n// " + v.Name + "WithContext records the call and calls " + v.Name + "Func.\n")
+		c.WriteString("func (_m *" + itf.Name + "Mock) ")
+		gen.genIFClientFun(&v, "WithContext")
+		c.WriteString("{\n_m.record(\"" + v.OriginName + "\", _opt")
+		for _, arg := range args {
+			c.WriteString(", " + arg)
+		}
+		c.WriteString(")\n")
+		c.WriteString("if _m." + v.Name + "Func != nil {\nreturn _m." + v.Name + "Func(ctx, " + argList + "_opt...)\n}\n")
+		if v.HasRet {
+			c.WriteString("return ret, _m.Err\n}\n")
+		} else {
+			c.WriteString("return _m.Err\n}\n")
+		}
+
+		c.WriteString("\n// " + v.Name + "OneWayWithContext records the call and calls " + v.Name + "Func.\n")
+		c.WriteString("func (_m *" + itf.Name + "Mock) ")
+		gen.genIFClientFun(&v, "OneWayWithContext")
+		c.WriteString("{\nreturn _m." + v.Name + "WithContext(ctx, " + argList + "_opt...)\n}\n")
+	}
+}
+
+func (gen *GenGo) genIFFake(itf *InterfaceInfo) {
+	c := &gen.code
+	c.WriteString(`
+// New` + itf.Name + `Fake returns a proxy whose calls are dispatched in-process to imp,
+// which implements _imp` + itf.Name + ` or _imp` + itf.Name + `WithContext, so no server is needed.
+func New` + itf.Name + `Fake(imp interface{}) *` + itf.Name + ` {
+	_obj := new(` + itf.Name + `)
+	fake := &_fake` + itf.Name + `Servant{obj: _obj, imp: imp}
+	switch imp.(type) {
+	case _imp` + itf.Name + `WithContext:
+		fake.withContext = true
+	case _imp` + itf.Name + `:
+	default:
+		panic("imp does not implement ` + itf.Name + `")
+	}
+	_obj.SetServant(fake)
+	return _obj
+}
+
+type _fake` + itf.Name + `Servant struct {
+	obj         *` + itf.Name + `
+	imp         interface{}
+	withContext bool
+}
+
+var _ m.Servant = (*_fake` + itf.Name + `Servant)(nil)
+
+// Tars_invoke dispatches the request to the implement like the server does, the error of the implement
+// is returned as the *tars.Error the client gets from a server.
+func (s *_fake` + itf.Name + `Servant) Tars_invoke(ctx context.Context, ctype byte,
+	sFuncName string,
+	buf []byte,
+	status map[string]string,
+	reqContext map[string]string,
+	resp *requestf.ResponsePacket) error {
+	req := &requestf.RequestPacket{
+		IVersion:     1,
+		CPacketType:  int8(ctype),
+		SFuncName:    sFuncName,
+		SBuffer:      tools.ByteToInt8(buf),
+		Status:       status,
+		Context:      reqContext,
+	}
+	ctx = current.ContextWithTarsCurrent(ctx)
+	current.SetRequestStatus(ctx, req.Status)
+	current.SetRequestContext(ctx, req.Context)
+	current.SetPacketTypeFromContext(ctx, req.CPacketType)
+	return tars.ServantError(s.obj.Dispatch(ctx, s.imp, req, resp, s.withContext))
+}
+
+// TarsSetTimeout does nothing for the fake.
+func (s *_fake` + itf.Name + `Servant) TarsSetTimeout(t int) {
+}
+
+// TarsSetProtocol does nothing for the fake.
+func (s *_fake` + itf.Name + `Servant) TarsSetProtocol(p m.Protocol) {
+}
+`)
+}
+
 func (gen *GenGo) genArgs(arg *ArgInfo) {
 	c := &gen.code
 	c.WriteString(arg.Name + " ")
@@ -1285,6 +1780,26 @@ func (gen *GenGo) genArgs(arg *ArgInfo) {
 	c.WriteString(gen.genType(arg.Type) + ",")
 }
 
+func (gen *GenGo) genIFReflection(itf *InterfaceInfo) {
+	c := &gen.code
+	source := `""`
+	if *gReflectionSource {
+		b, err := readSource(gen.p.Source)
+		if err != nil {
+			gen.genErr(err.Error())
+		}
+		source = strconv.Quote(string(b))
+	}
+	c.WriteString("// TarsReflection returns the name, the method signatures and the tars file of the interface for the reflection of the server.\n")
+	c.WriteString("func (_obj *" + itf.Name + ") TarsReflection() (string, []string, string) {\n")
+	c.WriteString("return " + strconv.Quote(gen.p.OriginModule+"."+itf.OriginName) + ", []string{\n")
+	for _, v := range itf.Fun {
+		c.WriteString(strconv.Quote(docSignature(&v)) + ",\n")
+	}
+	c.WriteString("}, " + source + "\n")
+	c.WriteString("}\n")
+}
+
 func (gen *GenGo) genIFServer(itf *InterfaceInfo) {
 	c := &gen.code
 	c.WriteString("type _imp" + itf.Name + " interface {" + "\n")
@@ -1305,6 +1820,7 @@ func (gen *GenGo) genIFServerWithContext(itf *InterfaceInfo) {
 
 func (gen *GenGo) genIFServerFun(fun *FunInfo) {
 	c := &gen.code
+	gen.genComment(fun.Comment)
 	c.WriteString(fun.Name + "(")
 	for _, v := range fun.Args {
 		gen.genArgs(&v)
@@ -1319,6 +1835,7 @@ func (gen *GenGo) genIFServerFun(fun *FunInfo) {
 
 func (gen *GenGo) genIFServerFunWithContext(fun *FunInfo) {
 	c := &gen.code
+	gen.genComment(fun.Comment)
 	c.WriteString(fun.Name + "(ctx context.Context, ")
 	for _, v := range fun.Args {
 		gen.genArgs(&v)
@@ -1362,10 +1879,18 @@ func (gen *GenGo) genIFDispatch(itf *InterfaceInfo) {
 		gen.genSwitchCase(itf.Name, &v)
 	}
 
-	c.WriteString(`
+	if *gAddServant {
+		c.WriteString(`
+	default:
+		return tars.NoFuncError(req.SFuncName)
+	}`)
+	} else {
+		c.WriteString(`
 	default:
 		return fmt.Errorf("func mismatch")
+	}`)
 	}
+	c.WriteString(`
 	var _status map[string]string
 	s, ok := current.GetResponseStatus(ctx)
 	if ok  && s != nil {
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// setFlags sets the bool flags of the generator, and returns the function to restore them.
+func setFlags(flags map[*bool]bool) func() {
+	old := make(map[*bool]bool, len(flags))
+	for f, v := range flags {
+		old[f] = *f
+		*f = v
+	}
+	tarsPath := gTarsPath
+	gTarsPath = "github.com/MacgradyHuang/TarsGo/tars"
+	return func() {
+		for f, v := range old {
+			*f = v
+		}
+		gTarsPath = tarsPath
+	}
+}
+
+// genGo generates the go code of the tars file into a temp dir, and returns the dir.
+func genGo(t *testing.T, file string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tars2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gFileMap = make(map[string]bool)
+	gen := NewGenGo(file, "", dir)
+	gen.tarsPath = gTarsPath
+	gen.Gen()
+	return dir
+}
+
+// checkGolden compares the file with the golden file, or updates the golden file with -update.
+func checkGolden(t *testing.T, file, golden string) {
+	t.Helper()
+	got, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoldenBytes(t, file, got, golden)
+}
+
+// checkGoldenBytes compares the content of name with the golden file, or updates the golden file with -update.
+func checkGoldenBytes(t *testing.T, name string, got []byte, golden string) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from %s, run go test -update to update it:\n%s", name, golden, got)
+	}
+}
+
+// goTest runs go test on the generated code in dir, which is built with the tars package of this repo.
+func goTest(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("building the generated code in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not found")
+	}
+	root, err := filepath.Abs("../../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := "module gentest\n\ngo 1.13\n\nrequire github.com/MacgradyHuang/TarsGo v0.0.0\n\n" +
+		"replace github.com/MacgradyHuang/TarsGo => " + root + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goBin, append([]string{"test"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off", "GOPROXY=off", "GOSUMDB=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test %v: %v\n%s", args, err, out)
+	}
+}
+
+// TestGenStructHelpers tests the code of -gen-equal, -gen-deepcopy, -gen-string and -gen-enum-string,
+// by the golden files and by the tests of testdata/gen/gen_test.go.in run on the generated code.
+func TestGenStructHelpers(t *testing.T) {
+	defer setFlags(map[*bool]bool{gGenEqual: true, gGenDeepCopy: true, gGenString: true, gGenEnumString: true})()
+	dir := genGo(t, "testdata/gen/Gen.tars")
+	defer os.RemoveAll(dir)
+
+	checkGolden(t, filepath.Join(dir, "Test", "Gen.go"), "testdata/gen/Gen.go.golden")
+
+	test, err := ioutil.ReadFile("testdata/gen/gen_test.go.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "Test", "gen_test.go"), test, 0644); err != nil {
+		t.Fatal(err)
+	}
+	goTest(t, dir, "./Test")
+}
+
+// TestGenMock tests the mock and the fake of -gen-mock by the tests of testdata/mock/mock_test.go.in run on
+// the generated code.
+func TestGenMock(t *testing.T) {
+	defer setFlags(map[*bool]bool{gGenMock: true})()
+	dir := genGo(t, "testdata/mock/Mock.tars")
+	defer os.RemoveAll(dir)
+
+	test, err := ioutil.ReadFile("testdata/mock/mock_test.go.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "Test", "mock_test.go"), test, 0644); err != nil {
+		t.Fatal(err)
+	}
+	goTest(t, dir, "./Test")
+}
@@ -0,0 +1,611 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var gProto = flag.Bool("proto", false, "Generate .proto file besides the go code, and the go converters with -proto-go-package")
+var gProtoGoPackage = flag.String("proto-go-package", "", "go package prefix of the protoc generated code, the module name is appended. converters are generated only if set")
+
+var gProtoFileMap = make(map[string]bool)
+
+// GenProto generates a .proto file from the tars file, and the converters between
+// the tars2go types and the protoc-gen-go types.
+// Field numbers are the tars tags plus one, as protobuf field numbers start from 1.
+type GenProto struct {
+	code   bytes.Buffer
+	conv   bytes.Buffer
+	vc     int
+	path   string
+	prefix string
+	p      *Parse
+
+	// wrapper messages for nested containers, which protobuf does not support directly
+	wrappers map[string]*VarType
+	// the message the converters are generated for, wrappers are nested in it
+	owner string
+
+	// proto file name(not include .tars)
+	ProtoName string
+}
+
+// NewGenProto build up a new proto generator.
+func NewGenProto(path string, outdir string) *GenProto {
+	if outdir != "" && !strings.HasSuffix(outdir, "/") {
+		outdir += "/"
+	}
+	return &GenProto{path: path, prefix: outdir, ProtoName: path2ProtoName(path)}
+}
+
+// Gen parses the file and generates the code.
+func (gen *GenProto) Gen() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}()
+
+	gen.p = ParseFile(gen.path, make([]string, 0))
+	gen.genAll()
+}
+
+func (gen *GenProto) genErr(err string) {
+	panic(err)
+}
+
+func (gen *GenProto) genAll() {
+	if gProtoFileMap[gen.path] {
+		return
+	}
+	gProtoFileMap[gen.path] = true
+
+	gen.p.rename()
+	for i := range gen.p.Struct {
+		gen.p.Struct[i].rename()
+	}
+	for i := range gen.p.Enum {
+		gen.p.Enum[i].rename()
+	}
+	for i := range gen.p.Interface {
+		gen.p.Interface[i].rename()
+	}
+	for _, inc := range gen.p.IncParse {
+		gen2 := &GenProto{
+			path:      inc.Source,
+			prefix:    gen.prefix,
+			ProtoName: path2ProtoName(inc.Source),
+			p:         inc,
+		}
+		gen2.genAll()
+	}
+
+	gen.wrappers = make(map[string]*VarType)
+	gen.genProto()
+	gen.saveProto()
+	if *gProtoGoPackage != "" && len(gen.p.Struct) > 0 {
+		gen.genConverters()
+	}
+}
+
+// === proto area ===
+
+// protoScalar returns the protobuf scalar type, or "" for the other types.
+func protoScalar(ty *VarType) string {
+	switch ty.Type {
+	case tkTBool:
+		return "bool"
+	case tkTByte, tkTShort, tkTInt:
+		if ty.Unsigned {
+			return "uint32"
+		}
+		return "int32"
+	case tkTLong:
+		if ty.Unsigned {
+			return "uint64"
+		}
+		return "int64"
+	case tkTFloat:
+		return "float"
+	case tkTDouble:
+		return "double"
+	case tkTString:
+		return "string"
+	}
+	return ""
+}
+
+func isBytes(ty *VarType) bool {
+	return ty.Type == tkTVector && ty.TypeK.Type == tkTByte
+}
+
+func isList(ty *VarType) bool {
+	return (ty.Type == tkTVector && !isBytes(ty)) || ty.Type == tkTArray
+}
+
+// needWrap reports whether the type must be wrapped in a message as element of a list or value of a map.
+func needWrap(ty *VarType) bool {
+	return isList(ty) || ty.Type == tkTMap
+}
+
+// splitTypeName returns the module and the name of a custom type.
+func (gen *GenProto) splitTypeName(ty *VarType) (string, string) {
+	vec := strings.Split(ty.TypeSt, "::")
+	if len(vec) == 1 {
+		return gen.p.Module, upperFirstLetter(vec[0])
+	}
+	module := vec[0]
+	if *gModuleUpper {
+		module = upperFirstLetter(module)
+	}
+	return module, upperFirstLetter(vec[1])
+}
+
+// wrapName names the wrapper message after the type, such as VectorString.
+func (gen *GenProto) wrapName(ty *VarType) string {
+	switch ty.Type {
+	case tkTVector, tkTArray:
+		if isBytes(ty) {
+			return "Bytes"
+		}
+		return "Vector" + gen.wrapName(ty.TypeK)
+	case tkTMap:
+		return "Map" + gen.wrapName(ty.TypeK) + gen.wrapName(ty.TypeV)
+	case tkName:
+		module, name := gen.splitTypeName(ty)
+		if module != gen.p.Module {
+			return upperFirstLetter(module) + name
+		}
+		return name
+	}
+	name := upperFirstLetter(TokenMap[ty.Type])
+	if ty.Unsigned {
+		name = "Unsigned" + name
+	}
+	return name
+}
+
+// protoType returns the type of a field, elem is true for list elements and map values.
+func (gen *GenProto) protoType(ty *VarType, elem bool) string {
+	if elem && needWrap(ty) {
+		name := gen.wrapName(ty)
+		gen.wrappers[name] = ty
+		return name
+	}
+	if s := protoScalar(ty); s != "" {
+		return s
+	}
+	switch ty.Type {
+	case tkTVector, tkTArray:
+		if isBytes(ty) {
+			return "bytes"
+		}
+		return "repeated " + gen.protoType(ty.TypeK, true)
+	case tkTMap:
+		if ty.TypeK.Type == tkName && ty.TypeK.CType == tkEnum {
+			return "map<int32, " + gen.protoType(ty.TypeV, true) + ">"
+		}
+		key := protoScalar(ty.TypeK)
+		if key == "" || key == "float" || key == "double" {
+			gen.genErr("map key " + TokenMap[ty.TypeK.Type] + " is not supported by protobuf")
+		}
+		return "map<" + key + ", " + gen.protoType(ty.TypeV, true) + ">"
+	case tkName:
+		module, name := gen.splitTypeName(ty)
+		if module != gen.p.Module {
+			return module + "." + name
+		}
+		return name
+	}
+	gen.genErr("Unknow Type " + TokenMap[ty.Type])
+	return ""
+}
+
+func (gen *GenProto) genProto() {
+	c := &gen.code
+	c.WriteString(`// This file was generated by tars2go ` + VERSION + `
+// Generated from ` + gen.ProtoName + `.tars
+// Field numbers are the tars tags plus one.
+syntax = "proto3";
+
+package ` + gen.p.Module + `;
+`)
+	if *gProtoGoPackage != "" {
+		c.WriteString("\noption go_package = \"" + *gProtoGoPackage + "/" + gen.p.Module + "\";\n")
+	}
+	if len(gen.p.Include) > 0 {
+		c.WriteString("\n")
+	}
+	for _, inc := range gen.p.Include {
+		c.WriteString("import \"" + path2ProtoName(inc) + ".proto\";\n")
+	}
+
+	if len(gen.p.Const) > 0 {
+		c.WriteString("\n// protobuf has no constants, they are kept as defined in the tars file:\n")
+		for _, v := range gen.p.Const {
+			c.WriteString("// const " + TokenMap[v.Type.Type] + " " + v.Name + " = " + v.Value + ";\n")
+		}
+	}
+
+	for _, en := range gen.p.Enum {
+		gen.genProtoEnum(&en)
+	}
+	for _, st := range gen.p.Struct {
+		c.WriteString("\nmessage " + st.Name + " {\n")
+		for _, mb := range st.Mb {
+			c.WriteString("  " + gen.protoType(mb.Type, false) + " " + mb.OriginKey + " = " + strconv.Itoa(int(mb.Tag)+1) + ";\n")
+		}
+		gen.genProtoWrappers()
+		c.WriteString("}\n")
+	}
+	for _, itf := range gen.p.Interface {
+		gen.genProtoService(&itf)
+	}
+}
+
+// genProtoWrappers writes the wrappers used by the message as nested messages, so they never conflict.
+func (gen *GenProto) genProtoWrappers() {
+	c := &gen.code
+	// wrappers may add other wrappers while generated
+	done := make(map[string]bool)
+	for len(done) < len(gen.wrappers) {
+		var names []string
+		for name := range gen.wrappers {
+			if !done[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			done[name] = true
+			c.WriteString("\n  message " + name + " {\n    " + gen.protoType(gen.wrappers[name], false) + " value = 1;\n  }\n")
+		}
+	}
+	gen.wrappers = make(map[string]*VarType)
+}
+
+func (gen *GenProto) genProtoEnum(en *EnumInfo) {
+	c := &gen.code
+	values := compatEnumValues(en)
+	seen := make(map[int32]bool)
+	alias := false
+	for _, v := range values {
+		if seen[v] {
+			alias = true
+		}
+		seen[v] = true
+	}
+
+	c.WriteString("\nenum " + en.Name + " {\n")
+	if alias {
+		c.WriteString("  option allow_alias = true;\n")
+	}
+	// proto3 requires the first value to be zero, and values are scoped in the package
+	if !seen[0] {
+		c.WriteString("  " + en.Name + "_UNSPECIFIED = 0;\n")
+	}
+	for _, mb := range en.Mb {
+		if values[mb.OriginKey] == 0 {
+			c.WriteString("  " + en.Name + "_" + mb.OriginKey + " = 0;\n")
+		}
+	}
+	for _, mb := range en.Mb {
+		if values[mb.OriginKey] != 0 {
+			c.WriteString("  " + en.Name + "_" + mb.OriginKey + " = " + strconv.Itoa(int(values[mb.OriginKey])) + ";\n")
+		}
+	}
+	c.WriteString("}\n")
+}
+
+func (gen *GenProto) genProtoService(itf *InterfaceInfo) {
+	c := &gen.code
+	for _, fun := range itf.Fun {
+		c.WriteString("\nmessage " + itf.Name + fun.Name + "Request {\n")
+		for k, arg := range fun.Args {
+			if !arg.IsOut {
+				c.WriteString("  " + gen.protoType(arg.Type, false) + " " + arg.OriginName + " = " + strconv.Itoa(k+2) + ";\n")
+			}
+		}
+		gen.genProtoWrappers()
+		c.WriteString("}\n")
+
+		c.WriteString("\nmessage " + itf.Name + fun.Name + "Response {\n")
+		if fun.HasRet {
+			c.WriteString("  " + gen.protoType(fun.RetType, false) + " ret = 1;\n")
+		}
+		for k, arg := range fun.Args {
+			if arg.IsOut {
+				c.WriteString("  " + gen.protoType(arg.Type, false) + " " + arg.OriginName + " = " + strconv.Itoa(k+2) + ";\n")
+			}
+		}
+		gen.genProtoWrappers()
+		c.WriteString("}\n")
+	}
+
+	c.WriteString("\nservice " + itf.Name + " {\n")
+	for _, fun := range itf.Fun {
+		c.WriteString("  rpc " + fun.OriginName + "(" + itf.Name + fun.Name + "Request) returns (" + itf.Name + fun.Name + "Response);\n")
+	}
+	c.WriteString("}\n")
+}
+
+func (gen *GenProto) saveProto() {
+	if err := os.MkdirAll(gen.prefix+".", 0766); err != nil {
+		gen.genErr(err.Error())
+	}
+	if err := ioutil.WriteFile(gen.prefix+gen.ProtoName+".proto", gen.code.Bytes(), 0666); err != nil {
+		gen.genErr(err.Error())
+	}
+}
+
+// === converter area ===
+
+// pbAlias is the import alias of the protoc-gen-go package of the module.
+func pbAlias(module string) string {
+	return "pb" + module
+}
+
+// goProtoType returns the go type protoc-gen-go generates for the field type.
+func (gen *GenProto) goProtoType(ty *VarType, elem bool) string {
+	if elem && needWrap(ty) {
+		return "*" + pbAlias(gen.p.Module) + "." + gen.owner + "_" + gen.wrapName(ty)
+	}
+	switch ty.Type {
+	case tkTBool:
+		return "bool"
+	case tkTFloat:
+		return "float32"
+	case tkTDouble:
+		return "float64"
+	case tkTString:
+		return "string"
+	case tkTVector, tkTArray:
+		if isBytes(ty) {
+			return "[]byte"
+		}
+		return "[]" + gen.goProtoType(ty.TypeK, true)
+	case tkTMap:
+		key := gen.goProtoType(ty.TypeK, false)
+		if ty.TypeK.Type == tkName {
+			key = "int32"
+		}
+		return "map[" + key + "]" + gen.goProtoType(ty.TypeV, true)
+	case tkName:
+		module, name := gen.splitTypeName(ty)
+		if ty.CType == tkStruct {
+			return "*" + pbAlias(module) + "." + name
+		}
+		return pbAlias(module) + "." + name
+	}
+	return protoScalar(ty)
+}
+
+func (gen *GenProto) tarsType(ty *VarType) string {
+	var g GenGo
+	return g.genType(ty)
+}
+
+func (gen *GenProto) newVar(prefix string) string {
+	gen.vc++
+	return prefix + strconv.Itoa(gen.vc)
+}
+
+// genToProto converts src of the tars type to dst of the protobuf type.
+func (gen *GenProto) genToProto(ty *VarType, dst string, src string) {
+	c := &gen.conv
+	switch ty.Type {
+	case tkTVector, tkTArray:
+		if isBytes(ty) {
+			if ty.TypeK.Unsigned {
+				c.WriteString(dst + " = append([]byte(nil), " + src + "[:]...)\n")
+				return
+			}
+			i := gen.newVar("i")
+			c.WriteString(dst + " = make([]byte, len(" + src + "))\n")
+			c.WriteString("for " + i + " := range " + src + " {\n" + dst + "[" + i + "] = byte(" + src + "[" + i + "])\n}\n")
+			return
+		}
+		i := gen.newVar("i")
+		c.WriteString(dst + " = make(" + gen.goProtoType(ty, false) + ", len(" + src + "))\n")
+		c.WriteString("for " + i + " := range " + src + " {\n")
+		gen.genElemToProto(ty.TypeK, dst+"["+i+"]", src+"["+i+"]")
+		c.WriteString("}\n")
+	case tkTMap:
+		k, v, e := gen.newVar("k"), gen.newVar("v"), gen.newVar("e")
+		c.WriteString(dst + " = make(" + gen.goProtoType(ty, false) + ", len(" + src + "))\n")
+		c.WriteString("for " + k + ", " + v + " := range " + src + " {\n")
+		c.WriteString("var " + e + " " + gen.goProtoType(ty.TypeV, true) + "\n")
+		gen.genElemToProto(ty.TypeV, e, v)
+		keyType := gen.goProtoType(ty.TypeK, false)
+		if ty.TypeK.Type == tkName {
+			keyType = "int32"
+		}
+		c.WriteString(dst + "[" + keyType + "(" + k + ")] = " + e + "\n}\n")
+	case tkName:
+		if ty.CType == tkStruct {
+			c.WriteString(dst + " = " + src + ".ToProto()\n")
+		} else {
+			c.WriteString(dst + " = " + gen.goProtoType(ty, false) + "(" + src + ")\n")
+		}
+	default:
+		c.WriteString(dst + " = " + gen.goProtoType(ty, false) + "(" + src + ")\n")
+	}
+}
+
+func (gen *GenProto) genElemToProto(ty *VarType, dst string, src string) {
+	if needWrap(ty) {
+		gen.conv.WriteString(dst + " = new(" + pbAlias(gen.p.Module) + "." + gen.owner + "_" + gen.wrapName(ty) + ")\n")
+		dst += ".Value"
+	}
+	gen.genToProto(ty, dst, src)
+}
+
+// genFromProto converts src of the protobuf type to dst of the tars type.
+func (gen *GenProto) genFromProto(ty *VarType, dst string, src string) {
+	c := &gen.conv
+	switch ty.Type {
+	case tkTVector:
+		if isBytes(ty) {
+			if ty.TypeK.Unsigned {
+				c.WriteString(dst + " = append([]uint8(nil), " + src + "...)\n")
+				return
+			}
+			i := gen.newVar("i")
+			c.WriteString(dst + " = make([]int8, len(" + src + "))\n")
+			c.WriteString("for " + i + " := range " + src + " {\n" + dst + "[" + i + "] = int8(" + src + "[" + i + "])\n}\n")
+			return
+		}
+		i := gen.newVar("i")
+		c.WriteString(dst + " = make(" + gen.tarsType(ty) + ", len(" + src + "))\n")
+		c.WriteString("for " + i + " := range " + src + " {\n")
+		gen.genElemFromProto(ty.TypeK, dst+"["+i+"]", src+"["+i+"]")
+		c.WriteString("}\n")
+	case tkTArray:
+		i := gen.newVar("i")
+		c.WriteString("for " + i + " := 0; " + i + " < len(" + dst + ") && " + i + " < len(" + src + "); " + i + "++ {\n")
+		gen.genElemFromProto(ty.TypeK, dst+"["+i+"]", src+"["+i+"]")
+		c.WriteString("}\n")
+	case tkTMap:
+		k, v, e := gen.newVar("k"), gen.newVar("v"), gen.newVar("e")
+		c.WriteString(dst + " = make(" + gen.tarsType(ty) + ", len(" + src + "))\n")
+		c.WriteString("for " + k + ", " + v + " := range " + src + " {\n")
+		c.WriteString("var " + e + " " + gen.tarsType(ty.TypeV) + "\n")
+		gen.genElemFromProto(ty.TypeV, e, v)
+		c.WriteString(dst + "[" + gen.tarsType(ty.TypeK) + "(" + k + ")] = " + e + "\n}\n")
+	case tkName:
+		if ty.CType == tkStruct {
+			c.WriteString(dst + ".FromProto(" + src + ")\n")
+		} else {
+			c.WriteString(dst + " = " + gen.tarsType(ty) + "(" + src + ")\n")
+		}
+	default:
+		c.WriteString(dst + " = " + gen.tarsType(ty) + "(" + src + ")\n")
+	}
+}
+
+func (gen *GenProto) genElemFromProto(ty *VarType, dst string, src string) {
+	if needWrap(ty) {
+		src += ".GetValue()"
+	}
+	gen.genFromProto(ty, dst, src)
+}
+
+// protoModules collects the modules of the custom types used by the structs.
+func (gen *GenProto) protoModules(ty *VarType, modules map[string]bool) {
+	if ty == nil {
+		return
+	}
+	if ty.Type == tkName {
+		module, _ := gen.splitTypeName(ty)
+		modules[module] = true
+	}
+	gen.protoModules(ty.TypeK, modules)
+	gen.protoModules(ty.TypeV, modules)
+}
+
+func (gen *GenProto) genConverters() {
+	c := &gen.conv
+	modules := map[string]bool{gen.p.Module: true}
+	for _, st := range gen.p.Struct {
+		for _, mb := range st.Mb {
+			gen.protoModules(mb.Type, modules)
+		}
+	}
+
+	c.WriteString(`// Package ` + gen.p.Module + ` comment
+// This file was generated by tars2go ` + VERSION + `
+// Generated from ` + gen.ProtoName + `.tars
+package ` + gen.p.Module + `
+
+import (
+`)
+	var names []string
+	for module := range modules {
+		names = append(names, module)
+	}
+	sort.Strings(names)
+	for _, module := range names {
+		c.WriteString(pbAlias(module) + " \"" + *gProtoGoPackage + "/" + module + "\"\n")
+	}
+	c.WriteString(")\n")
+
+	for _, st := range gen.p.Struct {
+		gen.vc = 0
+		gen.owner = st.Name
+		pbName := pbAlias(gen.p.Module) + "." + st.Name
+		c.WriteString(`
+// ToProto converts st to the protobuf message.
+func (st *` + st.Name + `) ToProto() *` + pbName + ` {
+	if st == nil {
+		return nil
+	}
+	p := new(` + pbName + `)
+`)
+		for _, mb := range st.Mb {
+			gen.genToProto(mb.Type, "p."+goCamelCase(mb.OriginKey), "st."+mb.Key)
+		}
+		c.WriteString(`	return p
+}
+
+// FromProto sets st from the protobuf message.
+func (st *` + st.Name + `) FromProto(p *` + pbName + `) {
+	*st = ` + st.Name + `{}
+	st.ResetDefault()
+	if p == nil {
+		return
+	}
+`)
+		for _, mb := range st.Mb {
+			gen.genFromProto(mb.Type, "st."+mb.Key, "p."+goCamelCase(mb.OriginKey))
+		}
+		c.WriteString("}\n")
+	}
+
+	beauty, err := format.Source(c.Bytes())
+	if err != nil {
+		gen.genErr("go fmt fail. " + gen.ProtoName + " " + err.Error())
+	}
+	mkPath := gen.prefix + gen.p.Module
+	if *gModuleCycle == true {
+		mkPath = gen.prefix + gen.ProtoName + "/" + gen.p.Module
+	}
+	if err = os.MkdirAll(mkPath, 0766); err != nil {
+		gen.genErr(err.Error())
+	}
+	if err = ioutil.WriteFile(mkPath+"/"+gen.ProtoName+"_proto.tars.go", beauty, 0666); err != nil {
+		gen.genErr(err.Error())
+	}
+}
+
+// goCamelCase returns the go field name protoc-gen-go generates for the proto field name.
+func goCamelCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_' && i == 0:
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && 'a' <= s[i+1] && s[i+1] <= 'z':
+			// skip the underscore, the next letter is upper cased
+		case '0' <= c && c <= '9':
+			b = append(b, c)
+		default:
+			if 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && 'a' <= s[i+1] && s[i+1] <= 'z'; i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
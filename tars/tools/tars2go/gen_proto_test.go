@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGenProto tests the .proto file and the converters of -proto with -proto-go-package, by the golden files
+// and by the tests of testdata/proto/proto_test.go.in run on the generated code.
+func TestGenProto(t *testing.T) {
+	defer setFlags(map[*bool]bool{gProto: true})()
+	defer func(pkg string) { *gProtoGoPackage = pkg }(*gProtoGoPackage)
+	*gProtoGoPackage = "gentest/pb"
+
+	dir := genGo(t, "testdata/proto/Proto.tars")
+	defer os.RemoveAll(dir)
+	gProtoFileMap = make(map[string]bool)
+	NewGenProto("testdata/proto/Proto.tars", dir).Gen()
+
+	checkGolden(t, filepath.Join(dir, "Proto.proto"), "testdata/proto/Proto.proto.golden")
+	checkGolden(t, filepath.Join(dir, "Test", "Proto_proto.tars.go"), "testdata/proto/Proto_proto.tars.go.golden")
+
+	for src, dst := range map[string]string{
+		"testdata/proto/pb.go.in":         filepath.Join(dir, "pb", "Test", "Proto.pb.go"),
+		"testdata/proto/proto_test.go.in": filepath.Join(dir, "Test", "proto_test.go"),
+	} {
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(dst, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	goTest(t, dir, "./Test")
+}
+
+// TestGenProtoWithoutPackage tests only the .proto file is generated without -proto-go-package.
+func TestGenProtoWithoutPackage(t *testing.T) {
+	defer setFlags(map[*bool]bool{gProto: true})()
+	dir, err := ioutil.TempDir("", "tars2go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gProtoFileMap = make(map[string]bool)
+	NewGenProto("testdata/proto/Proto.tars", dir).Gen()
+
+	if _, err := os.Stat(filepath.Join(dir, "Proto.proto")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Test")); !os.IsNotExist(err) {
+		t.Fatalf("converters are generated without -proto-go-package: %v", err)
+	}
+}
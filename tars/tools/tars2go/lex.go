@@ -64,6 +64,7 @@ const (
 	tkString
 	tkInteger
 	tkFloat
+	tkComment // only returned if the comments are kept
 )
 
 //TokenMap record token  value.
@@ -116,6 +117,7 @@ var TokenMap = [...]string{
 	tkString:  "<string>",
 	tkInteger: "<INTEGER>",
 	tkFloat:   "<FLOAT>",
+	tkComment: "<comment>",
 }
 
 //SemInfo is struct.
@@ -127,15 +129,21 @@ type SemInfo struct {
 
 //Token record token information.
 type Token struct {
-	T    TK
-	S    *SemInfo
-	Line int
+	T           TK
+	S           *SemInfo
+	Line        int
+	Pos         Pos    // where the token starts
+	End         Pos    // where the token ends, exclusive
+	Comment     string // comment right before the token
+	LineComment string // comment following the token on the same line
 }
 
 //LexState record lexical state.
 type LexState struct {
 	current    byte
 	linenumber int
+	column     int // column of current
+	tokPos     Pos // where the token being read starts
 
 	//t         Token
 	//lookahead Token
@@ -143,6 +151,12 @@ type LexState struct {
 	tokenBuff bytes.Buffer
 	buff      *bytes.Buffer
 
+	// comments read since the last token, and the line the last one ends
+	comments    []string
+	commentLine int
+	// return the comments as tokens with the comment marks, used by the formatter
+	keepComments bool
+
 	source string
 }
 
@@ -176,8 +190,11 @@ func isNumberType(t TK) bool {
 }
 
 func (ls *LexState) lexErr(err string) {
-	line := strconv.Itoa(ls.linenumber)
-	panic(ls.source + ": " + line + ".    " + err)
+	ls.lexErrAt(Pos{Line: ls.linenumber, Col: ls.column}, err)
+}
+
+func (ls *LexState) lexErrAt(pos Pos, err string) {
+	panic(&Diagnostic{Source: ls.source, Pos: pos, End: Pos{Line: pos.Line, Col: pos.Col + 1}, Severity: diagError, Msg: err})
 }
 
 func (ls *LexState) incLine() {
@@ -187,6 +204,7 @@ func (ls *LexState) incLine() {
 		ls.next() /* skip '\n\r' or '\r\n' */
 	}
 	ls.linenumber++
+	ls.column = 1
 }
 
 func (ls *LexState) readNumber() (TK, *SemInfo) {
@@ -288,34 +306,88 @@ func (ls *LexState) readString() (TK, *SemInfo) {
 	return tkString, sem
 }
 
-func (ls *LexState) readLongComment() {
+func (ls *LexState) readLongComment() string {
+	var text bytes.Buffer
 	for {
 		switch ls.current {
 		case EOS:
 			ls.lexErr("respect */")
-			return
+			return text.String()
 		case '\n', '\r':
 			ls.incLine()
+			text.WriteByte('\n')
 		case '*':
 			ls.next()
 			if ls.current == EOS {
-				return
+				return text.String()
 			} else if ls.current == '/' {
 				ls.next()
-				return
+				return text.String()
 			}
+			text.WriteByte('*')
 		default:
+			text.WriteByte(ls.current)
 			ls.next()
 		}
 	}
 }
 
+func (ls *LexState) readLineComment() string {
+	var text bytes.Buffer
+	for !isNewLine(ls.current) && ls.current != EOS {
+		text.WriteByte(ls.current)
+		ls.next()
+	}
+	return text.String()
+}
+
+// readRawComment reads the comment after '/', with the comment marks.
+func (ls *LexState) readRawComment() string {
+	ls.next()
+	if ls.current == '/' {
+		ls.next()
+		return "//" + ls.readLineComment()
+	} else if ls.current == '*' {
+		ls.next()
+		return "/*" + ls.readLongComment() + "*/"
+	}
+	ls.lexErr("lexical error，/")
+	return ""
+}
+
+// readComment reads the comment after '/', the text is returned without the comment marks.
+func (ls *LexState) readComment() string {
+	raw := ls.readRawComment()
+	if strings.HasPrefix(raw, "//") {
+		return cleanComment(raw[2:])
+	}
+	return cleanComment(strings.TrimSuffix(raw[2:], "*/"))
+}
+
+// cleanComment trims the leading '*' of every line of a block comment and the blank lines around.
+func cleanComment(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "*/")
+		lines[i] = strings.TrimSpace(line)
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (ls *LexState) next() {
 	var err error
 	ls.current, err = ls.buff.ReadByte()
 	if err != nil {
 		ls.current = EOS
 	}
+	ls.column++
 }
 
 func (ls *LexState) llexDefault() (TK, *SemInfo) {
@@ -325,7 +397,10 @@ func (ls *LexState) llexDefault() (TK, *SemInfo) {
 	case isLetter(ls.current):
 		return ls.readIdent()
 	default:
-		ls.lexErr("unrecognized characters, " + string(ls.current))
+		pos, c := Pos{Line: ls.linenumber, Col: ls.column}, ls.current
+		// skip it, so the lexer can go on after the error
+		ls.next()
+		ls.lexErrAt(pos, "unrecognized characters, "+string(c))
 		return '0', nil
 	}
 }
@@ -334,6 +409,7 @@ func (ls *LexState) llexDefault() (TK, *SemInfo) {
 func (ls *LexState) llex() (TK, *SemInfo) {
 	for {
 		ls.tokenBuff.Reset()
+		ls.tokPos = Pos{Line: ls.linenumber, Col: ls.column}
 		switch ls.current {
 		case EOS:
 			return tkEos, nil
@@ -342,17 +418,19 @@ func (ls *LexState) llex() (TK, *SemInfo) {
 		case '\n', '\r':
 			ls.incLine()
 		case '/': // Comment processing
-			ls.next()
-			if ls.current == '/' {
-				for !isNewLine(ls.current) && ls.current != EOS {
-					ls.next()
-				}
-			} else if ls.current == '*' {
-				ls.next()
-				ls.readLongComment()
-			} else {
-				ls.lexErr("lexical error，/")
+			if ls.keepComments {
+				return tkComment, &SemInfo{S: ls.readRawComment()}
+			}
+			line := ls.linenumber
+			text := ls.readComment()
+			// a blank line detaches the comments before from the next token
+			if len(ls.comments) > 0 && line > ls.commentLine+1 {
+				ls.comments = ls.comments[:0]
 			}
+			if text != "" {
+				ls.comments = append(ls.comments, text)
+			}
+			ls.commentLine = ls.linenumber
 		case '{':
 			ls.next()
 			return tkBracel, nil
@@ -400,11 +478,30 @@ func (ls *LexState) llex() (TK, *SemInfo) {
 //NextToken return token after lexical analysis.
 func (ls *LexState) NextToken() *Token {
 	tk := &Token{}
+	ls.comments = ls.comments[:0]
 	tk.T, tk.S = ls.llex()
 	tk.Line = ls.linenumber
+	tk.Pos = ls.tokPos
+	tk.End = Pos{Line: ls.linenumber, Col: ls.column}
+	if len(ls.comments) > 0 && tk.Line <= ls.commentLine+1 {
+		tk.Comment = strings.Join(ls.comments, "\n")
+	}
+	if !ls.keepComments {
+		ls.readTrailingComment(tk)
+	}
 	return tk
 }
 
+// readTrailingComment reads the comment after the token on the same line, like "0 optional int id; // the id".
+func (ls *LexState) readTrailingComment(tk *Token) {
+	for ls.current == ' ' || ls.current == '\t' {
+		ls.next()
+	}
+	if ls.current == '/' {
+		tk.LineComment = ls.readComment()
+	}
+}
+
 //NewLexState to update LexState struct.
 func NewLexState(source string, buff []byte) *LexState {
 	return &LexState{
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// A minimal language server of the tars files for editors, it publishes the diagnostics,
+// and supports hover, go to definition across #include and completion of types.
+// Documents are synchronized in full.
+
+type lspRequest struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspDocumentParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	Position       lspPosition     `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspCompletionItem struct {
+	Label         string `json:"label"`
+	Kind          int    `json:"kind"`
+	Detail        string `json:"detail,omitempty"`
+	Documentation string `json:"documentation,omitempty"`
+	InsertText    string `json:"insertText,omitempty"`
+}
+
+// kinds of the completion items
+const (
+	lspKindKeyword = 14
+	lspKindEnum    = 13
+	lspKindStruct  = 22
+)
+
+type lspDoc struct {
+	uri  string
+	path string
+	text string
+	p    *Parse
+}
+
+type lspServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*lspDoc // by path
+	shutdown bool
+}
+
+// runLSP serves the language server protocol until exit, it returns the exit code.
+func runLSP(in io.Reader, out io.Writer) int {
+	// the parser must not print the progress to stdout, which is used by the protocol
+	gQuiet = true
+
+	s := &lspServer{in: bufio.NewReader(in), out: out, docs: make(map[string]*lspDoc)}
+	for {
+		req, err := s.read()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(os.Stderr, "read request failed:", err)
+			}
+			return 1
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		s.handle(req)
+	}
+}
+
+func (s *lspServer) read() (*lspRequest, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if pos := strings.Index(line, ":"); pos > 0 && strings.EqualFold(line[:pos], "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[pos+1:])); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("no Content-Length")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	req := &lspRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (s *lspServer) write(msg map[string]interface{}) {
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "marshal response failed:", err)
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *lspServer) reply(req *lspRequest, result interface{}, err *lspError) {
+	if req.ID == nil {
+		return
+	}
+	if err != nil {
+		s.write(map[string]interface{}{"id": req.ID, "error": err})
+	} else {
+		s.write(map[string]interface{}{"id": req.ID, "result": result})
+	}
+}
+
+func (s *lspServer) notify(method string, params interface{}) {
+	s.write(map[string]interface{}{"method": method, "params": params})
+}
+
+func (s *lspServer) handle(req *lspRequest) {
+	var params lspDocumentParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.reply(req, nil, &lspError{Code: -32602, Message: err.Error()})
+			return
+		}
+	}
+
+	switch req.Method {
+	case "initialize":
+		s.reply(req, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1,
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{":", "<"}},
+			},
+			"serverInfo": map[string]string{"name": "tars2go", "version": VERSION},
+		}, nil)
+	case "shutdown":
+		s.shutdown = true
+		s.reply(req, nil, nil)
+	case "textDocument/didOpen":
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+	case "textDocument/didSave":
+		// the included files may be changed
+		s.reparse()
+	case "textDocument/didClose":
+		path := uriToPath(params.TextDocument.URI)
+		delete(s.docs, path)
+		delete(gSourceOverlay, path)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri": params.TextDocument.URI, "diagnostics": []lspDiagnostic{}})
+	case "textDocument/hover":
+		s.reply(req, s.hover(&params), nil)
+	case "textDocument/definition":
+		s.reply(req, s.definition(&params), nil)
+	case "textDocument/completion":
+		s.reply(req, s.completion(&params), nil)
+	default:
+		// notifications not supported are ignored
+		s.reply(req, nil, &lspError{Code: -32601, Message: "method not found: " + req.Method})
+	}
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func (s *lspServer) update(uri string, text string) {
+	path := uriToPath(uri)
+	s.docs[path] = &lspDoc{uri: uri, path: path, text: text}
+	gSourceOverlay[path] = []byte(text)
+	// the other documents may include it
+	s.reparse()
+}
+
+func (s *lspServer) reparse() {
+	for _, doc := range s.docs {
+		doc.p = parseSafely(doc.path)
+		s.publish(doc)
+	}
+}
+
+// parseSafely parses the file, a panic of the parser is reported as diagnostic.
+func parseSafely(path string) (p *Parse) {
+	defer func() {
+		if err := recover(); err != nil {
+			p = &Parse{Source: path}
+			p.Diags = append(p.Diags, Diagnostic{Source: path, Pos: Pos{Line: 1, Col: 1}, End: Pos{Line: 1, Col: 1},
+				Severity: diagError, Msg: fmt.Sprint(err)})
+		}
+	}()
+	return parseFile(path, make([]string, 0))
+}
+
+func (s *lspServer) publish(doc *lspDoc) {
+	diags := []lspDiagnostic{}
+	for _, d := range doc.p.Diags {
+		diags = append(diags, lspDiagnostic{Range: s.lspRange(d.Source, d.Pos, d.End), Severity: d.Severity,
+			Source: "tars2go", Message: d.Msg})
+	}
+	// errors of the included files are shown at the #include
+	for i, inc := range doc.p.Include {
+		path := doc.p.resolveInclude(inc)
+		for _, pInc := range doc.p.IncParse {
+			if pInc.Source != path {
+				continue
+			}
+			if incDiags := pInc.AllDiags(); len(incDiags) > 0 {
+				t := doc.p.incPos[i]
+				diags = append(diags, lspDiagnostic{Range: s.lspRange(doc.path, t.Pos, t.End), Severity: diagError,
+					Source: "tars2go", Message: "error in included file: " + incDiags[0].Error()})
+			}
+		}
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": doc.uri, "diagnostics": diags})
+}
+
+// === positions ===
+
+// sourceLine returns the line of the file, starting from 1.
+func sourceLine(path string, line int) string {
+	b, err := readSource(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(b), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// lspPos converts the position to the protocol, which counts the characters in utf-16 from 0.
+func (s *lspServer) lspPos(path string, pos Pos) lspPosition {
+	line := sourceLine(path, pos.Line)
+	col := pos.Col - 1
+	if col > len(line) {
+		col = len(line)
+	}
+	if col < 0 {
+		col = 0
+	}
+	return lspPosition{Line: pos.Line - 1, Character: len(utf16.Encode([]rune(line[:col])))}
+}
+
+func (s *lspServer) lspRange(path string, pos Pos, end Pos) lspRange {
+	return lspRange{Start: s.lspPos(path, pos), End: s.lspPos(path, end)}
+}
+
+// tarsPos converts the position from the protocol.
+func tarsPos(line string, pos lspPosition) Pos {
+	col, n := 0, 0
+	for col < len(line) && n < pos.Character {
+		r, size := utf8.DecodeRuneInString(line[col:])
+		col += size
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return Pos{Line: pos.Line + 1, Col: col + 1}
+}
+
+// === symbols ===
+
+type lspSymbol struct {
+	kind   string // struct, enum, interface, const, member, function or enum member
+	module string
+	name   string
+	p      *Parse
+	pos    Pos
+	detail string // the definition in tars
+	doc    string
+}
+
+func (sym *lspSymbol) contains(pos Pos) bool {
+	end := Pos{Line: sym.pos.Line, Col: sym.pos.Col + len(sym.name)}
+	return !pos.before(sym.pos) && pos.before(end)
+}
+
+func (sym *lspSymbol) isType() bool {
+	return sym.kind == "struct" || sym.kind == "enum"
+}
+
+// symbols returns the definitions in p and its includes.
+func symbols(p *Parse) []*lspSymbol {
+	var syms []*lspSymbol
+	seen := make(map[string]bool)
+	var walk func(p *Parse)
+	walk = func(p *Parse) {
+		if seen[p.Source] {
+			return
+		}
+		seen[p.Source] = true
+		syms = append(syms, fileSymbols(p)...)
+		for _, inc := range p.IncParse {
+			walk(inc)
+		}
+	}
+	walk(p)
+	return syms
+}
+
+func fileSymbols(p *Parse) []*lspSymbol {
+	var syms []*lspSymbol
+	add := func(kind string, name string, pos Pos, detail string, doc string) {
+		syms = append(syms, &lspSymbol{kind: kind, module: p.Module, name: name, p: p, pos: pos, detail: detail, doc: doc})
+	}
+	for _, v := range p.Const {
+		add("const", v.Name, v.Pos, "const "+docTypeName(v.Type)+" "+v.Name+" = "+v.Value+";", v.Comment)
+	}
+	for _, en := range p.Enum {
+		values := compatEnumValues(&en)
+		var body []string
+		for _, mb := range en.Mb {
+			value := strconv.Itoa(int(values[mb.Key]))
+			body = append(body, "    "+mb.Key+" = "+value)
+			add("enum member", mb.Key, mb.Pos, en.Name+"::"+mb.Key+" = "+value, mb.Comment)
+		}
+		add("enum", en.Name, en.Pos, "enum "+en.Name+" {\n"+strings.Join(body, ",\n")+"\n};", en.Comment)
+	}
+	for _, st := range p.Struct {
+		var body []string
+		for _, mb := range st.Mb {
+			line := memberDefine(&mb)
+			body = append(body, "    "+line)
+			add("member", mb.Key, mb.Pos, line, mb.Comment)
+		}
+		add("struct", st.Name, st.Pos, "struct "+st.Name+" {\n"+strings.Join(body, "\n")+"\n};", st.Comment)
+	}
+	for _, itf := range p.Interface {
+		var body []string
+		for _, fun := range itf.Fun {
+			sig := docSignature(&fun)
+			body = append(body, "    "+sig)
+			add("function", fun.Name, fun.Pos, sig, fun.Comment)
+		}
+		add("interface", itf.Name, itf.Pos, "interface "+itf.Name+" {\n"+strings.Join(body, "\n")+"\n};", itf.Comment)
+	}
+	return syms
+}
+
+func memberDefine(mb *StructMember) string {
+	require := "optional"
+	if mb.Require {
+		require = "require"
+	}
+	line := strconv.Itoa(int(mb.Tag)) + " " + require + " " + docTypeName(mb.Type) + " " + mb.Key
+	// defaults of enum are converted to go names, leave them out
+	if mb.Default != "" && mb.DefType != tkName {
+		line += " = " + mb.Default
+	}
+	return line + ";"
+}
+
+// typeRefs returns the custom types used in p.
+func typeRefs(p *Parse) []*VarType {
+	var refs []*VarType
+	var walk func(ty *VarType)
+	walk = func(ty *VarType) {
+		if ty == nil {
+			return
+		}
+		if ty.Type == tkName {
+			refs = append(refs, ty)
+		}
+		walk(ty.TypeK)
+		walk(ty.TypeV)
+	}
+	for _, st := range p.Struct {
+		for _, mb := range st.Mb {
+			walk(mb.Type)
+		}
+	}
+	for _, itf := range p.Interface {
+		for _, fun := range itf.Fun {
+			walk(fun.RetType)
+			for _, arg := range fun.Args {
+				walk(arg.Type)
+			}
+		}
+	}
+	return refs
+}
+
+func findSymbol(syms []*lspSymbol, module string, name string, match func(*lspSymbol) bool) *lspSymbol {
+	for _, sym := range syms {
+		if sym.module == module && sym.name == name && match(sym) {
+			return sym
+		}
+	}
+	return nil
+}
+
+// wordAt returns the identifier at the column of the line, with the module qualifier.
+func wordAt(line string, col int) string {
+	isWord := func(b byte) bool { return isLetter(b) || (b >= '0' && b <= '9') || b == ':' }
+	begin, end := col-1, col-1
+	if begin > len(line) {
+		return ""
+	}
+	for begin > 0 && isWord(line[begin-1]) {
+		begin--
+	}
+	for end < len(line) && isWord(line[end]) {
+		end++
+	}
+	return line[begin:end]
+}
+
+// symbolAt returns the symbol used or defined at the position of the document.
+func (s *lspServer) symbolAt(params *lspDocumentParams) (*lspSymbol, *lspDoc) {
+	doc, ok := s.docs[uriToPath(params.TextDocument.URI)]
+	if !ok || doc.p == nil {
+		return nil, nil
+	}
+	lines := strings.Split(doc.text, "\n")
+	if params.Position.Line >= len(lines) {
+		return nil, doc
+	}
+	line := strings.TrimRight(lines[params.Position.Line], "\r")
+	pos := tarsPos(line, params.Position)
+	syms := symbols(doc.p)
+
+	for _, ty := range typeRefs(doc.p) {
+		if !pos.before(ty.Pos) && pos.before(ty.End) {
+			module, name := splitDocType(doc.p, ty)
+			return findSymbol(syms, module, name, (*lspSymbol).isType), doc
+		}
+	}
+	for _, sym := range syms {
+		if sym.p == doc.p && sym.contains(pos) {
+			return sym, doc
+		}
+	}
+
+	// such as the enum member as default value
+	module, name := splitDocType(doc.p, &VarType{TypeSt: wordAt(line, pos.Col)})
+	if name == "" {
+		return nil, doc
+	}
+	if sym := findSymbol(syms, module, name, func(sym *lspSymbol) bool { return sym.kind != "member" && sym.kind != "function" }); sym != nil {
+		return sym, doc
+	}
+	return nil, doc
+}
+
+func (s *lspServer) hover(params *lspDocumentParams) interface{} {
+	sym, _ := s.symbolAt(params)
+	if sym == nil {
+		return nil
+	}
+	value := "```tars\n" + sym.detail + "\n```"
+	if sym.doc != "" {
+		value += "\n\n" + sym.doc
+	}
+	return map[string]interface{}{"contents": map[string]string{"kind": "markdown", "value": value}}
+}
+
+func (s *lspServer) definition(params *lspDocumentParams) interface{} {
+	sym, _ := s.symbolAt(params)
+	if sym == nil {
+		return nil
+	}
+	end := Pos{Line: sym.pos.Line, Col: sym.pos.Col + len(sym.name)}
+	return lspLocation{URI: pathToURI(sym.p.Source), Range: s.lspRange(sym.p.Source, sym.pos, end)}
+}
+
+// builtinTypes are completed besides the structs and enums.
+var builtinTypes = []string{"bool", "byte", "short", "int", "long", "float", "double", "string", "vector", "map", "unsigned", "void"}
+
+func (s *lspServer) completion(params *lspDocumentParams) interface{} {
+	items := []lspCompletionItem{}
+	doc, ok := s.docs[uriToPath(params.TextDocument.URI)]
+	if !ok || doc.p == nil {
+		return items
+	}
+
+	// after Module:: only the types of the module are completed, without the qualifier
+	qualifier := ""
+	lines := strings.Split(doc.text, "\n")
+	if params.Position.Line < len(lines) {
+		line := strings.TrimRight(lines[params.Position.Line], "\r")
+		pos := tarsPos(line, params.Position)
+		word := line[:pos.Col-1]
+		word = word[strings.LastIndexAny(word, " \t<,(")+1:]
+		if i := strings.Index(word, "::"); i >= 0 {
+			qualifier = word[:i]
+		}
+	}
+
+	if qualifier == "" {
+		for _, name := range builtinTypes {
+			items = append(items, lspCompletionItem{Label: name, Kind: lspKindKeyword})
+		}
+	}
+	for _, sym := range symbols(doc.p) {
+		if !sym.isType() {
+			continue
+		}
+		item := lspCompletionItem{Label: sym.name, Kind: lspKindStruct, Detail: sym.kind + " " + sym.module + "::" + sym.name,
+			Documentation: sym.doc}
+		if sym.kind == "enum" {
+			item.Kind = lspKindEnum
+		}
+		switch {
+		case qualifier != "":
+			if sym.module != qualifier {
+				continue
+			}
+		case sym.module != doc.p.Module:
+			item.Label = sym.module + "::" + sym.name
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lspSession builds the requests to the server.
+type lspSession struct {
+	in bytes.Buffer
+	id int
+}
+
+func (ss *lspSession) send(id *int, method string, params interface{}) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if id != nil {
+		msg["id"] = *id
+	}
+	if params != nil {
+		msg["params"] = params
+	}
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(&ss.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// request sends the request, and returns its id.
+func (ss *lspSession) request(method string, params interface{}) int {
+	ss.id++
+	id := ss.id
+	ss.send(&id, method, params)
+	return id
+}
+
+func (ss *lspSession) notify(method string, params interface{}) {
+	ss.send(nil, method, params)
+}
+
+func textDocument(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{"textDocument": map[string]string{"uri": uri},
+		"position": map[string]int{"line": line, "character": character}}
+}
+
+// readLSP reads the messages written by the server as json values.
+func readLSP(t *testing.T, out *bytes.Buffer) []interface{} {
+	t.Helper()
+	r := bufio.NewReader(out)
+	var msgs []interface{}
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF && header == "" {
+			return msgs
+		}
+		var length int
+		if _, err := fmt.Sscanf(header, "Content-Length: %d\r\n", &length); err != nil {
+			t.Fatalf("header %q: %v", header, err)
+		}
+		if line, err := r.ReadString('\n'); err != nil || line != "\r\n" {
+			t.Fatalf("end of the header %q: %v", line, err)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			t.Fatal(err)
+		}
+		var msg interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// checkLSP compares the messages of the server with the json of the wanted ones.
+func checkLSP(t *testing.T, got []interface{}, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		var w interface{}
+		if err := json.Unmarshal([]byte(want[i]), &w); err != nil {
+			t.Fatalf("want %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got[i], w) {
+			g, _ := json.Marshal(got[i])
+			t.Errorf("message %d:\ngot  %s\nwant %s", i, g, want[i])
+		}
+	}
+}
+
+const lspTypes = `module Types
+{
+	// Point is a point.
+	struct Point
+	{
+		0 require int x;
+		1 optional int y;
+	};
+
+	enum Color
+	{
+		RED,
+		GREEN
+	};
+};
+`
+
+const lspMain = `#include "Types.tars"
+module Main
+{
+	struct Shape
+	{
+		0 require Types::Point origin;
+		1 optional Types::Color color;
+		2 optional Unknown u;
+	};
+};
+`
+
+// TestLSP tests a session of an editor: initialize, didOpen, hover, definition and completion.
+func TestLSP(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"Types.tars": lspTypes})
+	defer os.RemoveAll(dir)
+	defer func(quiet bool) { gQuiet = quiet }(gQuiet)
+
+	uri := pathToURI(filepath.Join(dir, "Main.tars"))
+	ss := &lspSession{}
+	ss.request("initialize", map[string]interface{}{"processId": nil, "rootUri": pathToURI(dir)})
+	ss.notify("initialized", map[string]interface{}{})
+	ss.notify("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{
+		"uri": uri, "languageId": "tars", "version": 1, "text": lspMain}})
+	ss.request("textDocument/hover", textDocument(uri, 5, 21))
+	ss.request("textDocument/definition", textDocument(uri, 5, 21))
+	ss.request("textDocument/completion", textDocument(uri, 6, 20))
+	ss.request("textDocument/completion", textDocument(uri, 7, 13))
+	ss.notify("textDocument/didChange", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": strings.Replace(lspMain, "Unknown", "Types::Point", 1)}}})
+	ss.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+	ss.request("shutdown", nil)
+	ss.notify("exit", nil)
+
+	var out bytes.Buffer
+	if code := runLSP(&ss.in, &out); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+
+	typesURI := pathToURI(filepath.Join(dir, "Types.tars"))
+	builtins := ""
+	for _, name := range []string{"bool", "byte", "double", "float", "int", "long", "map", "short", "string",
+		"unsigned", "vector", "void"} {
+		builtins += `,{"label":"` + name + `","kind":14}`
+	}
+	checkLSP(t, readLSP(t, &out), []string{
+		`{"id":1,"jsonrpc":"2.0","result":{"capabilities":{"completionProvider":{"triggerCharacters":[":","<"]},` +
+			`"definitionProvider":true,"hoverProvider":true,"textDocumentSync":1},` +
+			`"serverInfo":{"name":"tars2go","version":"` + VERSION + `"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"` + uri + `","diagnostics":[` +
+			`{"range":{"start":{"line":7,"character":13},"end":{"line":7,"character":20}},"severity":1,` +
+			`"source":"tars2go","message":"Unknown not find define"}]}}`,
+		`{"id":2,"jsonrpc":"2.0","result":{"contents":{"kind":"markdown",` +
+			`"value":"` + "```tars\\nstruct Point {\\n    0 require int x;\\n    1 optional int y;\\n};\\n```\\n\\nPoint is a point." + `"}}}`,
+		`{"id":3,"jsonrpc":"2.0","result":{"uri":"` + typesURI + `",` +
+			`"range":{"start":{"line":3,"character":8},"end":{"line":3,"character":13}}}}`,
+		`{"id":4,"jsonrpc":"2.0","result":[{"label":"Color","kind":13,"detail":"enum Types::Color"},` +
+			`{"label":"Point","kind":22,"detail":"struct Types::Point","documentation":"Point is a point."}]}`,
+		`{"id":5,"jsonrpc":"2.0","result":[{"label":"Shape","kind":22,"detail":"struct Main::Shape"},` +
+			`{"label":"Types::Color","kind":13,"detail":"enum Types::Color"},` +
+			`{"label":"Types::Point","kind":22,"detail":"struct Types::Point","documentation":"Point is a point."}` +
+			builtins + `]}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"` + uri + `","diagnostics":[]}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"` + uri + `","diagnostics":[]}}`,
+		`{"id":6,"jsonrpc":"2.0","result":null}`,
+	})
+	if len(gSourceOverlay) != 0 {
+		t.Fatalf("closed document is left: %v", gSourceOverlay)
+	}
+}
+
+// TestLSPErrors tests the unknown requests, the positions out of the document and the exit without shutdown.
+func TestLSPErrors(t *testing.T) {
+	defer func(quiet bool) { gQuiet = quiet }(gQuiet)
+
+	ss := &lspSession{}
+	ss.request("workspace/symbol", map[string]string{"query": "A"})
+	ss.request("textDocument/hover", textDocument("file:///not/opened.tars", 0, 0))
+	ss.request("textDocument/completion", textDocument("file:///not/opened.tars", 0, 0))
+	ss.notify("exit", nil)
+
+	var out bytes.Buffer
+	if code := runLSP(&ss.in, &out); code != 1 {
+		t.Fatalf("exit without shutdown: got %d, want 1", code)
+	}
+	checkLSP(t, readLSP(t, &out), []string{
+		`{"id":1,"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found: workspace/symbol"}}`,
+		`{"id":2,"jsonrpc":"2.0","result":null}`,
+		`{"id":3,"jsonrpc":"2.0","result":[]}`,
+	})
+}
@@ -26,6 +26,16 @@ var gImports importPath
 var gTarsPath string
 var gOutdir string
 var gModule string
+var gCompat string
+var gCheck bool
+var gLSP bool
+var gScaffold string
+var gApp string
+var gServer string
+var gServant string
+
+// gQuiet stops printing the parsed files, for the modes whose output is the result.
+var gQuiet bool
 
 func printhelp() {
 	bin := os.Args[0]
@@ -34,26 +44,83 @@ func printhelp() {
 	}
 	fmt.Printf("Usage: %s [flags] *.tars\n", bin)
 	fmt.Printf("       %s -I tars/protocol/res/endpoint [-I ...] QueryF.tars\n", bin)
+	fmt.Printf("       %s -compat old/Hello.tars Hello.tars\n", bin)
+	fmt.Printf("       %s -doc markdown,openapi -outdir doc Hello.tars\n", bin)
+	fmt.Printf("       %s -proto [-proto-go-package github.com/TestApp/pb] Hello.tars\n", bin)
+	fmt.Printf("       %s -check *.tars\n", bin)
+	fmt.Printf("       %s -lsp\n", bin)
+	fmt.Printf("       %s -fmt [-w|-d|-l] [path ...]\n", bin)
+	fmt.Printf("       %s -scaffold HelloServer -app TestApp -module github.com/TestApp/HelloServer Hello.tars\n", bin)
+	fmt.Printf("The ToProto and FromProto converters of -proto are generated only with -proto-go-package.\n")
 	flag.PrintDefaults()
 }
 
 func main() {
+	if isFmtCommand(os.Args) {
+		os.Exit(fmtMain(fmtArgs(os.Args)))
+	}
+
 	flag.Usage = printhelp
 	flag.Var(&gImports, "I", "Specify a specific import path")
 	flag.StringVar(&gTarsPath, "tarsPath", "github.com/MacgradyHuang/TarsGo/tars", "Specify the tars source path.")
 	flag.StringVar(&gOutdir, "outdir", "", "which dir to put generated code")
 	flag.StringVar(&gModule, "module", "", "current go module path")
+	flag.StringVar(&gCompat, "compat", "", "check the tars file is compatible with this old version instead of generating code, exit 1 on breaking changes")
+	flag.BoolVar(&gCheck, "check", false, "report all the errors in the tars files instead of generating code")
+	flag.BoolVar(&gLSP, "lsp", false, "run as a language server on stdin and stdout for editors")
+	flag.StringVar(&gScaffold, "scaffold", "", "create a server in this dir from the tars files: main, servant stubs, config, client example and Makefile")
+	flag.StringVar(&gApp, "app", "", "app name of the server created by -scaffold")
+	flag.StringVar(&gServer, "server", "", "server name of the server created by -scaffold, the name of the dir by default")
+	flag.StringVar(&gServant, "servant", "", "create a sample tars file with this interface if -scaffold has no tars file")
 	flag.Parse()
+	gQuiet = gLSP || gCheck || gCompat != "" || *gDoc != ""
+
+	if gLSP {
+		os.Exit(runLSP(os.Stdin, os.Stdout))
+	}
+
+	if gScaffold != "" {
+		s := NewScaffold(gScaffold, gApp, gServer, gModule, flag.Args())
+		s.I = gImports
+		s.tarsPath = gTarsPath
+		s.servant = gServant
+		s.Gen()
+		return
+	}
 
 	if flag.NArg() == 0 {
 		printhelp()
 		os.Exit(0)
 	}
 
+	if gCheck {
+		os.Exit(checkFiles(flag.Args()))
+	}
+
+	if gCompat != "" {
+		if flag.NArg() != 1 {
+			printhelp()
+			os.Exit(compatError)
+		}
+		os.Exit(checkCompat(gCompat, flag.Arg(0)))
+	}
+
+	if *gDoc != "" {
+		formats := strings.Split(*gDoc, ",")
+		for _, filename := range flag.Args() {
+			NewGenDoc(filename, gOutdir, formats).Gen()
+		}
+		return
+	}
+
 	for _, filename := range flag.Args() {
 		gen := NewGenGo(filename, gModule, gOutdir)
 		gen.I = gImports
 		gen.tarsPath = gTarsPath
 		gen.Gen()
+
+		if *gProto {
+			NewGenProto(filename, gOutdir).Gen()
+		}
 	}
 }
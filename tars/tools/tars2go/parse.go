@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,10 +19,13 @@ type VarType struct {
 	TypeK    *VarType // vector's member variable,the key of map
 	TypeV    *VarType // the value of map
 	TypeL    int64    // lenth of array
+	Pos      Pos      // where the type is written
+	End      Pos
 }
 
 // StructMember member struct.
 type StructMember struct {
+	Pos       Pos // position of the key
 	Tag       int32
 	Require   bool
 	Type      *VarType
@@ -28,6 +33,7 @@ type StructMember struct {
 	OriginKey string // original key
 	Default   string
 	DefType   TK
+	Comment   string
 }
 
 // StructMemberSorter When serializing, make sure the tags are ordered.
@@ -37,16 +43,18 @@ func (a StructMemberSorter) Len() int           { return len(a) }
 func (a StructMemberSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a StructMemberSorter) Less(i, j int) bool { return a[i].Tag < a[j].Tag }
 
-//StructInfo record struct information.
+// StructInfo record struct information.
 type StructInfo struct {
+	Pos                 Pos // position of the name
 	Name                string
 	OriginName          string //original name
 	Mb                  []StructMember
 	DependModule        map[string]bool
 	DependModuleWithJce map[string]string
+	Comment             string
 }
 
-//ArgInfo record argument information.
+// ArgInfo record argument information.
 type ArgInfo struct {
 	Name       string
 	OriginName string //original name
@@ -54,61 +62,73 @@ type ArgInfo struct {
 	Type       *VarType
 }
 
-//FunInfo record function information.
+// FunInfo record function information.
 type FunInfo struct {
+	Pos        Pos    // position of the name
 	Name       string // after the uppercase converted name
 	OriginName string // original name
 	HasRet     bool
 	RetType    *VarType
 	Args       []ArgInfo
+	Comment    string
 }
 
-//InterfaceInfo record interface information.
+// InterfaceInfo record interface information.
 type InterfaceInfo struct {
+	Pos                 Pos // position of the name
 	Name                string
 	OriginName          string // original name
 	Fun                 []FunInfo
 	DependModule        map[string]bool
 	DependModuleWithJce map[string]string
+	Comment             string
 }
 
-//EnumMember record member information.
+// EnumMember record member information.
 type EnumMember struct {
-	Key   string
-	Type  int
-	Value int32  //type 0
-	Name  string //type 1
+	Pos       Pos // position of the key
+	Key       string
+	OriginKey string // original key
+	Type      int
+	Value     int32  //type 0
+	Name      string //type 1
+	Comment   string
 }
 
-//EnumInfo record EnumMember information include name.
+// EnumInfo record EnumMember information include name.
 type EnumInfo struct {
+	Pos        Pos // position of the name
 	Module     string
 	Name       string
 	OriginName string // original name
 	Mb         []EnumMember
+	Comment    string
 }
 
-//ConstInfo record const information.
+// ConstInfo record const information.
 type ConstInfo struct {
+	Pos        Pos // position of the name
 	Type       *VarType
 	Name       string
 	OriginName string // original name
 	Value      string
+	Comment    string
 }
 
-//HashKeyInfo record hashkey information.
+// HashKeyInfo record hashkey information.
 type HashKeyInfo struct {
 	Name   string
 	Member []string
 }
 
-//Parse record information of parse file.
+// Parse record information of parse file.
 type Parse struct {
 	Source string
 
-	Module       string
-	OriginModule string
-	Include      []string
+	Module        string
+	OriginModule  string
+	ModuleComment string
+	Include       []string
 
 	Struct    []StructInfo
 	Interface []InterfaceInfo
@@ -122,6 +142,12 @@ type Parse struct {
 	lex   *LexState
 	t     *Token
 	lastT *Token
+	depth int // depth of the braces
+
+	// errors found while parsing, the parser goes on with the next definition after an error
+	Diags []Diagnostic
+	// where the includes are written
+	incPos []*Token
 
 	// jce include chain
 	IncChain []string
@@ -133,17 +159,39 @@ type Parse struct {
 }
 
 func (p *Parse) parseErr(err string) {
-	line := "0"
-	if p.t != nil {
-		line = strconv.Itoa(p.t.Line)
+	if p.t == nil {
+		p.parseErrAt(Pos{Line: 1, Col: 1}, Pos{Line: 1, Col: 1}, err)
 	}
+	p.parseErrAt(p.t.Pos, p.t.End, err)
+}
+
+func (p *Parse) parseErrAt(pos Pos, end Pos, err string) {
+	panic(&Diagnostic{Source: p.Source, Pos: pos, End: end, Severity: diagError, Msg: err})
+}
 
-	panic(p.Source + ": " + line + ". " + err)
+// tokenComment returns the comment before lead, or else the first comment following the trail tokens.
+func tokenComment(lead *Token, trail ...*Token) string {
+	if lead.Comment != "" {
+		return lead.Comment
+	}
+	for _, t := range trail {
+		if t.LineComment != "" {
+			return t.LineComment
+		}
+	}
+	return ""
 }
 
 func (p *Parse) next() {
+	t := p.lex.NextToken()
 	p.lastT = p.t
-	p.t = p.lex.NextToken()
+	p.t = t
+	switch t.T {
+	case tkBracel:
+		p.depth++
+	case tkBracer:
+		p.depth--
+	}
 }
 
 func (p *Parse) expect(t TK) {
@@ -163,7 +211,7 @@ func (p *Parse) makeUnsigned(utype *VarType) {
 }
 
 func (p *Parse) parseType() *VarType {
-	vtype := &VarType{Type: p.t.T}
+	vtype := &VarType{Type: p.t.T, Pos: p.t.Pos}
 
 	switch vtype.Type {
 	case tkName:
@@ -187,17 +235,20 @@ func (p *Parse) parseType() *VarType {
 		p.next()
 		utype := p.parseType()
 		p.makeUnsigned(utype)
+		utype.Pos = vtype.Pos
 		return utype
 	default:
 		p.parseErr("expert type")
 	}
+	vtype.End = p.t.End
 	return vtype
 }
 
 func (p *Parse) parseEnum() {
-	enum := EnumInfo{}
+	enum := EnumInfo{Comment: p.t.Comment}
 	p.expect(tkName)
 	enum.Name = p.t.S.S
+	enum.Pos = p.t.Pos
 	for _, v := range p.Enum {
 		if v.Name == enum.Name {
 			p.parseErr(enum.Name + " Redefine.")
@@ -213,35 +264,43 @@ LFOR:
 			break LFOR
 		case tkName:
 			k := p.t.S.S
+			kt := p.t
 			p.next()
 			switch p.t.T {
 			case tkComma:
-				m := EnumMember{Key: k, Type: 2}
+				m := EnumMember{Pos: kt.Pos, Key: k, Type: 2, Comment: tokenComment(kt, kt, p.t)}
 				enum.Mb = append(enum.Mb, m)
 			case tkBracer:
-				m := EnumMember{Key: k, Type: 2}
+				m := EnumMember{Pos: kt.Pos, Key: k, Type: 2, Comment: tokenComment(kt, kt)}
 				enum.Mb = append(enum.Mb, m)
 				break LFOR
 			case tkEq:
 				p.next()
+				vt := p.t
 				switch p.t.T {
 				case tkInteger:
-					m := EnumMember{Key: k, Value: int32(p.t.S.I)}
+					m := EnumMember{Pos: kt.Pos, Key: k, Value: int32(p.t.S.I)}
 					enum.Mb = append(enum.Mb, m)
 				case tkName:
-					m := EnumMember{Key: k, Type: 1, Name: p.t.S.S}
+					m := EnumMember{Pos: kt.Pos, Key: k, Type: 1, Name: p.t.S.S}
 					enum.Mb = append(enum.Mb, m)
 				default:
 					p.parseErr("not expect " + TokenMap[p.t.T])
 				}
 				p.next()
 				if p.t.T == tkBracer {
+					enum.Mb[len(enum.Mb)-1].Comment = tokenComment(kt, vt)
 					break LFOR
 				} else if p.t.T == tkComma {
+					enum.Mb[len(enum.Mb)-1].Comment = tokenComment(kt, vt, p.t)
 				} else {
 					p.parseErr("expect , or }")
 				}
+			default:
+				p.parseErr("expect , = or }")
 			}
+		default:
+			p.parseErr("expect enum member or }")
 		}
 	}
 	p.expect(tkSemi)
@@ -295,6 +354,7 @@ func (p *Parse) parseStructMember() *StructMember {
 	}
 	m := &StructMember{}
 	m.Tag = int32(p.t.S.I)
+	tagT := p.t
 
 	// require or optional
 	p.next()
@@ -317,16 +377,20 @@ func (p *Parse) parseStructMember() *StructMember {
 	// key
 	p.expect(tkName)
 	m.Key = p.t.S.S
+	m.Pos = p.t.Pos
 
 	p.next()
 	if p.t.T == tkSemi {
+		m.Comment = tokenComment(tagT, p.t)
 		return m
 	}
 	if p.t.T == tkSquarel {
 		p.expect(tkInteger)
-		m.Type = &VarType{Type: tkTArray, TypeK: m.Type, TypeL: p.t.S.I}
+		m.Type = &VarType{Type: tkTArray, TypeK: m.Type, TypeL: p.t.S.I, Pos: m.Type.Pos}
 		p.expect(tkSquarer)
+		m.Type.End = p.t.End
 		p.expect(tkSemi)
+		m.Comment = tokenComment(tagT, p.t)
 		return m
 	}
 	if p.t.T != tkEq {
@@ -340,6 +404,7 @@ func (p *Parse) parseStructMember() *StructMember {
 	p.next()
 	p.parseStructMemberDefault(m)
 	p.expect(tkSemi)
+	m.Comment = tokenComment(tagT, p.t)
 
 	return m
 }
@@ -348,7 +413,7 @@ func (p *Parse) checkTag(st *StructInfo) {
 	set := make(map[int32]bool)
 	for _, v := range st.Mb {
 		if set[v.Tag] {
-			p.parseErr("tag = " + strconv.Itoa(int(v.Tag)) + ". have duplicates")
+			p.parseErrAt(v.Pos, Pos{Line: v.Pos.Line, Col: v.Pos.Col + len(v.Key)}, "tag = "+strconv.Itoa(int(v.Tag))+". have duplicates")
 		}
 		set[v.Tag] = true
 	}
@@ -359,18 +424,26 @@ func (p *Parse) sortTag(st *StructInfo) {
 }
 
 func (p *Parse) parseStruct() {
-	st := StructInfo{}
+	st := StructInfo{Comment: p.t.Comment}
 	p.expect(tkName)
 	st.Name = p.t.S.S
+	st.Pos = p.t.Pos
 	for _, v := range p.Struct {
 		if v.Name == st.Name {
 			p.parseErr(st.Name + " Redefine.")
 		}
 	}
 	p.expect(tkBracel)
+	depth := p.depth
 
 	for {
-		m := p.parseStructMember()
+		var m *StructMember
+		if !p.try(func() { m = p.parseStructMember() }) {
+			if p.skipTo(depth) {
+				break
+			}
+			continue
+		}
 		if m == nil {
 			break
 		}
@@ -378,7 +451,7 @@ func (p *Parse) parseStruct() {
 	}
 	p.expect(tkSemi) //semicolon at the end of the struct.
 
-	p.checkTag(&st)
+	p.try(func() { p.checkTag(&st) })
 	p.sortTag(&st)
 
 	p.Struct = append(p.Struct, st)
@@ -390,6 +463,7 @@ func (p *Parse) parseInterfaceFun() *FunInfo {
 	if p.t.T == tkBracer {
 		return nil
 	}
+	firstT := p.t
 	if p.t.T == tkVoid {
 		fun.HasRet = false
 	} else if !isType(p.t.T) && p.t.T != tkName && p.t.T != tkUnsigned {
@@ -400,6 +474,7 @@ func (p *Parse) parseInterfaceFun() *FunInfo {
 	}
 	p.expect(tkName)
 	fun.Name = p.t.S.S
+	fun.Pos = p.t.Pos
 	p.expect(tkPtl)
 
 	p.next()
@@ -410,6 +485,7 @@ func (p *Parse) parseInterfaceFun() *FunInfo {
 	// No parameter function, exit directly.
 	if p.t.T == tkPtr {
 		p.expect(tkSemi)
+		fun.Comment = tokenComment(firstT, p.t)
 		return fun
 	}
 
@@ -435,6 +511,7 @@ func (p *Parse) parseInterfaceFun() *FunInfo {
 			p.next()
 		} else if p.t.T == tkPtr {
 			p.expect(tkSemi)
+			fun.Comment = tokenComment(firstT, p.t)
 			break
 		} else {
 			p.parseErr("expect , or )")
@@ -444,18 +521,26 @@ func (p *Parse) parseInterfaceFun() *FunInfo {
 }
 
 func (p *Parse) parseInterface() {
-	itf := &InterfaceInfo{}
+	itf := &InterfaceInfo{Comment: p.t.Comment}
 	p.expect(tkName)
 	itf.Name = p.t.S.S
+	itf.Pos = p.t.Pos
 	for _, v := range p.Interface {
 		if v.Name == itf.Name {
 			p.parseErr(itf.Name + " Redefine.")
 		}
 	}
 	p.expect(tkBracel)
+	depth := p.depth
 
 	for {
-		fun := p.parseInterfaceFun()
+		var fun *FunInfo
+		if !p.try(func() { fun = p.parseInterfaceFun() }) {
+			if p.skipTo(depth) {
+				break
+			}
+			continue
+		}
 		if fun == nil {
 			break
 		}
@@ -467,6 +552,7 @@ func (p *Parse) parseInterface() {
 
 func (p *Parse) parseConst() {
 	m := ConstInfo{}
+	constT := p.t
 
 	// type
 	p.next()
@@ -483,6 +569,7 @@ func (p *Parse) parseConst() {
 
 	p.expect(tkName)
 	m.Name = p.t.S.S
+	m.Pos = p.t.Pos
 
 	p.expect(tkEq)
 
@@ -513,6 +600,7 @@ func (p *Parse) parseConst() {
 		p.parseErr("default value format error")
 	}
 	p.expect(tkSemi)
+	m.Comment = tokenComment(constT, p.t)
 
 	p.Const = append(p.Const, m)
 }
@@ -542,27 +630,58 @@ func (p *Parse) parseHashKey() {
 
 func (p *Parse) parseModuleSegment() {
 	p.expect(tkBracel)
+	depth := p.depth
 
 	for {
 		p.next()
-		t := p.t
-		switch t.T {
+		switch p.t.T {
 		case tkBracer:
 			p.expect(tkSemi)
 			return
-		case tkConst:
-			p.parseConst()
-		case tkEnum:
-			p.parseEnum()
-		case tkStruct:
-			p.parseStruct()
-		case tkInterface:
-			p.parseInterface()
-		case tkKey:
-			p.parseHashKey()
-		default:
-			p.parseErr("not except " + TokenMap[t.T])
+		case tkEos:
+			p.parseErr("expect }")
 		}
+		if !p.try(p.parseDefinition) && p.skipTo(depth) {
+			if p.t.T == tkEos {
+				p.parseErr("expect }")
+			}
+			p.expect(tkSemi)
+			return
+		}
+	}
+}
+
+func (p *Parse) parseDefinition() {
+	t := p.t
+	switch t.T {
+	case tkConst:
+		p.parseConst()
+	case tkEnum:
+		p.parseEnum()
+	case tkStruct:
+		p.parseStruct()
+	case tkInterface:
+		p.parseInterface()
+	case tkKey:
+		p.parseHashKey()
+	default:
+		p.parseErr("not except " + TokenMap[t.T])
+	}
+}
+
+// skipTo skips the tokens after an error, until the ; at the depth or the } closing the depth.
+// It reports whether the } or the end of file is reached.
+func (p *Parse) skipTo(depth int) bool {
+	for {
+		switch {
+		case p.t.T == tkEos:
+			return true
+		case p.depth < depth:
+			return true
+		case p.depth == depth && p.t.T == tkSemi:
+			return false
+		}
+		p.try(p.next)
 	}
 }
 
@@ -573,6 +692,7 @@ func (p *Parse) parseModule() {
 		p.parseErr("do not repeat define module")
 	}
 	p.Module = p.t.S.S
+	p.ModuleComment = p.lastT.Comment
 
 	p.parseModuleSegment()
 }
@@ -580,6 +700,7 @@ func (p *Parse) parseModule() {
 func (p *Parse) parseInclude() {
 	p.expect(tkString)
 	p.Include = append(p.Include, p.t.S.S)
+	p.incPos = append(p.incPos, p.t)
 }
 
 // Looking for the true type of user-defined identifier
@@ -671,7 +792,7 @@ func (p *Parse) checkDepTName(ty *VarType, dm *map[string]bool, dmj *map[string]
 		protoName := ""
 		ty.CType, mod, protoName = p.findTNameType(name)
 		if ty.CType == tkName {
-			p.parseErr(ty.TypeSt + " not find define")
+			p.parseErrAt(ty.Pos, ty.End, ty.TypeSt+" not find define")
 		}
 		if *gModuleCycle == true {
 			if mod != p.Module || protoName != p.ProtoName {
@@ -713,7 +834,7 @@ func (p *Parse) analyzeTName() {
 	for i, v := range p.Struct {
 		for _, v := range v.Mb {
 			ty := v.Type
-			p.checkDepTName(ty, &p.Struct[i].DependModule, &p.Struct[i].DependModuleWithJce)
+			p.try(func() { p.checkDepTName(ty, &p.Struct[i].DependModule, &p.Struct[i].DependModuleWithJce) })
 		}
 	}
 
@@ -721,10 +842,11 @@ func (p *Parse) analyzeTName() {
 		for _, v := range v.Fun {
 			for _, v := range v.Args {
 				ty := v.Type
-				p.checkDepTName(ty, &p.Interface[i].DependModule, &p.Interface[i].DependModuleWithJce)
+				p.try(func() { p.checkDepTName(ty, &p.Interface[i].DependModule, &p.Interface[i].DependModuleWithJce) })
 			}
 			if v.RetType != nil {
-				p.checkDepTName(v.RetType, &p.Interface[i].DependModule, &p.Interface[i].DependModuleWithJce)
+				ty := v.RetType
+				p.try(func() { p.checkDepTName(ty, &p.Interface[i].DependModule, &p.Interface[i].DependModuleWithJce) })
 			}
 		}
 	}
@@ -734,36 +856,67 @@ func (p *Parse) analyzeDefault() {
 	for _, v := range p.Struct {
 		for i, r := range v.Mb {
 			if r.Default != "" && r.DefType == tkName {
-				mb, enum := p.findEnumName(r.Default)
-				if mb == nil || enum == nil {
-					p.parseErr("can not find default value" + r.Default)
-				}
-				defValue := enum.Name + "_" + upperFirstLetter(mb.Key)
-				var currModule string
-				if *gModuleCycle == true {
-					currModule = p.ProtoName + "_" + p.Module
-				} else {
-					currModule = p.Module
-				}
-				if len(enum.Module) > 0 && currModule != enum.Module {
-					defValue = enum.Module + "." + defValue
-				}
-				v.Mb[i].Default = defValue
+				p.try(func() { p.analyzeMemberDefault(&v.Mb[i]) })
 			}
 		}
 	}
 }
 
+func (p *Parse) analyzeMemberDefault(r *StructMember) {
+	mb, enum := p.findEnumName(r.Default)
+	if mb == nil || enum == nil {
+		p.parseErrAt(r.Pos, Pos{Line: r.Pos.Line, Col: r.Pos.Col + len(r.Key)}, "can not find default value "+r.Default)
+	}
+	defValue := enum.Name + "_" + upperFirstLetter(mb.Key)
+	var currModule string
+	if *gModuleCycle == true {
+		currModule = p.ProtoName + "_" + p.Module
+	} else {
+		currModule = p.Module
+	}
+	if len(enum.Module) > 0 && currModule != enum.Module {
+		defValue = enum.Module + "." + defValue
+	}
+	r.Default = defValue
+}
+
 // TODO analysis key[]，have quoted the correct struct and member name.
 func (p *Parse) analyzeHashKey() {
 
 }
 
+// resolveInclude returns the path of the included file, relative to the working directory
+// or else to the including file.
+func (p *Parse) resolveInclude(path string) string {
+	if _, ok := gSourceOverlay[path]; ok {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil || filepath.IsAbs(path) {
+		return path
+	}
+	rel := filepath.Join(filepath.Dir(p.Source), path)
+	if _, ok := gSourceOverlay[rel]; ok {
+		return rel
+	}
+	if _, err := os.Stat(rel); err == nil {
+		return rel
+	}
+	return path
+}
+
 func (p *Parse) analyzeDepend() {
-	for _, v := range p.Include {
-		pInc := ParseFile(v, p.IncChain)
+	for i, v := range p.Include {
+		path := p.resolveInclude(v)
+		if _, err := readSource(path); err != nil {
+			t := p.incPos[i]
+			p.try(func() { p.parseErrAt(t.Pos, t.End, "file read error: "+err.Error()) })
+			continue
+		}
+		pInc := parseFile(path, p.IncChain)
 		p.IncParse = append(p.IncParse, pInc)
-		fmt.Println("parse include: ", v)
+		if !gQuiet {
+			fmt.Println("parse include: ", v)
+		}
 	}
 
 	p.analyzeDefault()
@@ -772,19 +925,26 @@ func (p *Parse) analyzeDepend() {
 }
 
 func (p *Parse) parse() {
+	// after an error, skip the tokens until the next include or module
+	skipping := false
 OUT:
 	for {
-		p.next()
+		if !p.try(p.next) {
+			continue
+		}
 		t := p.t
 		switch t.T {
 		case tkEos:
 			break OUT
 		case tkInclude:
-			p.parseInclude()
+			skipping = !p.try(p.parseInclude)
 		case tkModule:
-			p.parseModule()
+			skipping = !p.try(p.parseModule)
 		default:
-			p.parseErr("Expect include or module.")
+			if !skipping {
+				p.try(func() { p.parseErr("Expect include or module.") })
+				skipping = true
+			}
 		}
 	}
 	p.analyzeDepend()
@@ -792,28 +952,62 @@ OUT:
 
 func newParse(s string, b []byte, incChain []string) *Parse {
 	p := &Parse{Source: s, ProtoName: path2ProtoName(s)}
-	for _, v := range incChain {
-		if s == v {
-			panic("jce circular reference: " + s)
-		}
-	}
 	incChain = append(incChain, s)
 	p.IncChain = incChain
-	fmt.Println(s, p.IncChain)
+	if !gQuiet {
+		fmt.Println(s, p.IncChain)
+	}
 
 	p.lex = NewLexState(s, b)
 	return p
 }
 
-//ParseFile parse a file,return grammer tree.
-func ParseFile(path string, incChain []string) *Parse {
-	b, err := ioutil.ReadFile(path)
-	if err != nil {
-		fmt.Println("file read error: " + path + ". " + err.Error())
+// gSourceOverlay is the content of the files being edited, used instead of the files by the language server.
+var gSourceOverlay = make(map[string][]byte)
+
+func readSource(path string) ([]byte, error) {
+	if b, ok := gSourceOverlay[path]; ok {
+		return b, nil
+	}
+	return ioutil.ReadFile(path)
+}
+
+// parseFile parses a file and its includes, the errors are kept in Diags.
+func parseFile(path string, incChain []string) *Parse {
+	for _, v := range incChain {
+		if path == v {
+			p := &Parse{Source: path, ProtoName: path2ProtoName(path)}
+			p.Diags = append(p.Diags, Diagnostic{Source: path, Pos: Pos{Line: 1, Col: 1}, End: Pos{Line: 1, Col: 1},
+				Severity: diagError, Msg: "jce circular reference: " + path})
+			return p
+		}
 	}
 
+	b, err := readSource(path)
 	p := newParse(path, b, incChain)
+	if err != nil {
+		p.Diags = append(p.Diags, Diagnostic{Source: path, Pos: Pos{Line: 1, Col: 1}, End: Pos{Line: 1, Col: 1},
+			Severity: diagError, Msg: "file read error: " + err.Error()})
+		return p
+	}
 	p.parse()
+	return p
+}
 
+// ParseFile parse a file,return grammer tree. It panics with all the errors found.
+func ParseFile(path string, incChain []string) *Parse {
+	p := parseFile(path, incChain)
+
+	var errs []string
+	diags := p.AllDiags()
+	sortDiags(diags)
+	for _, d := range diags {
+		if d.Severity == diagError {
+			errs = append(errs, d.Error())
+		}
+	}
+	if len(errs) > 0 {
+		panic(strings.Join(errs, "\n"))
+	}
 	return p
 }
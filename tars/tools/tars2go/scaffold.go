@@ -0,0 +1,644 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the first port of the servants, the admin port is the one before.
+const scaffoldPort = 10015
+
+// the dir of the generated code in the server, the same as makefile.tars.gomod.
+const scaffoldProtoDir = "tars-protocol"
+
+// scaffoldServant is an interface of the tars files served by the server.
+type scaffoldServant struct {
+	p    *Parse
+	itf  *InterfaceInfo
+	pkg  string // the go package of the module
+	obj  string // the object name, without app and server
+	port int
+}
+
+// Scaffold creates a compilable server from the tars files.
+type Scaffold struct {
+	dir      string
+	app      string
+	server   string
+	module   string
+	tarsPath string
+	I        []string
+	files    []string // the tars files, their includes are copied too
+	servant  string   // the interface of the sample tars file created without tars files
+
+	servants []scaffoldServant
+	gen      *GenGo // used to convert the types
+}
+
+// NewScaffold creates the server in dir.
+func NewScaffold(dir string, app string, server string, module string, files []string) *Scaffold {
+	if server == "" {
+		server = filepath.Base(dir)
+	}
+	return &Scaffold{dir: dir, app: app, server: server, module: module, files: files, gen: &GenGo{}}
+}
+
+func (s *Scaffold) genErr(err string) {
+	panic(err)
+}
+
+// Gen creates the server, it exits with 1 on errors.
+func (s *Scaffold) Gen() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}()
+
+	if s.app == "" || s.module == "" {
+		s.genErr("-scaffold needs -app and -module")
+	}
+	if *gModuleCycle {
+		s.genErr("-scaffold does not support -module-cycle")
+	}
+	if s.server == s.app {
+		s.genErr("the server name should not be the same as the app name")
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		s.genErr(err.Error())
+	}
+
+	if len(s.files) == 0 {
+		s.genSampleTars()
+	}
+	s.collectServants()
+	tarsFiles := s.copyTars()
+
+	// generate the code the same way as the Makefile
+	pwd, err := os.Getwd()
+	if err != nil {
+		s.genErr(err.Error())
+	}
+	if err = os.Chdir(s.dir); err != nil {
+		s.genErr(err.Error())
+	}
+	for _, file := range tarsFiles {
+		gen := NewGenGo(file, s.module, scaffoldProtoDir)
+		gen.I = s.I
+		gen.tarsPath = s.tarsPath
+		gen.Gen()
+	}
+	if err = os.Chdir(pwd); err != nil {
+		s.genErr(err.Error())
+	}
+
+	s.genMain()
+	for i := range s.servants {
+		s.genImp(&s.servants[i])
+	}
+	s.genClient()
+	s.genConfig()
+	s.genMakefile(tarsFiles)
+	s.genGoMod()
+
+	fmt.Println("create server " + s.app + "." + s.server + " in " + s.dir)
+	fmt.Println("run `go mod tidy && make` in " + s.dir + " to build it, and `make run` to start it with config.conf")
+}
+
+// save writes the file in the server dir, the existing files are not overwritten.
+func (s *Scaffold) save(name string, content []byte) {
+	path := filepath.Join(s.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		s.genErr(path + " already exists, remove it to create it again")
+	}
+	if strings.HasSuffix(name, ".go") {
+		beauty, err := format.Source(content)
+		if err != nil {
+			s.genErr("go fmt fail. " + name + " " + err.Error())
+		}
+		content = beauty
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		s.genErr(err.Error())
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		s.genErr(err.Error())
+	}
+}
+
+// genSampleTars creates a tars file with an interface like the one of the Demo.
+func (s *Scaffold) genSampleTars() {
+	servant := s.servant
+	if servant == "" {
+		servant = "Hello"
+	}
+	if servant == s.server {
+		s.genErr("the servant name should not be the same as the server name")
+	}
+	name := servant + ".tars"
+	s.save(name, []byte(`module `+s.app+`
+{
+    interface `+servant+`
+    {
+        int add(int a, int b, out int c); // Some example function
+        int sub(int a, int b, out int c); // Some example function
+    };
+};
+`))
+	s.files = []string{filepath.Join(s.dir, name)}
+}
+
+// copyTars copies the tars files and their includes into the server dir, it returns their names in the dir.
+func (s *Scaffold) copyTars() []string {
+	var names []string
+	copied := make(map[string]bool)
+	var walk func(p *Parse)
+	walk = func(p *Parse) {
+		name := filepath.Base(p.Source)
+		if copied[name] {
+			return
+		}
+		copied[name] = true
+		for _, inc := range p.Include {
+			if filepath.Base(inc) != inc {
+				fmt.Println("warning: " + p.Source + " includes " + inc + ", fix the path after it is copied into " + s.dir)
+			}
+		}
+		dst := filepath.Join(s.dir, name)
+		if abs(dst) != abs(p.Source) {
+			content, err := ioutil.ReadFile(p.Source)
+			if err != nil {
+				s.genErr(err.Error())
+			}
+			s.save(name, content)
+		}
+		for _, inc := range p.IncParse {
+			walk(inc)
+		}
+	}
+	for _, file := range s.files {
+		walk(ParseFile(file, make([]string, 0)))
+		names = append(names, filepath.Base(file))
+	}
+	return names
+}
+
+func abs(path string) string {
+	if p, err := filepath.Abs(path); err == nil {
+		return p
+	}
+	return path
+}
+
+// collectServants finds the interfaces of the tars files, a servant is added for each of them.
+func (s *Scaffold) collectServants() {
+	objs := make(map[string]string)
+	for _, file := range s.files {
+		p := ParseFile(file, make([]string, 0))
+		for i := range p.Interface {
+			itf := &p.Interface[i]
+			obj := upperFirstLetter(itf.Name) + "Obj"
+			if other, ok := objs[obj]; ok {
+				s.genErr("interface " + itf.Name + " of " + p.Source + " has the same name as the one of " + other)
+			}
+			objs[obj] = p.Source
+			s.servants = append(s.servants, scaffoldServant{
+				p:    p,
+				itf:  itf,
+				pkg:  s.pkgName(p.Module),
+				obj:  obj,
+				port: scaffoldPort + len(s.servants),
+			})
+		}
+	}
+	if len(s.servants) == 0 {
+		s.genErr("no interface in the tars files")
+	}
+}
+
+// pkgName returns the go package of the tars module.
+func (s *Scaffold) pkgName(module string) string {
+	if *gModuleUpper {
+		return upperFirstLetter(module)
+	}
+	return module
+}
+
+func (s *Scaffold) importPath(pkg string) string {
+	for _, p := range s.I {
+		if strings.HasSuffix(p, "/"+pkg) {
+			return p
+		}
+	}
+	return s.module + "/" + scaffoldProtoDir + "/" + pkg
+}
+
+// genImports writes the import block with the tars packages.
+func (s *Scaffold) genImports(c *bytes.Buffer, std []string, pkgs map[string]bool) {
+	c.WriteString("import (\n")
+	for _, v := range std {
+		c.WriteString(strconv.Quote(v) + "\n")
+	}
+	if len(pkgs) > 0 {
+		c.WriteString("\n")
+	}
+	var paths []string
+	for pkg := range pkgs {
+		if pkg == "" {
+			paths = append(paths, s.tarsPath)
+		} else {
+			paths = append(paths, s.importPath(pkg))
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		c.WriteString(strconv.Quote(path) + "\n")
+	}
+	c.WriteString(")\n\n")
+}
+
+// goType returns the go type in package main, the types of the same module are qualified.
+func (s *Scaffold) goType(ty *VarType, module string) string {
+	switch ty.Type {
+	case tkTVector:
+		return "[]" + s.goType(ty.TypeK, module)
+	case tkTMap:
+		return "map[" + s.goType(ty.TypeK, module) + "]" + s.goType(ty.TypeV, module)
+	case tkTArray:
+		return "[" + strconv.FormatInt(ty.TypeL, 10) + "]" + s.goType(ty.TypeK, module)
+	case tkName:
+		t := *ty
+		if !strings.Contains(t.TypeSt, "::") {
+			t.TypeSt = module + "::" + t.TypeSt
+		}
+		return s.gen.genType(&t)
+	}
+	return s.gen.genType(ty)
+}
+
+// argName returns the name of the argument as a go identifier.
+func argName(name string) string {
+	switch name {
+	case "ctx", "imp", "ret", "err", "comm", "app":
+		return name + "_"
+	}
+	if token.Lookup(name).IsKeyword() {
+		return name + "_"
+	}
+	return name
+}
+
+// impName returns the name of the servant implementation.
+func (sv *scaffoldServant) impName() string {
+	return upperFirstLetter(sv.itf.Name) + "Imp"
+}
+
+func (s *Scaffold) genMain() {
+	var c bytes.Buffer
+	c.WriteString("package main\n\n")
+	pkgs := map[string]bool{"": true}
+	for _, sv := range s.servants {
+		pkgs[sv.pkg] = true
+	}
+	s.genImports(&c, []string{"fmt", "os"}, pkgs)
+	c.WriteString(`func main() {
+	// Get server config
+	cfg := tars.GetServerConfig()
+
+`)
+	for _, sv := range s.servants {
+		imp := sv.impName()
+		c.WriteString(`// New servant imp
+	` + lowerFirst(imp) + ` := new(` + imp + `)
+	if err := ` + lowerFirst(imp) + `.Init(); err != nil {
+		fmt.Printf("` + imp + ` init fail, err:(%s)\n", err)
+		os.Exit(-1)
+	}
+	// Register Servant
+	` + lowerFirst(sv.itf.Name) + ` := new(` + sv.pkg + `.` + upperFirstLetter(sv.itf.Name) + `)
+	` + lowerFirst(sv.itf.Name) + `.AddServantWithContext(` + lowerFirst(imp) + `, cfg.App+"."+cfg.Server+".` + sv.obj + `")
+
+`)
+	}
+	c.WriteString(`// Run application
+	tars.Run()
+}
+`)
+	s.save("main.go", c.Bytes())
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func (s *Scaffold) genImp(sv *scaffoldServant) {
+	var c bytes.Buffer
+	imp := sv.impName()
+	c.WriteString("package main\n\n")
+	pkgs := make(map[string]bool)
+	for _, fun := range sv.itf.Fun {
+		s.funPkgs(&fun, sv.pkg, pkgs)
+	}
+	s.genImports(&c, []string{"context"}, pkgs)
+
+	c.WriteString(`// ` + imp + ` servant implementation
+type ` + imp + ` struct {
+}
+
+// Init servant init
+func (imp *` + imp + `) Init() error {
+	//initialize servant here:
+	//...
+	return nil
+}
+
+// Destroy servant destroy
+func (imp *` + imp + `) Destroy() {
+	//destroy servant here:
+	//...
+}
+`)
+	for _, fun := range sv.itf.Fun {
+		c.WriteString("\n")
+		if fun.Comment != "" {
+			for _, line := range strings.Split(fun.Comment, "\n") {
+				c.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+			}
+		}
+		c.WriteString("func (imp *" + imp + ") " + upperFirstLetter(fun.Name) + "(ctx context.Context")
+		for _, arg := range fun.Args {
+			c.WriteString(", " + argName(arg.Name) + " ")
+			if arg.IsOut || arg.Type.CType == tkStruct {
+				c.WriteString("*")
+			}
+			c.WriteString(s.goType(arg.Type, sv.p.Module))
+		}
+		c.WriteString(") (")
+		if fun.HasRet {
+			c.WriteString("ret " + s.goType(fun.RetType, sv.p.Module) + ", ")
+		}
+		c.WriteString(`err error) {
+	//Doing something in your function
+	//...
+`)
+		if fun.HasRet {
+			c.WriteString("return ret, nil\n}\n")
+		} else {
+			c.WriteString("return nil\n}\n")
+		}
+	}
+	s.save(strings.ToLower(sv.itf.Name)+"_imp.go", c.Bytes())
+}
+
+// funPkgs adds the packages used by the function in package main.
+func (s *Scaffold) funPkgs(fun *FunInfo, pkg string, pkgs map[string]bool) {
+	var types []*VarType
+	if fun.HasRet {
+		types = append(types, fun.RetType)
+	}
+	for _, arg := range fun.Args {
+		types = append(types, arg.Type)
+	}
+	for _, ty := range types {
+		for _, p := range s.typePkgs(ty, pkg) {
+			pkgs[p] = true
+		}
+	}
+}
+
+// typePkgs returns the packages used by the type in package main.
+func (s *Scaffold) typePkgs(ty *VarType, pkg string) []string {
+	switch ty.Type {
+	case tkTVector, tkTArray:
+		return s.typePkgs(ty.TypeK, pkg)
+	case tkTMap:
+		return append(s.typePkgs(ty.TypeK, pkg), s.typePkgs(ty.TypeV, pkg)...)
+	case tkName:
+		if i := strings.Index(ty.TypeSt, "::"); i != -1 {
+			return []string{s.pkgName(ty.TypeSt[:i])}
+		}
+		return []string{pkg}
+	}
+	return nil
+}
+
+func (s *Scaffold) genClient() {
+	var c bytes.Buffer
+	c.WriteString("package main\n\n")
+	pkgs := map[string]bool{"": true}
+	for _, sv := range s.servants {
+		pkgs[sv.pkg] = true
+		if len(sv.itf.Fun) > 0 {
+			// the return value is not declared
+			fun := sv.itf.Fun[0]
+			fun.HasRet = false
+			s.funPkgs(&fun, sv.pkg, pkgs)
+		}
+	}
+	s.genImports(&c, []string{"context", "fmt"}, pkgs)
+	c.WriteString(`func main() {
+	comm := tars.NewCommunicator()
+	ctx := context.Background()
+`)
+	for _, sv := range s.servants {
+		c.WriteString("call" + upperFirstLetter(sv.itf.Name) + "(ctx, comm)\n")
+	}
+	c.WriteString("}\n")
+
+	for _, sv := range s.servants {
+		name := upperFirstLetter(sv.itf.Name)
+		app := lowerFirst(name)
+		c.WriteString(`
+// call` + name + ` calls the first method of ` + sv.obj + `.
+func call` + name + `(ctx context.Context, comm *tars.Communicator) {
+	obj := "` + s.app + "." + s.server + "." + sv.obj + "@tcp -h 127.0.0.1 -p " + strconv.Itoa(sv.port) + ` -t 60000"
+	` + app + ` := new(` + sv.pkg + `.` + name + `)
+	comm.StringToProxy(obj, ` + app + `)
+`)
+		if len(sv.itf.Fun) == 0 {
+			c.WriteString("_ = " + app + "\n}\n")
+			continue
+		}
+		fun := sv.itf.Fun[0]
+		var args, outs []string
+		for _, arg := range fun.Args {
+			name := argName(arg.Name)
+			c.WriteString("var " + name + " " + s.goType(arg.Type, sv.p.Module) + "\n")
+			if arg.IsOut || arg.Type.CType == tkStruct {
+				args = append(args, "&"+name)
+			} else {
+				args = append(args, name)
+			}
+			if arg.IsOut {
+				outs = append(outs, name)
+			}
+		}
+		call := app + "." + upperFirstLetter(fun.Name) + "WithContext(" + strings.Join(append([]string{"ctx"}, args...), ", ") + ")"
+		if fun.HasRet {
+			c.WriteString("ret, err := " + call + "\n")
+			outs = append([]string{"ret"}, outs...)
+		} else {
+			c.WriteString("err := " + call + "\n")
+		}
+		if len(outs) == 0 {
+			outs = []string{`"ok"`}
+		}
+		c.WriteString(`if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(` + strings.Join(outs, ", ") + `)
+}
+`)
+	}
+	s.save("client/client.go", c.Bytes())
+}
+
+// genConfig creates the config with the keys read by initConfig, the optional ones are commented with their defaults.
+func (s *Scaffold) genConfig() {
+	var c bytes.Buffer
+	c.WriteString(`<tars>
+    <application>
+        #enableset=n
+        #setdivision=
+        <client>
+            #locator=tars.tarsregistry.QueryObj@tcp -h 127.0.0.1 -p 17890
+            #stat=tars.tarsstat.StatObj
+            #property=tars.tarsproperty.PropertyObj
+            async-invoke-timeout=3000
+            refresh-endpoint-interval=60000
+            report-interval=5000
+            check-status-interval=1000
+            #clientqueuelen=10000
+            #clientidletimeout=600000
+            #clientreadtimeout=100
+            #clientwritetimeout=3000
+            #clientdialtimeout=3000
+            #reqdefaulttimeout=3000
+            #objqueuemax=100000
+            #adapterproxyticker=10000
+            #adapterproxyresetcount=5
+        </client>
+        <server>
+            app=` + s.app + `
+            server=` + s.server + `
+            local=tcp -h 127.0.0.1 -p ` + strconv.Itoa(scaffoldPort-1) + ` -t 30000
+            logpath=/tmp
+            logsize=100M
+            lognum=10
+            logLevel=DEBUG
+            #node=tars.tarsnode.ServerObj@tcp -h 127.0.0.1 -p 19386 -t 60000
+            #log=tars.tarslog.LogObj
+            #config=tars.tarsconfig.ConfigObj
+            #notify=tars.tarsnotify.NotifyObj
+            #basepath=
+            #datapath=
+            #accepttimeout=500
+            #readtimeout=0
+            #writetimeout=0
+            #handletimeout=0
+            #idletimeout=600000
+            #zombiletimeout=10000
+            #queuecap=10000000
+            #gracedowntimeout=60000
+            #tcpreadbuffer=134217728
+            #tcpwritebuffer=134217728
+            #tcpnodelay=true
+            #maxroutine=0
+            #propertyreportinterval=10000
+            #statreportinterval=10000
+            #mainloopticker=10000
+            #statreportchannelbuflen=100000
+            #maxPackageLength=10485760
+`)
+	for _, sv := range s.servants {
+		obj := s.app + "." + s.server + "." + sv.obj
+		c.WriteString(`            <` + obj + `Adapter>
+                allow
+                endpoint=tcp -h 127.0.0.1 -p ` + strconv.Itoa(sv.port) + ` -t 60000
+                handlegroup=` + obj + `Adapter
+                maxconns=200000
+                protocol=tars
+                queuecap=10000
+                queuetimeout=60000
+                servant=` + obj + `
+                shmcap=0
+                shmkey=0
+                threads=1
+            </` + obj + `Adapter>
+`)
+	}
+	c.WriteString(`        </server>
+    </application>
+</tars>
+`)
+	s.save("config.conf", c.Bytes())
+}
+
+func (s *Scaffold) genMakefile(tarsFiles []string) {
+	flags := "-outdir=" + scaffoldProtoDir + " -module=" + s.module
+	if s.tarsPath != "github.com/MacgradyHuang/TarsGo/tars" {
+		flags += " -tarsPath=" + s.tarsPath
+	}
+	if *gModuleUpper {
+		flags += " -module-upper"
+	}
+	for _, i := range s.I {
+		flags += " -I " + i
+	}
+	s.save("Makefile", []byte(`APP       := `+s.app+`
+TARGET    := `+s.server+`
+CONFIG    := config.conf
+GO        ?= go
+TARS2GO   ?= tars2go
+J2GO_FLAG := `+flags+`
+
+TARS_SRC  := `+strings.Join(tarsFiles, " ")+`
+GO_SRC    := $(wildcard *.go)
+
+all: $(TARGET)
+
+tars: $(TARS_SRC)
+	$(TARS2GO) $(J2GO_FLAG) $(TARS_SRC)
+
+$(TARGET): tars $(GO_SRC)
+	$(GO) build -o $@
+
+client: tars
+	$(GO) build -o $(TARGET)_client ./client
+
+run: $(TARGET)
+	./$(TARGET) --config=$(CONFIG)
+
+tar: $(TARGET) $(CONFIG)
+	rm -rf $(TARGET)_tmp_dir && mkdir -p $(TARGET)_tmp_dir/$(TARGET)
+	cp -f $(TARGET) $(CONFIG) $(TARGET)_tmp_dir/$(TARGET)/
+	cd $(TARGET)_tmp_dir && tar -czvf ../$(TARGET).tgz $(TARGET)/
+	rm -rf $(TARGET)_tmp_dir
+
+clean:
+	rm -rf $(TARGET) $(TARGET)_client $(TARGET).tgz
+
+.PHONY: all tars client run tar clean
+`))
+}
+
+// genGoMod creates the go.mod of the server if there is none.
+func (s *Scaffold) genGoMod() {
+	if _, err := os.Stat(filepath.Join(s.dir, "go.mod")); err == nil {
+		return
+	}
+	s.save("go.mod", []byte("module "+s.module+"\n\ngo 1.13\n"))
+}
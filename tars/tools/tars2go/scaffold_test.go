@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestScaffold tests the server created by -scaffold compiles, with the sample tars file and with the tars
+// files given.
+func TestScaffold(t *testing.T) {
+	defer setFlags(map[*bool]bool{})()
+	defer func(quiet bool) { gQuiet = quiet }(gQuiet)
+	gQuiet = true
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{name: "sample", want: []string{"SayHello.tars", "sayhello_imp.go", "tars-protocol/TestApp/SayHello.tars.go"}},
+		{name: "files", files: []string{"testdata/mock/Mock.tars"},
+			want: []string{"Mock.tars", "greeter_imp.go", "tars-protocol/Test/Greeter.tars.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp, err := ioutil.TempDir("", "tars2go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmp)
+			dir := filepath.Join(tmp, "HelloServer")
+			s := NewScaffold(dir, "TestApp", "", "gentest", tt.files)
+			s.tarsPath = gTarsPath
+			s.servant = "SayHello"
+			capture(t, &os.Stdout, s.Gen)
+
+			want := append([]string{"main.go", "config.conf", "Makefile", "go.mod", "client/client.go"}, tt.want...)
+			for _, name := range want {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Error(err)
+				}
+			}
+			goTest(t, dir, "./...")
+		})
+	}
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// typeKind is the kind of a tars type.
+type typeKind int
+
+const (
+	kindBool typeKind = iota
+	kindByte
+	kindShort
+	kindInt
+	kindLong
+	kindFloat
+	kindDouble
+	kindString
+	kindVector
+	kindMap
+	kindNamed
+)
+
+var basicKinds = map[string]typeKind{
+	"bool":   kindBool,
+	"byte":   kindByte,
+	"short":  kindShort,
+	"int":    kindInt,
+	"long":   kindLong,
+	"float":  kindFloat,
+	"double": kindDouble,
+	"string": kindString,
+}
+
+// varType describes a type used by a struct member or function argument.
+type varType struct {
+	Kind     typeKind
+	Unsigned bool
+	Name     string   // qualified name for kindNamed, e.g. "App::Req"
+	Key      *varType // element type of vector, key type of map
+	Value    *varType // value type of map
+}
+
+func (t *varType) String() string {
+	switch t.Kind {
+	case kindVector:
+		return "vector<" + t.Key.String() + ">"
+	case kindMap:
+		return "map<" + t.Key.String() + ", " + t.Value.String() + ">"
+	case kindNamed:
+		return t.Name
+	}
+	for k, v := range basicKinds {
+		if v == t.Kind {
+			if t.Unsigned {
+				return "unsigned " + k
+			}
+			return k
+		}
+	}
+	return "unknown"
+}
+
+// structMember is a member of a tars struct.
+type structMember struct {
+	Tag     byte
+	Require bool
+	Type    *varType
+	Name    string
+}
+
+// structInfo is a tars struct.
+type structInfo struct {
+	Name    string
+	Members []structMember
+}
+
+func (st *structInfo) hasMember(name string) bool {
+	for _, mb := range st.Members {
+		if mb.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// enumInfo is a tars enum, values keyed by member name.
+type enumInfo struct {
+	Name   string
+	Keys   []string
+	Values map[string]int32
+}
+
+// argInfo is an argument of an interface function.
+type argInfo struct {
+	Name  string
+	IsOut bool
+	Type  *varType
+}
+
+// funInfo is a function of a tars interface.
+type funInfo struct {
+	Name    string
+	RetType *varType // nil for void
+	Args    []argInfo
+}
+
+// interfaceInfo is a tars interface.
+type interfaceInfo struct {
+	Name string
+	Funs []funInfo
+}
+
+// idl holds every definition loaded from a set of .tars files, keyed by qualified name.
+type idl struct {
+	Structs    map[string]*structInfo
+	Enums      map[string]*enumInfo
+	Interfaces map[string]*interfaceInfo
+
+	includes []string
+	loaded   map[string]bool
+}
+
+func newIDL(includes []string) *idl {
+	return &idl{
+		Structs:    make(map[string]*structInfo),
+		Enums:      make(map[string]*enumInfo),
+		Interfaces: make(map[string]*interfaceInfo),
+		includes:   includes,
+		loaded:     make(map[string]bool),
+	}
+}
+
+// LoadFile parses the file and all the files it includes.
+func (d *idl) LoadFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if d.loaded[abs] {
+		return nil
+	}
+	d.loaded[abs] = true
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	p := &idlParser{d: d, source: path, src: b, line: 1}
+	if err = p.parse(); err != nil {
+		return err
+	}
+	for _, inc := range p.incs {
+		if err = d.LoadFile(d.resolveInclude(filepath.Dir(path), inc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *idl) resolveInclude(dir, inc string) string {
+	candidates := append([]string{dir}, d.includes...)
+	for _, c := range candidates {
+		p := filepath.Join(c, inc)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return filepath.Join(dir, inc)
+}
+
+// FindInterface looks up an interface by name, with or without the module prefix.
+func (d *idl) FindInterface(name string) (*interfaceInfo, bool) {
+	if itf, ok := d.Interfaces[name]; ok {
+		return itf, true
+	}
+	var found *interfaceInfo
+	for k, itf := range d.Interfaces {
+		if strings.HasSuffix(k, "::"+name) {
+			if found != nil {
+				return nil, false
+			}
+			found = itf
+		}
+	}
+	return found, found != nil
+}
+
+// resolve returns the struct or enum a named type refers to.
+func (d *idl) resolve(t *varType) (*structInfo, *enumInfo, error) {
+	if st, ok := d.Structs[t.Name]; ok {
+		return st, nil, nil
+	}
+	if en, ok := d.Enums[t.Name]; ok {
+		return nil, en, nil
+	}
+	return nil, nil, fmt.Errorf("type %s not defined", t.Name)
+}
+
+// idlParser is a small recursive descent parser for the .tars grammar.
+type idlParser struct {
+	d      *idl
+	source string
+	src    []byte
+	pos    int
+	line   int
+	module string
+	incs   []string
+
+	tok     string
+	tokLine int
+}
+
+func (p *idlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.source, p.tokLine, fmt.Sprintf(format, args...))
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b == ':' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// next reads the next token, returning "" at the end of input.
+func (p *idlParser) next() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			p.pos++
+		case c == '/' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '/':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '/' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '*':
+			end := strings.Index(string(p.src[p.pos+2:]), "*/")
+			if end < 0 {
+				p.tokLine = p.line
+				return p.errorf("unterminated comment")
+			}
+			p.line += strings.Count(string(p.src[p.pos:p.pos+2+end]), "\n")
+			p.pos += end + 4
+		default:
+			return p.readToken()
+		}
+	}
+	p.tok, p.tokLine = "", p.line
+	return nil
+}
+
+func (p *idlParser) readToken() error {
+	p.tokLine = p.line
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case c == '"':
+		end := strings.IndexByte(string(p.src[p.pos+1:]), '"')
+		if end < 0 {
+			return p.errorf(`no match "`)
+		}
+		p.pos += end + 2
+	case c == '#':
+		p.pos++
+		for p.pos < len(p.src) && isIdentByte(p.src[p.pos]) {
+			p.pos++
+		}
+	case isIdentByte(c) || c == '-' || c == '.':
+		p.pos++
+		for p.pos < len(p.src) && (isIdentByte(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = string(p.src[start:p.pos])
+	return nil
+}
+
+func (p *idlParser) expect(tok string) error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	if p.tok != tok {
+		return p.errorf("expect %s, got %q", tok, p.tok)
+	}
+	return nil
+}
+
+func (p *idlParser) expectName() (string, error) {
+	if err := p.next(); err != nil {
+		return "", err
+	}
+	if p.tok == "" || !isIdentByte(p.tok[0]) {
+		return "", p.errorf("expect name, got %q", p.tok)
+	}
+	return p.tok, nil
+}
+
+func (p *idlParser) qualify(name string) string {
+	if strings.Contains(name, "::") {
+		return name
+	}
+	return p.module + "::" + name
+}
+
+func (p *idlParser) parse() error {
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		switch p.tok {
+		case "":
+			return nil
+		case "#include":
+			if err := p.next(); err != nil {
+				return err
+			}
+			p.incs = append(p.incs, strings.Trim(p.tok, `"`))
+		case "module":
+			if err := p.parseModule(); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("expect include or module, got %q", p.tok)
+		}
+	}
+}
+
+func (p *idlParser) parseModule() error {
+	name, err := p.expectName()
+	if err != nil {
+		return err
+	}
+	p.module = name
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		if err = p.next(); err != nil {
+			return err
+		}
+		switch p.tok {
+		case "}":
+			return p.expect(";")
+		case "struct":
+			err = p.parseStruct()
+		case "enum":
+			err = p.parseEnum()
+		case "interface":
+			err = p.parseInterface()
+		case "const", "key":
+			err = p.skipStatement()
+		default:
+			err = p.errorf("not expect %q", p.tok)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *idlParser) skipStatement() error {
+	for p.tok != ";" {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.tok == "" {
+			return p.errorf("expect ;")
+		}
+	}
+	return nil
+}
+
+// parseType parses a type whose first token is already in p.tok.
+func (p *idlParser) parseType() (*varType, error) {
+	switch p.tok {
+	case "unsigned":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		t, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		switch t.Kind {
+		case kindByte, kindShort, kindInt:
+			t.Unsigned = true
+		default:
+			return nil, p.errorf("type %s unsigned decoration is not supported", t)
+		}
+		return t, nil
+	case "vector":
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		k, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &varType{Kind: kindVector, Key: k}, p.expect(">")
+	case "map":
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		k, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+		v, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &varType{Kind: kindMap, Key: k, Value: v}, p.expect(">")
+	}
+	if k, ok := basicKinds[p.tok]; ok {
+		return &varType{Kind: k}, nil
+	}
+	if p.tok == "" || !isIdentByte(p.tok[0]) {
+		return nil, p.errorf("expect type, got %q", p.tok)
+	}
+	return &varType{Kind: kindNamed, Name: p.qualify(p.tok)}, nil
+}
+
+func (p *idlParser) parseStruct() error {
+	name, err := p.expectName()
+	if err != nil {
+		return err
+	}
+	st := &structInfo{Name: p.qualify(name)}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		if err = p.next(); err != nil {
+			return err
+		}
+		if p.tok == "}" {
+			break
+		}
+		tag, err := strconv.Atoi(p.tok)
+		if err != nil || tag < 0 || tag > 255 {
+			return p.errorf("expect tags, got %q", p.tok)
+		}
+		m := structMember{Tag: byte(tag)}
+		if err = p.next(); err != nil {
+			return err
+		}
+		switch p.tok {
+		case "require":
+			m.Require = true
+		case "optional":
+		default:
+			return p.errorf("expect require or optional")
+		}
+		if err = p.next(); err != nil {
+			return err
+		}
+		if m.Type, err = p.parseType(); err != nil {
+			return err
+		}
+		if m.Name, err = p.expectName(); err != nil {
+			return err
+		}
+		if err = p.next(); err != nil {
+			return err
+		}
+		if p.tok == "[" {
+			// fixed length arrays are encoded as vectors
+			m.Type = &varType{Kind: kindVector, Key: m.Type}
+		}
+		if err = p.skipStatement(); err != nil {
+			return err
+		}
+		st.Members = append(st.Members, m)
+	}
+	// members are encoded in tag order
+	sort.Slice(st.Members, func(i, j int) bool { return st.Members[i].Tag < st.Members[j].Tag })
+	p.d.Structs[st.Name] = st
+	return p.expect(";")
+}
+
+func (p *idlParser) parseEnum() error {
+	name, err := p.expectName()
+	if err != nil {
+		return err
+	}
+	en := &enumInfo{Name: p.qualify(name), Values: make(map[string]int32)}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	var it int32
+	for {
+		if err = p.next(); err != nil {
+			return err
+		}
+		if p.tok == "}" {
+			break
+		}
+		key := p.tok
+		if err = p.next(); err != nil {
+			return err
+		}
+		if p.tok == "=" {
+			if err = p.next(); err != nil {
+				return err
+			}
+			if v, ok := en.Values[p.tok]; ok {
+				it = v
+			} else {
+				v, err := strconv.ParseInt(p.tok, 0, 32)
+				if err != nil {
+					return p.errorf("bad enum value %q", p.tok)
+				}
+				it = int32(v)
+			}
+			if err = p.next(); err != nil {
+				return err
+			}
+		}
+		en.Keys = append(en.Keys, key)
+		en.Values[key] = it
+		it++
+		if p.tok == "}" {
+			break
+		}
+		if p.tok != "," {
+			return p.errorf("expect , or }")
+		}
+	}
+	p.d.Enums[en.Name] = en
+	return p.expect(";")
+}
+
+func (p *idlParser) parseInterface() error {
+	name, err := p.expectName()
+	if err != nil {
+		return err
+	}
+	itf := &interfaceInfo{Name: p.qualify(name)}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		if err = p.next(); err != nil {
+			return err
+		}
+		if p.tok == "}" {
+			break
+		}
+		fun := funInfo{}
+		if p.tok != "void" {
+			if fun.RetType, err = p.parseType(); err != nil {
+				return err
+			}
+		}
+		if fun.Name, err = p.expectName(); err != nil {
+			return err
+		}
+		if err = p.expect("("); err != nil {
+			return err
+		}
+		for {
+			if err = p.next(); err != nil {
+				return err
+			}
+			if p.tok == ")" {
+				break
+			}
+			arg := argInfo{}
+			if p.tok == "out" {
+				arg.IsOut = true
+				if err = p.next(); err != nil {
+					return err
+				}
+			}
+			if arg.Type, err = p.parseType(); err != nil {
+				return err
+			}
+			if arg.Name, err = p.expectName(); err != nil {
+				return err
+			}
+			fun.Args = append(fun.Args, arg)
+			if err = p.next(); err != nil {
+				return err
+			}
+			if p.tok == ")" {
+				break
+			}
+			if p.tok != "," {
+				return p.errorf("expect , or )")
+			}
+		}
+		if err = p.expect(";"); err != nil {
+			return err
+		}
+		itf.Funs = append(itf.Funs, fun)
+	}
+	p.d.Interfaces[itf.Name] = itf
+	return p.expect(";")
+}
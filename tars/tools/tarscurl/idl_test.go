@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/codec"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/configf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/endpointf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/logf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/nodef"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/notifyf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/propertyf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/statf"
+)
+
+const resDir = "../../protocol/res"
+
+// tarsStruct is the struct generated by tars2go.
+type tarsStruct interface {
+	ReadFrom(_is *codec.Reader) error
+	WriteTo(_os *codec.Buffer) error
+}
+
+// generatedStructs are the structs generated by tars2go from the tars files of the framework.
+var generatedStructs = map[string]func() tarsStruct{
+	"configf::ConfigInfo":        func() tarsStruct { return new(configf.ConfigInfo) },
+	"configf::GetConfigListInfo": func() tarsStruct { return new(configf.GetConfigListInfo) },
+	"endpointf::EndpointF":       func() tarsStruct { return new(endpointf.EndpointF) },
+	"logf::LogInfo":              func() tarsStruct { return new(logf.LogInfo) },
+	"nodef::ServerInfo":          func() tarsStruct { return new(nodef.ServerInfo) },
+	"notifyf::NotifyInfo":        func() tarsStruct { return new(notifyf.NotifyInfo) },
+	"notifyf::NotifyItem":        func() tarsStruct { return new(notifyf.NotifyItem) },
+	"notifyf::NotifyKey":         func() tarsStruct { return new(notifyf.NotifyKey) },
+	"notifyf::ReportInfo":        func() tarsStruct { return new(notifyf.ReportInfo) },
+	"propertyf::StatPropInfo":    func() tarsStruct { return new(propertyf.StatPropInfo) },
+	"propertyf::StatPropMsgBody": func() tarsStruct { return new(propertyf.StatPropMsgBody) },
+	"propertyf::StatPropMsgHead": func() tarsStruct { return new(propertyf.StatPropMsgHead) },
+	"requestf::RequestPacket":    func() tarsStruct { return new(requestf.RequestPacket) },
+	"requestf::ResponsePacket":   func() tarsStruct { return new(requestf.ResponsePacket) },
+	"statf::ProxyInfo":           func() tarsStruct { return new(statf.ProxyInfo) },
+	"statf::StatMicMsgBody":      func() tarsStruct { return new(statf.StatMicMsgBody) },
+	"statf::StatMicMsgHead":      func() tarsStruct { return new(statf.StatMicMsgHead) },
+	"statf::StatSampleMsg":       func() tarsStruct { return new(statf.StatSampleMsg) },
+}
+
+func loadFiles(t *testing.T, pattern string) *idl {
+	t.Helper()
+	files, err := filepath.Glob(pattern)
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no tars file of %s: %v", pattern, err)
+	}
+	d := newIDL(nil)
+	for _, f := range files {
+		if err := d.LoadFile(f); err != nil {
+			t.Fatalf("load %s: %v", f, err)
+		}
+	}
+	return d
+}
+
+// sampleValue returns a value of the type which is not the default, in the json form of -d.
+func sampleValue(t *testing.T, d *idl, ty *varType) interface{} {
+	switch ty.Kind {
+	case kindBool:
+		return true
+	case kindByte, kindShort, kindInt, kindLong:
+		return json.Number("7")
+	case kindFloat, kindDouble:
+		return json.Number("1.5")
+	case kindString:
+		return "s"
+	case kindVector:
+		if ty.Key.Kind == kindByte && !ty.Key.Unsigned {
+			return base64.StdEncoding.EncodeToString([]byte("ab"))
+		}
+		return []interface{}{sampleValue(t, d, ty.Key)}
+	case kindMap:
+		key := "3"
+		if ty.Key.Kind == kindString {
+			key = "k"
+		}
+		return map[string]interface{}{key: sampleValue(t, d, ty.Value)}
+	}
+	st, en, err := d.resolve(ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if en != nil {
+		return en.Keys[len(en.Keys)-1]
+	}
+	m := make(map[string]interface{})
+	for _, mb := range st.Members {
+		m[mb.Name] = sampleValue(t, d, mb.Type)
+	}
+	return m
+}
+
+// TestResStructs tests the structs of the framework are encoded and decoded like the code generated by tars2go:
+// the struct encoded by tarscurl is read and written back by the generated struct without losing any member.
+func TestResStructs(t *testing.T) {
+	d := loadFiles(t, filepath.Join(resDir, "*.tars"))
+	for name := range d.Structs {
+		if generatedStructs[name] == nil {
+			t.Errorf("struct %s is not generated in %s", name, resDir)
+		}
+	}
+	for name, newStruct := range generatedStructs {
+		t.Run(name, func(t *testing.T) {
+			ty := &varType{Kind: kindNamed, Name: name}
+			want := sampleValue(t, d, ty)
+			e := &encoder{d: d, os: codec.NewBuffer()}
+			if err := e.write(ty, want, 0); err != nil {
+				t.Fatal(err)
+			}
+			encoded := e.os.ToBytes()
+
+			st := newStruct()
+			is := codec.NewReader(encoded)
+			if err, _ := is.SkipTo(codec.STRUCT_BEGIN, 0, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.ReadFrom(is); err != nil {
+				t.Fatalf("read by the generated struct: %v", err)
+			}
+			buf := codec.NewBuffer()
+			if err := buf.WriteHead(codec.STRUCT_BEGIN, 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			if err := buf.WriteHead(codec.STRUCT_END, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := (&decoder{d: d, is: codec.NewReader(buf.ToBytes())}).read(ty, 0, true)
+			if err != nil {
+				t.Fatalf("decode the generated struct: %v", err)
+			}
+			sent, err := (&decoder{d: d, is: codec.NewReader(encoded)}).read(ty, 0, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, sent) {
+				t.Fatalf("generated struct: got %v, want %v", got, sent)
+			}
+		})
+	}
+}
+
+// generatedMethods returns the methods of the servant interfaces generated by tars2go in dir, with the
+// numbers of their arguments.
+func generatedMethods(t *testing.T, dir string) map[string]map[string]int {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*_IF.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	itfs := make(map[string]map[string]int)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok || !strings.HasPrefix(spec.Name.Name, "_imp") || strings.HasSuffix(spec.Name.Name, "WithContext") {
+				return true
+			}
+			methods := make(map[string]int)
+			for _, m := range spec.Type.(*ast.InterfaceType).Methods.List {
+				n := 0
+				for _, p := range m.Type.(*ast.FuncType).Params.List {
+					n += len(p.Names)
+				}
+				methods[strings.ToLower(m.Names[0].Name)] = n
+			}
+			itfs[f.Name.Name+"::"+strings.TrimPrefix(spec.Name.Name, "_imp")] = methods
+			return false
+		})
+	}
+	return itfs
+}
+
+// TestResInterfaces tests the interfaces of the framework have the methods and the arguments of the code
+// generated by tars2go.
+func TestResInterfaces(t *testing.T) {
+	d := loadFiles(t, filepath.Join(resDir, "*.tars"))
+	generated := generatedMethods(t, resDir)
+	if len(d.Interfaces) != len(generated) {
+		t.Fatalf("got %d interfaces, tars2go generated %d", len(d.Interfaces), len(generated))
+	}
+	for name, itf := range d.Interfaces {
+		methods, ok := generated[name]
+		if !ok {
+			t.Errorf("interface %s is not generated", name)
+			continue
+		}
+		if len(itf.Funs) != len(methods) {
+			t.Errorf("%s: got %d methods, tars2go generated %d", name, len(itf.Funs), len(methods))
+		}
+		for _, fun := range itf.Funs {
+			n, ok := methods[strings.ToLower(fun.Name)]
+			if !ok {
+				t.Errorf("%s.%s is not generated", name, fun.Name)
+			} else if n != len(fun.Args) {
+				t.Errorf("%s.%s: got %d arguments, tars2go generated %d", name, fun.Name, len(fun.Args), n)
+			}
+		}
+	}
+}
+
+// TestExamples tests the tars files of the examples are parsed.
+func TestExamples(t *testing.T) {
+	var files []string
+	err := filepath.Walk("../../../_examples", func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(path, ".tars") {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no tars file of the examples: %v", err)
+	}
+	for _, f := range files {
+		d := newIDL(nil)
+		if err := d.LoadFile(f); err != nil {
+			t.Errorf("load %s: %v", f, err)
+			continue
+		}
+		if len(d.Interfaces) == 0 && len(d.Structs) == 0 {
+			t.Errorf("%s: nothing is parsed", f)
+		}
+	}
+}
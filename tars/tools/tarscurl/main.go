@@ -0,0 +1,362 @@
+//
+// tarscurl invokes a method of a running tars servant from the command line,
+// encoding the arguments from json with the types declared in .tars files.
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MacgradyHuang/TarsGo/tars"
+	m "github.com/MacgradyHuang/TarsGo/tars/model"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/codec"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/basef"
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/res/requestf"
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
+	"github.com/MacgradyHuang/TarsGo/tars/util/tools"
+)
+
+type stringList []string
+
+func (t *stringList) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *stringList) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+func (t *stringList) toMap() (map[string]string, error) {
+	if len(*t) == 0 {
+		return nil, nil
+	}
+	ret := make(map[string]string)
+	for _, kv := range *t {
+		pos := strings.Index(kv, "=")
+		if pos <= 0 {
+			return nil, fmt.Errorf("expect key=value, got %q", kv)
+		}
+		ret[kv[:pos]] = kv[pos+1:]
+	}
+	return ret, nil
+}
+
+var (
+	gTarsFiles stringList
+	gImports   stringList
+	gContext   stringList
+	gStatus    stringList
+	gData      string
+	gLocator   string
+	gTimeout   int
+	gHash      int64
+	gConsHash  bool
+	gOneWay    bool
+	gList      bool
+)
+
+// rawProxy is a ProxyPrx without generated code, it only holds the servant.
+type rawProxy struct {
+	s m.Servant
+}
+
+// SetServant sets servant for the proxy.
+func (p *rawProxy) SetServant(s m.Servant) {
+	p.s = s
+}
+
+func printhelp() {
+	fmt.Fprintf(os.Stderr, "Usage: tarscurl [flags] -tars Hello.tars <obj> <Interface.method>\n")
+	fmt.Fprintf(os.Stderr, "       tarscurl -tars Hello.tars -d '{\"name\":\"tars\"}' 'App.Server.HelloObj@tcp -h 127.0.0.1 -p 10015' Hello.sayHello\n")
+	fmt.Fprintf(os.Stderr, "       tarscurl -tars Hello.tars -locator 'tars.tarsregistry.QueryObj@tcp -h 10.0.0.1 -p 17890' App.Server.HelloObj Hello.sayHello\n")
+	fmt.Fprintf(os.Stderr, "       tarscurl -tars Hello.tars -list\n")
+	flag.PrintDefaults()
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = printhelp
+	flag.Var(&gTarsFiles, "tars", "tars file describing the servant, can be repeated")
+	flag.Var(&gImports, "I", "include path for #include in tars files, can be repeated")
+	flag.Var(&gContext, "context", "request context as key=value, can be repeated")
+	flag.Var(&gStatus, "status", "request status as key=value, can be repeated")
+	flag.StringVar(&gData, "d", "", "arguments as json object keyed by argument name or json array, @file reads a file, - reads stdin")
+	flag.StringVar(&gLocator, "locator", "", "locator used to resolve obj without endpoints")
+	flag.IntVar(&gTimeout, "timeout", 3000, "invoke timeout in milliseconds")
+	flag.Int64Var(&gHash, "hash", -1, "hash code used to select the server, from 0 to 4294967295")
+	flag.BoolVar(&gConsHash, "consistent-hash", false, "use consistent hash instead of mod hash with -hash")
+	flag.BoolVar(&gOneWay, "oneway", false, "invoke without waiting for the response")
+	flag.BoolVar(&gList, "list", false, "list interfaces and methods declared in the tars files")
+	flag.Parse()
+
+	if len(gTarsFiles) == 0 {
+		printhelp()
+		os.Exit(1)
+	}
+	d := newIDL(gImports)
+	for _, f := range gTarsFiles {
+		if err := d.LoadFile(f); err != nil {
+			fatal("load %s failed: %v", f, err)
+		}
+	}
+	if gList {
+		listInterfaces(d)
+		return
+	}
+	if flag.NArg() != 2 {
+		printhelp()
+		os.Exit(1)
+	}
+	if gHash > math.MaxUint32 {
+		fatal("-hash %d is out of the range of uint32", gHash)
+	}
+
+	fun, err := findFun(d, flag.Arg(1))
+	if err != nil {
+		fatal("%v", err)
+	}
+	buf, err := encodeArgs(d, fun)
+	if err != nil {
+		fatal("encode arguments failed: %v", err)
+	}
+	reqContext, err := gContext.toMap()
+	if err != nil {
+		fatal("-context: %v", err)
+	}
+	status, err := gStatus.toMap()
+	if err != nil {
+		fatal("-status: %v", err)
+	}
+
+	// the framework parses its own flags from os.Args while initializing.
+	os.Args = os.Args[:1]
+	comm := tars.NewCommunicator()
+	if gLocator != "" {
+		comm.SetProperty("locator", gLocator)
+	}
+	proxy := new(rawProxy)
+	comm.StringToProxy(flag.Arg(0), proxy)
+	proxy.s.TarsSetTimeout(gTimeout)
+
+	ctx := current.ContextWithClientCurrent(context.Background())
+	if gHash >= 0 {
+		hashType := tars.ModHash
+		if gConsHash {
+			hashType = tars.ConsistentHash
+		}
+		current.SetClientHash(ctx, int(hashType), uint32(gHash))
+	}
+
+	ctype := byte(basef.TARSNORMAL)
+	if gOneWay {
+		ctype = byte(basef.TARSONEWAY)
+	}
+	resp := new(requestf.ResponsePacket)
+	begin := time.Now()
+	err = proxy.s.Tars_invoke(ctx, ctype, fun.Name, buf, status, reqContext, resp)
+	cost := time.Since(begin)
+	if err != nil {
+		fatal("invoke failed after %v: %v", cost, err)
+	}
+
+	out := map[string]interface{}{}
+	if !gOneWay {
+		if out, err = decodeResult(d, fun, tools.Int8ToByte(resp.SBuffer)); err != nil {
+			fatal("decode response failed: %v", err)
+		}
+		if len(resp.Context) > 0 {
+			out["_context"] = resp.Context
+		}
+		if len(resp.Status) > 0 {
+			out["_status"] = resp.Status
+		}
+	}
+	b, _ := json.MarshalIndent(out, "", "  ")
+	fmt.Println(string(b))
+	ip, _ := current.GetServerIPFromContext(ctx)
+	port, _ := current.GetServerPortFromContext(ctx)
+	fmt.Fprintf(os.Stderr, "invoke %s %s:%s cost %v\n", fun.Name, ip, port, cost)
+}
+
+// findFun finds the function from "Interface.method", the interface may be omitted if there is only one.
+func findFun(d *idl, name string) (*funInfo, error) {
+	itfName := ""
+	funName := name
+	if pos := strings.LastIndex(name, "."); pos >= 0 {
+		itfName, funName = name[:pos], name[pos+1:]
+	}
+	var itfs []*interfaceInfo
+	if itfName == "" {
+		for _, itf := range d.Interfaces {
+			itfs = append(itfs, itf)
+		}
+	} else if itf, ok := d.FindInterface(itfName); ok {
+		itfs = append(itfs, itf)
+	} else {
+		return nil, fmt.Errorf("interface %s not found", itfName)
+	}
+	var found *funInfo
+	for _, itf := range itfs {
+		for i := range itf.Funs {
+			if itf.Funs[i].Name == funName {
+				if found != nil {
+					return nil, fmt.Errorf("method %s is ambiguous, use Interface.method", funName)
+				}
+				found = &itf.Funs[i]
+			}
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("method %s not found", name)
+	}
+	return found, nil
+}
+
+func readData() ([]byte, error) {
+	switch {
+	case gData == "-":
+		return ioutil.ReadAll(os.Stdin)
+	case strings.HasPrefix(gData, "@"):
+		return ioutil.ReadFile(gData[1:])
+	}
+	return []byte(gData), nil
+}
+
+// encodeArgs encodes the input arguments of fun, tags start from 1 like the generated proxy.
+func encodeArgs(d *idl, fun *funInfo) ([]byte, error) {
+	data, err := readData()
+	if err != nil {
+		return nil, err
+	}
+	var named map[string]interface{}
+	var positional []interface{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var v interface{}
+		if err = dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		switch a := v.(type) {
+		case map[string]interface{}:
+			named = a
+		case []interface{}:
+			positional = a
+		default:
+			return nil, fmt.Errorf("expect json object or array")
+		}
+	}
+
+	if err = checkArgs(fun, named, positional); err != nil {
+		return nil, err
+	}
+
+	e := &encoder{d: d, os: codec.NewBuffer()}
+	pos := 0
+	for k, arg := range fun.Args {
+		var v interface{}
+		if positional != nil {
+			if arg.IsOut {
+				continue
+			}
+			if pos < len(positional) {
+				v = positional[pos]
+			}
+			pos++
+		} else {
+			v = named[arg.Name]
+		}
+		if err = e.write(arg.Type, v, byte(k+1)); err != nil {
+			return nil, fmt.Errorf("argument %s: %v", arg.Name, err)
+		}
+	}
+	return e.os.ToBytes(), nil
+}
+
+// checkArgs rejects the names which are not the arguments of fun, and the positional arguments more than
+// the input arguments, so a typo is not sent as the default value.
+func checkArgs(fun *funInfo, named map[string]interface{}, positional []interface{}) error {
+	inputs := 0
+	args := make(map[string]bool, len(fun.Args))
+	for _, arg := range fun.Args {
+		args[arg.Name] = true
+		if !arg.IsOut {
+			inputs++
+		}
+	}
+	var unknown []string
+	for k := range named {
+		if !args[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown arguments %s of %s", strings.Join(unknown, ", "), fun.Name)
+	}
+	if len(positional) > inputs {
+		return fmt.Errorf("got %d arguments, %s has %d input arguments", len(positional), fun.Name, inputs)
+	}
+	return nil
+}
+
+// decodeResult decodes the return value at tag 0 and the out arguments.
+func decodeResult(d *idl, fun *funInfo, buf []byte) (map[string]interface{}, error) {
+	dc := &decoder{d: d, is: codec.NewReader(buf)}
+	out := make(map[string]interface{})
+	if fun.RetType != nil {
+		v, err := dc.read(fun.RetType, 0, true)
+		if err != nil {
+			return nil, fmt.Errorf("return value: %v", err)
+		}
+		out["_ret"] = v
+	}
+	for k, arg := range fun.Args {
+		if !arg.IsOut {
+			continue
+		}
+		v, err := dc.read(arg.Type, byte(k+1), true)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %v", arg.Name, err)
+		}
+		out[arg.Name] = v
+	}
+	return out, nil
+}
+
+func listInterfaces(d *idl) {
+	for _, itf := range d.Interfaces {
+		fmt.Println(itf.Name)
+		for _, fun := range itf.Funs {
+			ret := "void"
+			if fun.RetType != nil {
+				ret = fun.RetType.String()
+			}
+			var args []string
+			for _, arg := range fun.Args {
+				s := arg.Type.String() + " " + arg.Name
+				if arg.IsOut {
+					s = "out " + s
+				}
+				args = append(args, s)
+			}
+			fmt.Printf("    %s %s(%s);\n", ret, fun.Name, strings.Join(args, ", "))
+		}
+	}
+}
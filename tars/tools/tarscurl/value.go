@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/codec"
+)
+
+// encoder writes json decoded values to a tars buffer, driven by the idl types.
+type encoder struct {
+	d  *idl
+	os *codec.Buffer
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		return n.Int64()
+	case float64:
+		return int64(n), nil
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(n, 0, 64)
+	}
+	return 0, fmt.Errorf("expect number, got %T", v)
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		return n.Float64()
+	case float64:
+		return n, nil
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	return 0, fmt.Errorf("expect number, got %T", v)
+}
+
+func (e *encoder) enumValue(en *enumInfo, v interface{}) (int32, error) {
+	if s, ok := v.(string); ok {
+		if n, ok := en.Values[s]; ok {
+			return n, nil
+		}
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, fmt.Errorf("enum %s: %v", en.Name, err)
+	}
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return 0, fmt.Errorf("enum %s: %d out of range", en.Name, n)
+	}
+	return int32(n), nil
+}
+
+// write encodes v as type t with the given tag.
+func (e *encoder) write(t *varType, v interface{}, tag byte) error {
+	os := e.os
+	switch t.Kind {
+	case kindBool:
+		b, ok := v.(bool)
+		if !ok && v != nil {
+			return fmt.Errorf("expect bool, got %T", v)
+		}
+		return os.Write_bool(b, tag)
+	case kindByte, kindShort, kindInt, kindLong:
+		n, err := toInt64(v)
+		if err != nil {
+			return err
+		}
+		return e.writeInt(t, n, tag)
+	case kindFloat:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		return os.Write_float32(float32(f), tag)
+	case kindDouble:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		return os.Write_float64(f, tag)
+	case kindString:
+		s, ok := v.(string)
+		if !ok && v != nil {
+			return fmt.Errorf("expect string, got %T", v)
+		}
+		return os.Write_string(s, tag)
+	case kindVector:
+		return e.writeVector(t, v, tag)
+	case kindMap:
+		return e.writeMap(t, v, tag)
+	case kindNamed:
+		st, en, err := e.d.resolve(t)
+		if err != nil {
+			return err
+		}
+		if en != nil {
+			n, err := e.enumValue(en, v)
+			if err != nil {
+				return err
+			}
+			return os.Write_int32(n, tag)
+		}
+		return e.writeStruct(st, v, tag)
+	}
+	return fmt.Errorf("unknown type %s", t)
+}
+
+// intRange returns the range of the values of the integer type t.
+func intRange(t *varType) (min, max int64) {
+	switch t.Kind {
+	case kindByte:
+		if t.Unsigned {
+			return 0, math.MaxUint8
+		}
+		return math.MinInt8, math.MaxInt8
+	case kindShort:
+		if t.Unsigned {
+			return 0, math.MaxUint16
+		}
+		return math.MinInt16, math.MaxInt16
+	case kindInt:
+		if t.Unsigned {
+			return 0, math.MaxUint32
+		}
+		return math.MinInt32, math.MaxInt32
+	}
+	return math.MinInt64, math.MaxInt64
+}
+
+func (e *encoder) writeInt(t *varType, n int64, tag byte) error {
+	if min, max := intRange(t); n < min || n > max {
+		return fmt.Errorf("%d out of range of %s", n, t)
+	}
+	os := e.os
+	switch t.Kind {
+	case kindByte:
+		if t.Unsigned {
+			return os.Write_uint8(uint8(n), tag)
+		}
+		return os.Write_int8(int8(n), tag)
+	case kindShort:
+		if t.Unsigned {
+			return os.Write_uint16(uint16(n), tag)
+		}
+		return os.Write_int16(int16(n), tag)
+	case kindInt:
+		if t.Unsigned {
+			return os.Write_uint32(uint32(n), tag)
+		}
+		return os.Write_int32(int32(n), tag)
+	}
+	return os.Write_int64(n, tag)
+}
+
+func (e *encoder) writeVector(t *varType, v interface{}, tag byte) error {
+	os := e.os
+	if t.Key.Kind == kindByte && !t.Key.Unsigned {
+		// vector<byte> is a SIMPLE_LIST, given as a base64 string like it is printed
+		var data []byte
+		switch b := v.(type) {
+		case nil:
+		case string:
+			var err error
+			if data, err = base64.StdEncoding.DecodeString(b); err != nil {
+				return fmt.Errorf("expect base64 string: %v", err)
+			}
+		default:
+			return fmt.Errorf("expect base64 string, got %T", v)
+		}
+		if err := os.WriteHead(codec.SIMPLE_LIST, tag); err != nil {
+			return err
+		}
+		if err := os.WriteHead(codec.BYTE, 0); err != nil {
+			return err
+		}
+		if err := os.Write_int32(int32(len(data)), 0); err != nil {
+			return err
+		}
+		return os.Write_slice_uint8(data)
+	}
+
+	list, ok := v.([]interface{})
+	if !ok && v != nil {
+		return fmt.Errorf("expect array, got %T", v)
+	}
+	if err := os.WriteHead(codec.LIST, tag); err != nil {
+		return err
+	}
+	if err := os.Write_int32(int32(len(list)), 0); err != nil {
+		return err
+	}
+	for _, x := range list {
+		if err := e.write(t.Key, x, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) writeMap(t *varType, v interface{}, tag byte) error {
+	os := e.os
+	m, ok := v.(map[string]interface{})
+	if !ok && v != nil {
+		return fmt.Errorf("expect object, got %T", v)
+	}
+	if err := os.WriteHead(codec.MAP, tag); err != nil {
+		return err
+	}
+	if err := os.Write_int32(int32(len(m)), 0); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// json object keys are always strings, let write convert them for numeric key types
+		var key interface{} = k
+		if t.Key.Kind != kindString {
+			key = json.Number(k)
+		}
+		if err := e.write(t.Key, key, 0); err != nil {
+			return fmt.Errorf("map key %s: %v", k, err)
+		}
+		if err := e.write(t.Value, m[k], 1); err != nil {
+			return fmt.Errorf("map value %s: %v", k, err)
+		}
+	}
+	return nil
+}
+
+func (e *encoder) writeStruct(st *structInfo, v interface{}, tag byte) error {
+	os := e.os
+	m, ok := v.(map[string]interface{})
+	if !ok && v != nil {
+		return fmt.Errorf("struct %s: expect object, got %T", st.Name, v)
+	}
+	for k := range m {
+		if !st.hasMember(k) {
+			return fmt.Errorf("struct %s: unknown member %s", st.Name, k)
+		}
+	}
+	if err := os.WriteHead(codec.STRUCT_BEGIN, tag); err != nil {
+		return err
+	}
+	for _, mb := range st.Members {
+		x, have := m[mb.Name]
+		if !have && !mb.Require {
+			continue
+		}
+		if err := e.write(mb.Type, x, mb.Tag); err != nil {
+			return fmt.Errorf("%s.%s: %v", st.Name, mb.Name, err)
+		}
+	}
+	return os.WriteHead(codec.STRUCT_END, 0)
+}
+
+// decoder reads tars encoded values into json friendly go values.
+type decoder struct {
+	d  *idl
+	is *codec.Reader
+}
+
+// read decodes the value of type t at tag.
+func (dc *decoder) read(t *varType, tag byte, require bool) (interface{}, error) {
+	is := dc.is
+	switch t.Kind {
+	case kindBool:
+		var b bool
+		err := is.Read_bool(&b, tag, require)
+		return b, err
+	case kindByte, kindShort, kindInt, kindLong:
+		var n int64
+		err := is.Read_int64(&n, tag, require)
+		return n, err
+	case kindFloat, kindDouble:
+		var f float64
+		err := is.Read_float64(&f, tag, require)
+		return f, err
+	case kindString:
+		var s string
+		err := is.Read_string(&s, tag, require)
+		return s, err
+	case kindVector:
+		return dc.readVector(t, tag, require)
+	case kindMap:
+		return dc.readMap(t, tag, require)
+	case kindNamed:
+		st, en, err := dc.d.resolve(t)
+		if err != nil {
+			return nil, err
+		}
+		if en != nil {
+			var n int32
+			if err = is.Read_int32(&n, tag, require); err != nil {
+				return nil, err
+			}
+			for _, k := range en.Keys {
+				if en.Values[k] == n {
+					return k, nil
+				}
+			}
+			return n, nil
+		}
+		return dc.readStruct(st, tag, require)
+	}
+	return nil, fmt.Errorf("unknown type %s", t)
+}
+
+func (dc *decoder) readVector(t *varType, tag byte, require bool) (interface{}, error) {
+	is := dc.is
+	err, have, ty := is.SkipToNoCheck(tag, require)
+	if err != nil || !have {
+		return nil, err
+	}
+	var length int32
+	switch ty {
+	case codec.SIMPLE_LIST:
+		if err, _ = is.SkipTo(codec.BYTE, 0, true); err != nil {
+			return nil, err
+		}
+		if err = is.ReadLength(&length, codec.SIMPLE_LIST); err != nil {
+			return nil, err
+		}
+		var data []uint8
+		if err = is.Read_slice_uint8(&data, length, true); err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	case codec.LIST:
+		if err = is.ReadLength(&length, codec.LIST); err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, 0)
+		for i := int32(0); i < length; i++ {
+			x, err := dc.read(t.Key, 0, true)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, x)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("require vector at tag %d, but type is %d", tag, ty)
+}
+
+func (dc *decoder) readMap(t *varType, tag byte, require bool) (interface{}, error) {
+	is := dc.is
+	err, have := is.SkipTo(codec.MAP, tag, require)
+	if err != nil || !have {
+		return nil, err
+	}
+	var length int32
+	if err = is.ReadLength(&length, codec.MAP); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	for i := int32(0); i < length; i++ {
+		k, err := dc.read(t.Key, 0, true)
+		if err != nil {
+			return nil, err
+		}
+		v, err := dc.read(t.Value, 1, true)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
+
+func (dc *decoder) readStruct(st *structInfo, tag byte, require bool) (interface{}, error) {
+	is := dc.is
+	err, have := is.SkipTo(codec.STRUCT_BEGIN, tag, require)
+	if err != nil || !have {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	for _, mb := range st.Members {
+		v, err := dc.read(mb.Type, mb.Tag, mb.Require)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", st.Name, mb.Name, err)
+		}
+		m[mb.Name] = v
+	}
+	if err = is.SkipToStructEnd(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
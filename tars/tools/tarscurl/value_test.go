@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MacgradyHuang/TarsGo/tars/protocol/codec"
+)
+
+const testTars = `
+module Test
+{
+	enum Color
+	{
+		RED,
+		GREEN = 5
+	};
+
+	struct Item
+	{
+		1 optional map<string, long> counts;
+		0 require string name;
+		2 optional Color color;
+		3 optional vector<byte> data;
+	};
+
+	interface Demo
+	{
+		int echo(Item item, vector<int> ids, out Item outItem);
+	};
+};
+`
+
+func loadTestIDL(t *testing.T) *idl {
+	dir, err := ioutil.TempDir("", "tarscurl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "Test.tars")
+	if err = ioutil.WriteFile(path, []byte(testTars), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := newIDL(nil)
+	if err = d.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEncodeDecode(t *testing.T) {
+	d := loadTestIDL(t)
+	gData = `{"item":{"name":"tars","counts":{"a":1},"color":"GREEN","data":"AQI="},"ids":[1,2]}`
+	defer func() { gData = "" }()
+	fun, err := findFun(d, "Demo.echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := encodeArgs(d, fun)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dc := &decoder{d: d, is: codec.NewReader(buf)}
+	item, err := dc.read(fun.Args[0].Type, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{
+		"name":   "tars",
+		"counts": map[string]interface{}{"a": int64(1)},
+		"color":  "GREEN",
+		"data":   "AQI=",
+	}
+	if !reflect.DeepEqual(item, expect) {
+		t.Fatalf("item: got %v, expect %v", item, expect)
+	}
+	ids, err := dc.read(fun.Args[1].Type, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []interface{}{int64(1), int64(2)}) {
+		t.Fatalf("ids: got %v", ids)
+	}
+}
+
+// TestEncodeUnknownArgs tests the names which are not the arguments or the members are rejected.
+func TestEncodeUnknownArgs(t *testing.T) {
+	d := loadTestIDL(t)
+	defer func() { gData = "" }()
+	fun, err := findFun(d, "Demo.echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"item":{"name":"tars"},"idz":[1],"id":[2]}`, "unknown arguments id, idz of echo"},
+		{`{"item":{"name":"tars","colour":"RED"}}`, "argument item: struct Test::Item: unknown member colour"},
+		{`[{"name":"tars"},[1],{"name":"out"}]`, "got 3 arguments, echo has 2 input arguments"},
+		{`{"item":{"name":"tars"},"outItem":{"name":"out"}}`, ""},
+		{`[{"name":"tars"},[1]]`, ""},
+	}
+	for _, tt := range tests {
+		gData = tt.data
+		_, err := encodeArgs(d, fun)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %v", tt.data, err)
+		}
+		if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: got %v, want %s", tt.data, err, tt.err)
+		}
+	}
+}
+
+// TestEncodeInvalidValues tests the numbers out of the range of their types and the bytes which are not
+// base64 strings are rejected.
+func TestEncodeInvalidValues(t *testing.T) {
+	d := loadTestIDL(t)
+	defer func() { gData = "" }()
+	fun, err := findFun(d, "Demo.echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"item":{"name":"tars"},"ids":[2147483648]}`, "argument ids: 2147483648 out of range of int"},
+		{`{"item":{"name":"tars"},"ids":[-2147483649]}`, "argument ids: -2147483649 out of range of int"},
+		{`{"item":{"name":"tars","color":4294967296}}`, "argument item: Test::Item.color: enum Test::Color: 4294967296 out of range"},
+		{`{"item":{"name":"tars","data":"te$t"}}`, "argument item: Test::Item.data: expect base64 string: illegal base64 data at input byte 2"},
+		{`{"item":{"name":"tars","data":[1,2]}}`, "argument item: Test::Item.data: expect base64 string, got []interface {}"},
+		{`{"item":{"name":"tars","data":"dGVzdA=="},"ids":[2147483647,-2147483648]}`, ""},
+	}
+	for _, tt := range tests {
+		gData = tt.data
+		_, err := encodeArgs(d, fun)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %v", tt.data, err)
+		}
+		if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: got %v, want %s", tt.data, err, tt.err)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package current
 
-import "context"
+import (
+	"context"
+	"sync/atomic"
+)
 
 type tarsCurrentKey int64
 
@@ -19,6 +22,8 @@ type Current struct {
 	resContext  map[string]string
 	needDyeing  bool
 	dyeingUser  string
+	sampleKey   string
+	sampleWidth int32
 }
 
 // NewCurrent return a Current point.
@@ -254,3 +259,32 @@ func SetDyeingUser(ctx context.Context, user string) bool {
 	}
 	return ok
 }
+
+const STATUS_SAMPLE_KEY = "STATUS_SAMPLE_KEY"
+
+// GetSampleKey gets the sample key of the request from the context.
+func GetSampleKey(ctx context.Context) (string, bool) {
+	tc, ok := currentFromContext(ctx)
+	if ok && tc.sampleKey != "" {
+		return tc.sampleKey, true
+	}
+	return "", false
+}
+
+// SetSampleKey set the sample key of the request to the tars current.
+func SetSampleKey(ctx context.Context, sampleKey string) bool {
+	tc, ok := currentFromContext(ctx)
+	if ok {
+		tc.sampleKey = sampleKey
+	}
+	return ok
+}
+
+// NextSampleWidth returns the width of the next sampled call made in the request, starting from 1.
+func NextSampleWidth(ctx context.Context) int32 {
+	tc, ok := currentFromContext(ctx)
+	if ok {
+		return atomic.AddInt32(&tc.sampleWidth, 1)
+	}
+	return 1
+}
@@ -3,6 +3,13 @@ package zaplog
 import (
 	"os"
 	"path"
+	"time"
+)
+
+// the encodings of the logs
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
 )
 
 type zapLoggerConf struct {
@@ -14,6 +21,12 @@ type zapLoggerConf struct {
 	hostName    string
 	eLKTempName string
 	logPath     string
+	level       string
+	encoding    string
+	logSizeMB   int
+	logNum      int
+	maxAge      time.Duration
+	httpServer  bool
 }
 
 var defaultOptions = zapLoggerConf{
@@ -23,12 +36,19 @@ var defaultOptions = zapLoggerConf{
 	logApiPath:  "/log",
 	listenAddr:  "127.0.0.1:0",
 	eLKTempName: path.Base(os.Args[0]),
+	encoding:    EncodingJSON,
+	logNum:      10,
+	maxAge:      time.Hour * 24 * 3,
+	httpServer:  true,
 }
 
 type zapLoggerOption interface {
 	apply(*zapLoggerConf)
 }
 
+// Option is the option of InitZapLogger.
+type Option = zapLoggerOption
+
 type zapLoggerOptionFunc func(*zapLoggerConf)
 
 func (t zapLoggerOptionFunc) apply(option *zapLoggerConf) {
@@ -82,3 +102,46 @@ func LogPath(path string) zapLoggerOption {
 		option.logPath = path
 	})
 }
+
+// Level sets the level of the framework logger, which is debug in the test env or info if it is empty.
+func Level(level string) zapLoggerOption {
+	return zapLoggerOptionFunc(func(option *zapLoggerConf) {
+		option.level = level
+	})
+}
+
+// Encoding sets the encoding of the logs, json or console (plain text).
+func Encoding(encoding string) zapLoggerOption {
+	return zapLoggerOptionFunc(func(option *zapLoggerConf) {
+		option.encoding = encoding
+	})
+}
+
+// LogSize rolls the log by size instead of by day when the sizeMB is positive.
+func LogSize(sizeMB int) zapLoggerOption {
+	return zapLoggerOptionFunc(func(option *zapLoggerConf) {
+		option.logSizeMB = sizeMB
+	})
+}
+
+// LogNum sets the number of the files to keep of the log rolled by size.
+func LogNum(num int) zapLoggerOption {
+	return zapLoggerOptionFunc(func(option *zapLoggerConf) {
+		option.logNum = num
+	})
+}
+
+// MaxAge sets how long to keep the files of the log rolled by day.
+func MaxAge(maxAge time.Duration) zapLoggerOption {
+	return zapLoggerOptionFunc(func(option *zapLoggerConf) {
+		option.maxAge = maxAge
+	})
+}
+
+// WithHttpServer serves the level of the framework logger at ListenAddr and LogApiPath by http, the
+// server is started once, and it is stopped if the logger is initialized again without it.
+func WithHttpServer(httpServer bool) zapLoggerOption {
+	return zapLoggerOptionFunc(func(option *zapLoggerConf) {
+		option.httpServer = httpServer
+	})
+}
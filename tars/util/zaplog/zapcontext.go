@@ -0,0 +1,67 @@
+package zaplog
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// the fields of the requests set by the framework
+const (
+	ServantField    = "servant"
+	FuncField       = "func"
+	RequestIDField  = "requestId"
+	ClientIPField   = "clientIp"
+	ClientPortField = "clientPort"
+	TraceIDField    = "traceId"
+)
+
+type fieldsKey struct{}
+
+// NewContext returns the context carrying the fields, which are added to the ones of ctx and replace
+// the ones of the same keys.
+func NewContext(ctx context.Context, fields ...zap.Field) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	old := Fields(ctx)
+	fs := make([]zap.Field, 0, len(old)+len(fields))
+	for _, f := range old {
+		replaced := false
+		for _, nf := range fields {
+			if nf.Key == f.Key {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			fs = append(fs, f)
+		}
+	}
+	fs = append(fs, fields...)
+	return context.WithValue(ctx, fieldsKey{}, fs)
+}
+
+// Fields returns the fields carried by ctx.
+func Fields(ctx context.Context) []zap.Field {
+	if ctx == nil {
+		return nil
+	}
+	fs, _ := ctx.Value(fieldsKey{}).([]zap.Field)
+	return fs
+}
+
+// FromContext returns the framework logger with the fields carried by ctx, e.g. the servant, the function,
+// the request id, the client ip and port, the dyeing key and the trace id of the request being handled.
+func FromContext(ctx context.Context) *zap.Logger {
+	// the framework logger skips the caller of the package level functions
+	return WithContext(ctx, zapLogger.WithOptions(zap.AddCallerSkip(-1)))
+}
+
+// WithContext returns the logger with the fields carried by ctx.
+func WithContext(ctx context.Context, l *zap.Logger) *zap.Logger {
+	if fs := Fields(ctx); len(fs) > 0 {
+		return l.With(fs...)
+	}
+	return l
+}
@@ -0,0 +1,55 @@
+package zaplog
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
+)
+
+// DyeingKeyField is the field of the dyeing key in the logs of the dyed requests.
+const DyeingKeyField = "dyeingKey"
+
+// Dyeing returns the logger with the dyeing key of the request in ctx, whose logs are written by the
+// dyeing cores of the logger as well, or the logger itself if the request is not dyed.
+func Dyeing(ctx context.Context, l *zap.Logger) *zap.Logger {
+	if key, ok := current.GetDyeingKey(ctx); ok {
+		return l.With(zap.String(DyeingKeyField, key))
+	}
+	return l
+}
+
+// dyeingCore writes the logs of all the levels of the loggers with the dyeing key.
+type dyeingCore struct {
+	zapcore.Core
+	dyed bool
+}
+
+// NewDyeingCore returns the core writing the logs of the dyed requests to w whatever the level is, the
+// dyeing key is added to the logger by Dyeing or With(zap.String(DyeingKeyField, key)).
+func NewDyeingCore(w zapcore.WriteSyncer) zapcore.Core {
+	return &dyeingCore{Core: zapcore.NewCore(NewEncoder(), w, zapcore.DebugLevel)}
+}
+
+func (c *dyeingCore) Enabled(zapcore.Level) bool {
+	return c.dyed
+}
+
+func (c *dyeingCore) With(fields []zapcore.Field) zapcore.Core {
+	dyed := c.dyed
+	for _, f := range fields {
+		if f.Key == DyeingKeyField {
+			dyed = true
+		}
+	}
+	return &dyeingCore{Core: c.Core.With(fields), dyed: dyed}
+}
+
+func (c *dyeingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.dyed {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
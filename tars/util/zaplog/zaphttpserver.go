@@ -4,23 +4,59 @@ import (
 	"go.uber.org/zap"
 	"net"
 	"net/http"
+	"sync"
 )
 
-var zapLoggerHttpServer string
+var (
+	zapLoggerHttpMu       sync.Mutex
+	zapLoggerHttpServer   string
+	zapLoggerHttpListener net.Listener
+)
 
 func runZapLoggerHttpServer(config *zapLoggerConf, level zap.AtomicLevel) {
+	zapLoggerHttpMu.Lock()
+	defer zapLoggerHttpMu.Unlock()
+	if zapLoggerHttpListener != nil {
+		return
+	}
 	mux := http.NewServeMux()
 	mux.Handle(config.logApiPath, level)
 	listener, err := net.Listen("tcp", config.listenAddr)
 	if err != nil {
 		Fatal("runZapLoggerHttpServer err", zap.String("ListenAddr", config.listenAddr), zap.Error(err))
 	} else {
+		zapLoggerHttpListener = listener
 		zapLoggerHttpServer = "http://" + listener.Addr().String() + config.logApiPath
 		Info("make zapLoggerHttpServer success", zap.String("ZapLoggerHttpServer", zapLoggerHttpServer))
 	}
 	go func() {
-		if err = http.Serve(listener, mux); err != nil {
+		if err = http.Serve(listener, mux); err != nil && !isStopped(listener) {
 			Fatal("runZapLoggerHttpServer err", zap.String("ListenAddr", config.listenAddr), zap.Error(err))
 		}
 	}()
 }
+
+// stopZapLoggerHttpServer stops the http server of the level, which is then set directly by SetLogLevel.
+func stopZapLoggerHttpServer() {
+	zapLoggerHttpMu.Lock()
+	defer zapLoggerHttpMu.Unlock()
+	if zapLoggerHttpListener == nil {
+		return
+	}
+	listener := zapLoggerHttpListener
+	zapLoggerHttpListener = nil
+	zapLoggerHttpServer = ""
+	listener.Close()
+}
+
+func isStopped(listener net.Listener) bool {
+	zapLoggerHttpMu.Lock()
+	defer zapLoggerHttpMu.Unlock()
+	return zapLoggerHttpListener != listener
+}
+
+func getZapLoggerHttpServer() string {
+	zapLoggerHttpMu.Lock()
+	defer zapLoggerHttpMu.Unlock()
+	return zapLoggerHttpServer
+}
@@ -0,0 +1,112 @@
+package zaplog
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/MacgradyHuang/TarsGo/tars/util/current"
+)
+
+// TestSizeWriter tests the files are rolled by size.
+func TestSizeWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zaplog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewSizeWriter(dir, "app", 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	for i := 0; i < 3*1024; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	for _, name := range []string{"app.log", "app1.log", "app2.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app3.log")); !os.IsNotExist(err) {
+		t.Errorf("app3.log should not be kept")
+	}
+}
+
+// TestDyeingCore tests only the logs of the dyed requests are written by the dyeing core.
+func TestDyeingCore(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := zap.New(zapcore.NewTee(
+		NewWriterCore(ioutil.Discard, zap.WarnLevel),
+		NewDyeingCore(zapcore.AddSync(buf)),
+	))
+
+	Dyeing(context.Background(), l).Debug("not dyed")
+	if buf.Len() != 0 {
+		t.Fatalf("not dyed log is written: %s", buf.String())
+	}
+
+	ctx := current.ContextWithTarsCurrent(context.Background())
+	current.SetDyeingKey(ctx, "user42")
+	Dyeing(ctx, l).Debug("dyed")
+	if !strings.Contains(buf.String(), `"dyeingKey":"user42"`) {
+		t.Fatalf("dyed log is not written: %s", buf.String())
+	}
+}
+
+// TestNewContext tests the fields of the context replace the ones of the same keys.
+func TestNewContext(t *testing.T) {
+	ctx := NewContext(context.Background(), zap.String(ServantField, "App.Server.HelloObj"), zap.Int32(RequestIDField, 1))
+	ctx = NewContext(ctx, zap.String(ServantField, "App.Server.StoreObj"), zap.String(FuncField, "get"))
+
+	buf := &bytes.Buffer{}
+	WithContext(ctx, zap.New(NewWriterCore(buf, zap.DebugLevel))).Info("request")
+	out := buf.String()
+	for _, s := range []string{`"servant":"App.Server.StoreObj"`, `"requestId":1`, `"func":"get"`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s not found in %s", s, out)
+		}
+	}
+	if strings.Contains(out, "HelloObj") {
+		t.Errorf("servant is not replaced: %s", out)
+	}
+}
+
+// TestModuleLevel tests the level of a module follows the framework level until it is set.
+func TestModuleLevel(t *testing.T) {
+	defer zapLoggerLevel.SetLevel(zapLoggerLevel.Level())
+	zapLoggerLevel.SetLevel(zap.InfoLevel)
+
+	level := ModuleLevel("db")
+	if level.Enabled(zap.DebugLevel) || !level.Enabled(zap.InfoLevel) {
+		t.Fatal("level of the module does not follow the framework level")
+	}
+	if err := SetModuleLevel("db", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if !level.Enabled(zap.DebugLevel) {
+		t.Fatal("level of the module is not set")
+	}
+	if ModuleLevels()["db"] != "debug" {
+		t.Fatalf("levels of the modules: %v", ModuleLevels())
+	}
+	if err := SetModuleLevel("db", "verbose"); err == nil {
+		t.Fatal("unknown level is set")
+	}
+	SetModuleLevel("db", "")
+	if level.Enabled(zap.DebugLevel) {
+		t.Fatal("level of the module does not follow the framework level again")
+	}
+}
@@ -11,78 +11,120 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
 var (
 	zapLogger *zap.Logger
+	// zapLoggerLevel is the level of the framework logger and the loggers of the cores in the package.
+	zapLoggerLevel = zap.NewAtomicLevelAt(zap.DebugLevel)
+	// zapLoggerWriter and zapLoggerFields are the writer and the fields of the framework logger, which
+	// are shared by the loggers of the modules.
+	zapLoggerWriter zapcore.WriteSyncer
+	zapLoggerFields []zap.Field
+	zapEncoding     = EncodingJSON
 )
 
 func InitZapLogger(options ...zapLoggerOption) error {
-	var (
-		zapLoggerLevel zap.AtomicLevel
-		err            error
-	)
 	config := defaultOptions
 	for _, option := range options {
 		option.apply(&config)
 	}
 
-	if zapLogger, zapLoggerLevel, err = zapLoggerInit(&config); err != nil {
+	logger, err := zapLoggerInit(&config)
+	if err != nil {
 		fmt.Printf("ZapLogInit err: %v", err)
 		return err
 	}
 
-	zapLogger = zapLogger.WithOptions(zap.AddCallerSkip(1))
-	runZapLoggerHttpServer(&config, zapLoggerLevel)
+	zapLogger = logger.WithOptions(zap.AddCallerSkip(1))
+	if config.httpServer {
+		runZapLoggerHttpServer(&config, zapLoggerLevel)
+	} else {
+		stopZapLoggerHttpServer()
+	}
 	return nil
 }
 
-func zapLoggerInit(config *zapLoggerConf) (*zap.Logger, zap.AtomicLevel, error) {
+func zapLoggerInit(config *zapLoggerConf) (*zap.Logger, error) {
 	var (
-		zapLogger      *zap.Logger
-		zapLoggerLevel zap.AtomicLevel
-		err            error
+		zapLogger *zap.Logger
+		err       error
 	)
 
-	zapEncoderConfig := zap.NewProductionEncoderConfig()
-	zapEncoderConfig.TimeKey = "timestamp"
-	zapEncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-		enc.AppendString(t.Format("2006-01-02 15:04:05.000"))
+	switch config.encoding {
+	case EncodingJSON, EncodingConsole:
+		zapEncoding = config.encoding
+	default:
+		return zapLogger, errors.New("not support encoding " + config.encoding)
 	}
-	zapEncoder := zapcore.NewJSONEncoder(zapEncoderConfig)
-	zapWriter, err := getWriter(config.logPath)
+	zapEncoder := NewEncoder()
+	zapWriter, err := getWriter(config)
 	if err != nil {
 		fmt.Printf("zapLoggerInit err: %v", err)
-		return zapLogger, zapLoggerLevel, err
+		return zapLogger, err
 	}
-	if config.isTestEnv {
-		zapLoggerLevel = zap.NewAtomicLevelAt(zap.DebugLevel)
+	if config.level != "" {
+		l, err := ParseLevel(config.level)
+		if err != nil {
+			return zapLogger, err
+		}
+		zapLoggerLevel.SetLevel(l)
+	} else if config.isTestEnv {
+		zapLoggerLevel.SetLevel(zap.DebugLevel)
 	} else {
-		zapLoggerLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+		zapLoggerLevel.SetLevel(zap.InfoLevel)
 	}
-	zapLogger = zap.New(zapcore.NewCore(zapEncoder, zapcore.AddSync(zapWriter), zapLoggerLevel),
+	zapLoggerWriter = zapcore.AddSync(zapWriter)
+	zapLogger = zap.New(zapcore.NewCore(zapEncoder, zapLoggerWriter, zapLoggerLevel),
 		zap.AddCaller(), zap.AddStacktrace(zap.DPanicLevel))
 
+	zapLoggerFields = nil
 	if config.withPid {
-		zapLogger = zapLogger.With(zap.Int("pid", os.Getpid()))
+		zapLoggerFields = append(zapLoggerFields, zap.Int("pid", os.Getpid()))
 	}
 	if config.hostName != "" {
-		zapLogger = zapLogger.With(zap.String("hostname", config.hostName))
+		zapLoggerFields = append(zapLoggerFields, zap.String("hostname", config.hostName))
 	}
 	if config.eLKTempName != "" {
-		zapLogger = zapLogger.With(zap.String("service", config.eLKTempName))
+		zapLoggerFields = append(zapLoggerFields, zap.String("service", config.eLKTempName))
 	}
+	zapLogger = zapLogger.With(zapLoggerFields...)
 
-	return zapLogger, zapLoggerLevel, nil
+	return zapLogger, nil
 }
 
-func getWriter(filename string) (io.Writer, error) {
+// NewEncoder returns the encoder of the framework logs, which is json or console by the Encoding option.
+func NewEncoder() zapcore.Encoder {
+	zapEncoderConfig := zap.NewProductionEncoderConfig()
+	zapEncoderConfig.TimeKey = "timestamp"
+	zapEncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(t.Format("2006-01-02 15:04:05.000"))
+	}
+	if zapEncoding == EncodingConsole {
+		zapEncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		return zapcore.NewConsoleEncoder(zapEncoderConfig)
+	}
+	return zapcore.NewJSONEncoder(zapEncoderConfig)
+}
+
+// AtomicLevel returns the level of the framework logger, which is changed by SetLogLevel.
+func AtomicLevel() zap.AtomicLevel {
+	return zapLoggerLevel
+}
+
+func getWriter(config *zapLoggerConf) (io.Writer, error) {
+	filename := config.logPath
+	if config.logSizeMB > 0 {
+		return NewSizeWriter(filepath.Dir(filename), strings.TrimSuffix(filepath.Base(filename), ".log"),
+			config.logNum, config.logSizeMB)
+	}
 	hook, err := rotate.New(
 		filename+".%Y%m%d%H", // 没有使用go风格
 		rotate.WithLinkName(filename),
-		rotate.WithMaxAge(time.Hour*24*3),     // 保存3天
+		rotate.WithMaxAge(config.maxAge),      // 保存天数
 		rotate.WithRotationTime(time.Hour*24), // 切割频率:24小时
 	)
 	if err != nil {
@@ -147,16 +189,37 @@ func Sync() error {
 	return zapLogger.Sync()
 }
 
-func SetLogLevel(level string) error {
+// ParseLevel returns the level of the name, which is one of debug, info, warn, error, panic, fatal,
+// all and none in any case.
+func ParseLevel(level string) (zapcore.Level, error) {
 	switch strings.ToLower(level) {
-	case "debug", "info", "warn", "error", "panic", "fatal":
-		level = strings.ToLower(level)
-	case "all":
-		level = "debug"
-	case "none":
-		level = "fatal"
+	case "debug", "all":
+		return zap.DebugLevel, nil
+	case "info":
+		return zap.InfoLevel, nil
+	case "warn":
+		return zap.WarnLevel, nil
+	case "error":
+		return zap.ErrorLevel, nil
+	case "panic":
+		return zap.PanicLevel, nil
+	case "fatal", "none":
+		return zap.FatalLevel, nil
 	default:
-		return errors.New("not support level")
+		return zap.InfoLevel, errors.New("not support level")
+	}
+}
+
+func SetLogLevel(level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	level = l.String()
+	server := getZapLoggerHttpServer()
+	if server == "" {
+		zapLoggerLevel.SetLevel(l)
+		return nil
 	}
 
 	client := http.Client{}
@@ -169,7 +232,7 @@ func SetLogLevel(level string) error {
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(http.MethodPut, zapLoggerHttpServer, bytes.NewReader(data))
+	req, err := http.NewRequest(http.MethodPut, server, bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
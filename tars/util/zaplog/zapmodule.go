@@ -0,0 +1,73 @@
+package zaplog
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// moduleLevel is the level of a module, which follows the level of the framework logger until it is set.
+type moduleLevel struct {
+	set   int32
+	level zap.AtomicLevel
+}
+
+func (m *moduleLevel) Enabled(l zapcore.Level) bool {
+	if atomic.LoadInt32(&m.set) == 0 {
+		return zapLoggerLevel.Enabled(l)
+	}
+	return m.level.Enabled(l)
+}
+
+var modules sync.Map // module name -> *moduleLevel
+
+func getModuleLevel(module string) *moduleLevel {
+	if m, ok := modules.Load(module); ok {
+		return m.(*moduleLevel)
+	}
+	m, _ := modules.LoadOrStore(module, &moduleLevel{level: zap.NewAtomicLevel()})
+	return m.(*moduleLevel)
+}
+
+// ModuleLevel returns the level of the module, which is changed by SetModuleLevel.
+func ModuleLevel(module string) zapcore.LevelEnabler {
+	return getModuleLevel(module)
+}
+
+// SetModuleLevel sets the level of the module, the level of the framework logger is followed again if
+// the level is empty.
+func SetModuleLevel(module, level string) error {
+	m := getModuleLevel(module)
+	if level == "" {
+		atomic.StoreInt32(&m.set, 0)
+		return nil
+	}
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	m.level.SetLevel(l)
+	atomic.StoreInt32(&m.set, 1)
+	return nil
+}
+
+// ModuleLevels returns the levels of the modules which are set.
+func ModuleLevels() map[string]string {
+	levels := make(map[string]string)
+	modules.Range(func(k, v interface{}) bool {
+		if m := v.(*moduleLevel); atomic.LoadInt32(&m.set) == 1 {
+			levels[k.(string)] = m.level.String()
+		}
+		return true
+	})
+	return levels
+}
+
+// Named returns the logger of the module, which writes to the framework log with the name of the module
+// and has its own level. It should be called after the framework logger is initialized.
+func Named(module string) *zap.Logger {
+	return zap.New(zapcore.NewCore(NewEncoder(), zapLoggerWriter, getModuleLevel(module)),
+		zap.AddCaller(), zap.AddStacktrace(zap.DPanicLevel)).With(zapLoggerFields...).Named(module)
+}
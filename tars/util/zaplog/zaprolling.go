@@ -0,0 +1,124 @@
+package zaplog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	rotate "github.com/lestrrat-go/file-rotatelogs"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewWriterCore returns the core writing the logs of the level to w in json like the framework logs.
+func NewWriterCore(w io.Writer, level zapcore.LevelEnabler) zapcore.Core {
+	return zapcore.NewCore(NewEncoder(), zapcore.AddSync(w), level)
+}
+
+// NewDayWriter returns the writer of logPath/name_YYYYMMDD.log rolled by day, the files of the last num
+// days are kept, or 7 days if num is not positive.
+func NewDayWriter(logPath, name string, num int) (io.Writer, error) {
+	return newTimeWriter(filepath.Join(logPath, name+"_%Y%m%d.log"), 24*time.Hour, num)
+}
+
+// NewHourWriter returns the writer of logPath/name_YYYYMMDDHH.log rolled by hour, the files of the last
+// num hours are kept, or 7 days if num is not positive.
+func NewHourWriter(logPath, name string, num int) (io.Writer, error) {
+	return newTimeWriter(filepath.Join(logPath, name+"_%Y%m%d%H.log"), time.Hour, num)
+}
+
+func newTimeWriter(pattern string, rotation time.Duration, num int) (io.Writer, error) {
+	opts := []rotate.Option{rotate.WithClock(rotate.Local), rotate.WithRotationTime(rotation)}
+	if num > 0 {
+		opts = append(opts, rotate.WithMaxAge(-1), rotate.WithRotationCount(uint(num)))
+	}
+	return rotate.New(pattern, opts...)
+}
+
+// SizeWriter writes logPath/name.log, which is rolled to name1.log, name2.log ... when its size reaches
+// the limit, and num files are kept.
+type SizeWriter struct {
+	mu       sync.Mutex
+	logPath  string
+	name     string
+	num      int
+	size     int64
+	currSize int64
+	file     *os.File
+}
+
+// NewSizeWriter returns the writer of logPath/name.log rolled by sizeMB, num files are kept.
+func NewSizeWriter(logPath, name string, num, sizeMB int) (*SizeWriter, error) {
+	if err := os.MkdirAll(logPath, 0755); err != nil {
+		return nil, err
+	}
+	w := &SizeWriter{
+		logPath: logPath,
+		name:    name,
+		num:     num,
+		size:    int64(sizeMB) * 1024 * 1024,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SizeWriter) path(i int) string {
+	if i == 0 {
+		return filepath.Join(w.logPath, w.name+".log")
+	}
+	return filepath.Join(w.logPath, w.name+strconv.Itoa(i)+".log")
+}
+
+func (w *SizeWriter) open() error {
+	f, err := os.OpenFile(w.path(0), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.currSize = 0
+	if st, err := f.Stat(); err == nil {
+		w.currSize = st.Size()
+	}
+	return nil
+}
+
+// Write writes the log and rolls the files if the size reaches the limit.
+func (w *SizeWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n, err := w.file.Write(p)
+	w.currSize += int64(n)
+	if w.size > 0 && w.currSize >= w.size {
+		w.file.Close()
+		for i := w.num - 1; i >= 1; i-- {
+			if _, e := os.Stat(w.path(i - 1)); e == nil {
+				os.Rename(w.path(i-1), w.path(i))
+			}
+		}
+		if w.num <= 1 {
+			os.Remove(w.path(0))
+		}
+		if e := w.open(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return n, err
+}
+
+// Sync commits the file to the disk.
+func (w *SizeWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Sync()
+}
+
+// Close closes the file.
+func (w *SizeWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}